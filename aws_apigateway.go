@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/apigateway"
+	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/lambda"
+	"github.com/pulumi/pulumi/sdk/v2/go/pulumi"
+)
+
+// makeRoute exposes function as POST /<name> on the gateway. The returned
+// resources should be depended on by the gateway deployment.
+func makeRoute(
+	ctx *pulumi.Context,
+	name string,
+	gateway *apigateway.RestApi,
+	function *lambda.Function,
+	sourceArn pulumi.StringOutput) ([]pulumi.Resource, error) {
+
+	// The webhook route existed before makeRoute did, alias its original
+	// resource names so existing stacks update it in place.
+	opts := func(kind string, dependsOn ...pulumi.Resource) []pulumi.ResourceOption {
+		options := []pulumi.ResourceOption{pulumi.DependsOn(dependsOn)}
+		if name == "webhook" {
+			options = append(options, pulumi.Aliases([]pulumi.Alias{
+				{Name: pulumi.String(fmt.Sprintf("answering-machine-webhook-api-%s", kind))},
+			}))
+		}
+
+		return options
+	}
+
+	resource, err := apigateway.NewResource(ctx, fmt.Sprintf("answering-machine-webhook-api-%s-resource", name), &apigateway.ResourceArgs{
+		RestApi:  gateway.ID(),
+		PathPart: pulumi.String(name),
+		ParentId: gateway.RootResourceId,
+	}, pulumi.DependsOn([]pulumi.Resource{gateway}))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = apigateway.NewMethod(ctx, fmt.Sprintf("answering-machine-webhook-api-%s-post-method", name), &apigateway.MethodArgs{
+		HttpMethod:    pulumi.String("POST"),
+		Authorization: pulumi.String("NONE"),
+		RestApi:       gateway.ID(),
+		ResourceId:    resource.ID(),
+	}, opts("post-method", gateway, resource)...)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = apigateway.NewIntegration(ctx, fmt.Sprintf("answering-machine-webhook-api-%s-lambda-integration", name), &apigateway.IntegrationArgs{
+		HttpMethod:            pulumi.String("POST"),
+		IntegrationHttpMethod: pulumi.String("POST"),
+		ResourceId:            resource.ID(),
+		RestApi:               gateway.ID(),
+		Type:                  pulumi.String("AWS_PROXY"),
+		Uri:                   function.InvokeArn,
+	}, opts("lambda-integration", gateway, resource, function)...)
+	if err != nil {
+		return nil, err
+	}
+
+	permission, err := lambda.NewPermission(ctx, fmt.Sprintf("answering-machine-webhook-api-%s-lambda-permission", name), &lambda.PermissionArgs{
+		Action:    pulumi.String("lambda:InvokeFunction"),
+		Function:  function.Name,
+		Principal: pulumi.String("apigateway.amazonaws.com"),
+		SourceArn: sourceArn,
+	}, opts("lambda-permission", gateway, resource, function)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return []pulumi.Resource{resource, function, permission}, nil
+}
@@ -12,7 +12,8 @@ func makeLambda(
 	ctx *pulumi.Context,
 	name string,
 	statementEntries []policyStatementEntry,
-	env lambda.FunctionEnvironmentArgs) (*lambda.Function, error) {
+	env lambda.FunctionEnvironmentArgs,
+	timeout int) (*lambda.Function, error) {
 
 	assumeRolePolicy, err := newAssumeRolePolicyDocumentString("lambda.amazonaws.com")
 	if err != nil {
@@ -74,7 +75,7 @@ func makeLambda(
 		TracingConfig: lambda.FunctionTracingConfigArgs{
 			Mode: pulumi.String("Active"),
 		},
-		Timeout: pulumi.Int(10),
+		Timeout: pulumi.Int(timeout),
 	}
 
 	function, err := lambda.NewFunction(
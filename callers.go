@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/dynamodb"
+	"github.com/pulumi/pulumi/sdk/v2/go/pulumi"
+)
+
+// configureCallerLists creates the caller blocklist and the allowlist of
+// callers who ring through to the forwarding number. Entries are managed
+// with cmd/answering-machine-callers so they don't need a deployment.
+func configureCallerLists(ctx *pulumi.Context) (dynamodb.Table, dynamodb.Table, error) {
+	blocklistTable, err := makeCallerList(ctx, "answering-machine-caller-blocklist")
+	if err != nil {
+		return dynamodb.Table{}, dynamodb.Table{}, err
+	}
+
+	allowlistTable, err := makeCallerList(ctx, "answering-machine-caller-allowlist")
+	if err != nil {
+		return dynamodb.Table{}, dynamodb.Table{}, err
+	}
+
+	ctx.Export("Blocklist Table", blocklistTable.ID())
+	ctx.Export("Allowlist Table", allowlistTable.ID())
+
+	return *blocklistTable, *allowlistTable, nil
+}
+
+func makeCallerList(ctx *pulumi.Context, name string) (*dynamodb.Table, error) {
+	return dynamodb.NewTable(ctx, name, &dynamodb.TableArgs{
+		BillingMode: pulumi.String("PAY_PER_REQUEST"),
+		HashKey:     pulumi.String("Pattern"),
+		Attributes: dynamodb.TableAttributeArray{
+			dynamodb.TableAttributeArgs{
+				Name: pulumi.String("Pattern"),
+				Type: pulumi.String("S"),
+			},
+		},
+	})
+}
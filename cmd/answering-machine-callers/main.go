@@ -0,0 +1,83 @@
+// Command answering-machine-callers manages the caller blocklist and
+// allowlist without a deployment.
+//
+//	answering-machine-callers add +447700900123 +1900*
+//	answering-machine-callers remove +1900*
+//	answering-machine-callers -list allowlist add +447700900456
+//	answering-machine-callers -list allowlist list
+//
+// The table defaults to $BLOCKLIST_TABLE or $ALLOWLIST_TABLE, see
+// `pulumi stack output`.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"answering-machine/internal/callers"
+)
+
+func main() {
+	listName := flag.String("list", "blocklist", "blocklist or allowlist")
+	table := flag.String("table", "", "caller list table, defaults to $BLOCKLIST_TABLE or $ALLOWLIST_TABLE")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-list name] [-table name] add|remove|list [pattern...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *listName != "blocklist" && *listName != "allowlist" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *table == "" {
+		*table = os.Getenv(strings.ToUpper(*listName) + "_TABLE")
+	}
+
+	if *table == "" || flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+	list := callers.NewList(dynamodb.New(sess), *table)
+	ctx := context.Background()
+
+	command, patterns := flag.Arg(0), flag.Args()[1:]
+
+	switch command {
+	case "add":
+		for _, pattern := range patterns {
+			if err := list.Add(ctx, pattern); err != nil {
+				log.Fatal(err)
+			}
+		}
+	case "remove":
+		for _, pattern := range patterns {
+			if err := list.Remove(ctx, pattern); err != nil {
+				log.Fatal(err)
+			}
+		}
+	case "list":
+		entries, err := list.Entries(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, entry := range entries {
+			fmt.Println(entry)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,90 @@
+// Command answering-machine-greetings uploads the recorded greetings the
+// voice handler plays instead of speaking the greeting.
+//
+//	answering-machine-greetings put +447700900999 open hello.mp3
+//	answering-machine-greetings put default closed closed.wav
+//	answering-machine-greetings delete +447700900999 open
+//	answering-machine-greetings list
+//
+// Greetings are per dialled number, or "default" for every number without
+// its own, and per schedule slot: open, closed or holiday. Holidays fall
+// back to the closed greeting. The bucket defaults to $GREETINGS_BUCKET,
+// see `pulumi stack output`.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"answering-machine/internal/greetings"
+	"answering-machine/internal/schedule"
+)
+
+func main() {
+	bucket := flag.String("bucket", os.Getenv("GREETINGS_BUCKET"), "greetings bucket, defaults to $GREETINGS_BUCKET")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-bucket name] put number slot file | delete number slot | list\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *bucket == "" || flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+	store := greetings.NewStore(s3.New(sess), *bucket, time.Hour)
+	ctx := context.Background()
+
+	switch command := flag.Arg(0); {
+	case command == "put" && flag.NArg() == 4:
+		file, err := os.Open(flag.Arg(3))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+
+		err = store.Put(ctx, flag.Arg(1), slot(flag.Arg(2)), file.Name(), file)
+		if err != nil {
+			log.Fatal(err)
+		}
+	case command == "delete" && flag.NArg() == 3:
+		err := store.Delete(ctx, flag.Arg(1), slot(flag.Arg(2)))
+		if err != nil {
+			log.Fatal(err)
+		}
+	case command == "list" && flag.NArg() == 1:
+		uploaded, err := store.List(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, greeting := range uploaded {
+			fmt.Printf("%s\t%s\t%s\n", greeting.Number, greeting.Slot, greeting.Key)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// slot checks name is a schedule slot.
+func slot(name string) schedule.Slot {
+	switch slot := schedule.Slot(name); slot {
+	case schedule.SlotOpen, schedule.SlotClosed, schedule.SlotHoliday:
+		return slot
+	}
+
+	log.Fatalf("invalid slot %q, expected open, closed or holiday", name)
+
+	return ""
+}
@@ -0,0 +1,129 @@
+// Command answering-machine-local serves the webhook, voice and sms routes
+// on localhost, so the call flow can be tested without deploying.
+//
+//	answering-machine-local -addr localhost:8080
+//	ngrok http 8080
+//
+// Point the number's voice and messaging webhooks at the tunnel's /voice
+// and /sms. The handlers read the same environment as their Lambdas, with
+// TWILIO_AUTH_TOKEN (or another provider's credentials) needed to validate
+// requests.
+//
+// With -storage local (the default) tables and the bucket are files under
+// -data, greetings are only spoken, and nothing downstream of the webhook
+// runs. With -storage aws the handlers use the deployed tables and buckets,
+// see `pulumi stack output`, which starts the rest of the pipeline as usual.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	_ "time/tzdata"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+
+	"answering-machine/internal/callers"
+	"answering-machine/internal/lambdahttp"
+	"answering-machine/internal/localstore"
+	"answering-machine/internal/mailbox"
+	"answering-machine/internal/sms"
+	"answering-machine/internal/voice"
+	"answering-machine/internal/webhook"
+)
+
+// tables are the table variables the handlers read, with their local
+// defaults and hash keys.
+var tables = []struct {
+	env, name, key string
+}{
+	{"TABLE", "answering-machine-webhook-data", "RecordingSid"},
+	{"CALL_STATUS_TABLE", "answering-machine-call-status", "CallSid"},
+	{"MESSAGES_TABLE", "answering-machine-messages", "MessageSid"},
+	{"BLOCKLIST_TABLE", "answering-machine-caller-blocklist", callers.KeyAttribute},
+	{"ALLOWLIST_TABLE", "answering-machine-caller-allowlist", callers.KeyAttribute},
+	{"MAILBOX_TABLE", "answering-machine-mailboxes", mailbox.KeyAttribute},
+}
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	storage := flag.String("storage", "local", "local or aws")
+	dir := flag.String("data", "local-data", "directory for -storage local")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-addr host:port] [-storage local|aws] [-data dir]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var db dynamodbiface.DynamoDBAPI
+	var uploader s3manageriface.UploaderAPI
+	var s3client s3iface.S3API
+
+	switch *storage {
+	case "local":
+		keys := map[string]string{}
+		for _, table := range tables {
+			if os.Getenv(table.env) == "" {
+				os.Setenv(table.env, table.name)
+			}
+			keys[os.Getenv(table.env)] = table.key
+		}
+		if os.Getenv("RECORDING_BUCKET_NAME") == "" {
+			os.Setenv("RECORDING_BUCKET_NAME", "answering-machine-recordings")
+		}
+
+		db = localstore.NewDynamoDB(*dir, keys)
+		uploader = localstore.NewUploader(*dir)
+	case "aws":
+		sess := session.Must(session.NewSessionWithOptions(session.Options{
+			SharedConfigState: session.SharedConfigEnable,
+		}))
+
+		db = dynamodb.New(sess)
+		uploader = s3manager.NewUploader(sess)
+		s3client = s3.New(sess)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	httpClient := &http.Client{}
+
+	webhookDeps, err := webhook.NewFromEnv(db, httpClient)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	voiceDeps, err := voice.NewFromEnv(db, s3client, httpClient)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	smsDeps, err := sms.NewFromEnv(db, uploader, httpClient)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/webhook", lambdahttp.Handler(webhookDeps.Handler))
+	mux.Handle("/voice", lambdahttp.Handler(voiceDeps.Handler))
+	mux.Handle("/sms", lambdahttp.Handler(smsDeps.Handler))
+
+	log.Printf("listening on %s with %s storage", *addr, *storage)
+	log.Fatal(http.ListenAndServe(*addr, logRequests(mux)))
+}
+
+func logRequests(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("%s %s", r.Method, r.URL)
+		handler.ServeHTTP(w, r)
+	})
+}
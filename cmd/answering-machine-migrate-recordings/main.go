@@ -0,0 +1,60 @@
+// Command answering-machine-migrate-recordings copies recordings archived
+// at their bare RecordingSid to mailbox/<to>/yyyy/mm/dd/<RecordingSid>.mp3,
+// and points the webhook data and transcription items at the copies.
+//
+//	answering-machine-migrate-recordings -dry-run
+//	answering-machine-migrate-recordings -kms-key alias/answering-machine-recordings -delete
+//
+// Recordings are dated by when they were archived. The originals are kept
+// unless -delete is passed, and the command can be run again if it fails
+// part way. The bucket and tables default to $RECORDING_BUCKET_NAME,
+// $ANSWERING_MACHINE_WEBHOOK_DATA_TABLE and
+// $ANSWERING_MACHINE_TRANSCRIPTON_TABLE, see `pulumi stack output`.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"answering-machine/internal/recording"
+)
+
+func main() {
+	bucket := flag.String("bucket", os.Getenv("RECORDING_BUCKET_NAME"), "recordings bucket, defaults to $RECORDING_BUCKET_NAME")
+	webhookTable := flag.String("webhook-table", os.Getenv("ANSWERING_MACHINE_WEBHOOK_DATA_TABLE"), "webhook data table, defaults to $ANSWERING_MACHINE_WEBHOOK_DATA_TABLE")
+	transcriptionTable := flag.String("transcription-table", os.Getenv("ANSWERING_MACHINE_TRANSCRIPTON_TABLE"), "transcription table, defaults to $ANSWERING_MACHINE_TRANSCRIPTON_TABLE")
+	kmsKey := flag.String("kms-key", os.Getenv("RECORDING_KMS_KEY_ID"), "KMS key to encrypt the copies with, defaults to $RECORDING_KMS_KEY_ID")
+	deleteOriginals := flag.Bool("delete", false, "delete each original once it has been copied")
+	dryRun := flag.Bool("dry-run", false, "list the copies without making them")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-bucket name] [-webhook-table name] [-transcription-table name] [-kms-key id] [-delete] [-dry-run]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *bucket == "" || *webhookTable == "" || flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	sess := session.Must(session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	}))
+	migration := recording.NewMigration(s3.New(sess), dynamodb.New(sess), *bucket, *webhookTable, *transcriptionTable)
+	migration.KMSKeyID = *kmsKey
+	migration.DeleteOriginals = *deleteOriginals
+	migration.DryRun = *dryRun
+
+	migrated, err := migration.Run(context.Background())
+	log.Printf("migrated %d recordings", migrated)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
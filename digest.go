@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+
+	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/cloudwatch"
+	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/dynamodb"
+	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/lambda"
+	"github.com/pulumi/pulumi/sdk/v2/go/pulumi"
+)
+
+func configureHangUpDigest(ctx *pulumi.Context, answeringMachineTable, mailboxTable dynamodb.Table) error {
+	statementEntries := []policyStatementEntry{
+		{
+			Effect:   "Allow",
+			Action:   []string{"ses:SendEmail"},
+			Resource: []string{"*"},
+		},
+		{
+			Effect:       "Allow",
+			Action:       []string{"dynamodb:Scan"},
+			Resource:     []string{"%s/index/DigestPending"},
+			resourceArgs: []interface{}{answeringMachineTable.Arn},
+		},
+		{
+			Effect:       "Allow",
+			Action:       []string{"dynamodb:UpdateItem"},
+			Resource:     []string{"%s"},
+			resourceArgs: []interface{}{answeringMachineTable.Arn},
+		},
+		{
+			Effect:       "Allow",
+			Action:       []string{"dynamodb:GetItem"},
+			Resource:     []string{"%s"},
+			resourceArgs: []interface{}{mailboxTable.Arn},
+		},
+	}
+
+	env := lambda.FunctionEnvironmentArgs{
+		Variables: pulumi.StringMap{
+			"ANSWERING_MACHINE_WEBHOOK_DATA_TABLE": answeringMachineTable.ID(),
+			"MAILBOX_TABLE":                        mailboxTable.ID(),
+			"TO_EMAIL":                             pulumi.String(os.Getenv("TO_EMAIL")),
+			"FROM_EMAIL":                           pulumi.String(os.Getenv("FROM_EMAIL")),
+			"HANG_UPS":                             pulumi.String(os.Getenv("HANG_UPS")),
+		},
+	}
+
+	function, err := makeLambda(ctx, "send-digest", statementEntries, env, 10)
+	if err != nil {
+		return err
+	}
+
+	rule, err := cloudwatch.NewEventRule(ctx, "answering-machine-hang-up-digest", &cloudwatch.EventRuleArgs{
+		Description:        pulumi.String("Daily digest of hang-ups"),
+		ScheduleExpression: pulumi.String("cron(0 8 * * ? *)"),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = lambda.NewPermission(ctx, "answering-machine-hang-up-digest-lambda-permission", &lambda.PermissionArgs{
+		Action:    pulumi.String("lambda:InvokeFunction"),
+		Function:  function.Name,
+		Principal: pulumi.String("events.amazonaws.com"),
+		SourceArn: rule.Arn,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = cloudwatch.NewEventTarget(ctx, "answering-machine-hang-up-digest", &cloudwatch.EventTargetArgs{
+		Rule: rule.Name,
+		Arn:  function.Arn,
+	})
+
+	return err
+}
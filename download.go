@@ -1,16 +1,23 @@
 package main
 
 import (
+	"os"
+
 	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/dynamodb"
+	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/kms"
 	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/lambda"
 	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/s3"
+	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/sqs"
 	"github.com/pulumi/pulumi/sdk/v2/go/pulumi"
 )
 
-func configureRecordingDownload(ctx *pulumi.Context, answeringMachineTable dynamodb.Table) (pulumi.IDOutput, error) {
+// configureRecordingBucket creates the bucket recordings and message media
+// are saved to, which also holds the uploaded greetings, and the key the
+// recordings are encrypted with.
+func configureRecordingBucket(ctx *pulumi.Context) (pulumi.IDOutput, *kms.Key, error) {
 	bucket, err := s3.NewBucket(ctx, "answering-machine-recordings", &s3.BucketArgs{})
 	if err != nil {
-		return pulumi.IDOutput{}, err
+		return pulumi.IDOutput{}, nil, err
 	}
 
 	_, err = s3.NewBucketPublicAccessBlock(ctx, "answering-machine-recordings-public-access-block", &s3.BucketPublicAccessBlockArgs{
@@ -19,18 +26,46 @@ func configureRecordingDownload(ctx *pulumi.Context, answeringMachineTable dynam
 		Bucket:            bucket.ID(),
 	})
 	if err != nil {
-		return pulumi.IDOutput{}, err
+		return pulumi.IDOutput{}, nil, err
 	}
 
+	key, err := kms.NewKey(ctx, "answering-machine-recordings-key", &kms.KeyArgs{
+		Description:       pulumi.String("Encrypts answering machine recordings"),
+		EnableKeyRotation: pulumi.Bool(true),
+	})
+	if err != nil {
+		return pulumi.IDOutput{}, nil, err
+	}
+
+	_, err = kms.NewAlias(ctx, "answering-machine-recordings-key-alias", &kms.AliasArgs{
+		Name:        pulumi.String("alias/answering-machine-recordings"),
+		TargetKeyId: key.KeyId,
+	})
+	if err != nil {
+		return pulumi.IDOutput{}, nil, err
+	}
+
+	ctx.Export("Recording Bucket", bucket.ID())
+
+	return bucket.ID(), key, nil
+}
+
+func configureRecordingDownload(ctx *pulumi.Context, answeringMachineTable, mailboxTable dynamodb.Table, recordingBucketID pulumi.IDOutput, recordingKey *kms.Key, failureQueue *sqs.Queue) error {
 	statementEntries := []policyStatementEntry{
+		{
+			Effect:       "Allow",
+			Action:       []string{"sqs:SendMessage"},
+			Resource:     []string{"%s"},
+			resourceArgs: []interface{}{failureQueue.Arn},
+		},
 		{
 			Effect: "Allow",
-			Action: []string{"s3:PutObject"},
+			Action: []string{"s3:PutObject", "s3:PutObjectTagging", "s3:GetObject"},
 			Resource: []string{
 				"arn:aws:s3:::%s/*",
 			},
 			resourceArgs: []interface{}{
-				bucket.ID(),
+				recordingBucketID,
 			},
 		},
 		{
@@ -44,17 +79,42 @@ func configureRecordingDownload(ctx *pulumi.Context, answeringMachineTable dynam
 			Resource:     []string{"%s"},
 			resourceArgs: []interface{}{answeringMachineTable.StreamArn},
 		},
+		{
+			Effect:       "Allow",
+			Action:       []string{"kms:GenerateDataKey", "kms:Decrypt"},
+			Resource:     []string{"%s"},
+			resourceArgs: []interface{}{recordingKey.Arn},
+		},
+		{
+			Effect:       "Allow",
+			Action:       []string{"dynamodb:GetItem", "dynamodb:UpdateItem"},
+			Resource:     []string{"%s"},
+			resourceArgs: []interface{}{answeringMachineTable.Arn},
+		},
+		{
+			Effect:       "Allow",
+			Action:       []string{"dynamodb:GetItem"},
+			Resource:     []string{"%s"},
+			resourceArgs: []interface{}{mailboxTable.Arn},
+		},
 	}
 
 	env := lambda.FunctionEnvironmentArgs{
-		Variables: pulumi.StringMap{
-			"RECORDING_BUCKET_NAME": bucket.ID(),
-		},
+		Variables: telephonyVariables(pulumi.StringMap{
+			"RECORDING_BUCKET_NAME":                recordingBucketID,
+			"RECORDING_KMS_KEY_ID":                 recordingKey.Arn,
+			"ANSWERING_MACHINE_WEBHOOK_DATA_TABLE": answeringMachineTable.ID(),
+			"MAILBOX_TABLE":                        mailboxTable.ID(),
+			"EMPTY_RECORDING_MIN_LENGTH":           pulumi.String(os.Getenv("EMPTY_RECORDING_MIN_LENGTH")),
+			"EMPTY_RECORDING_MIN_LEVEL":            pulumi.String(os.Getenv("EMPTY_RECORDING_MIN_LEVEL")),
+		}),
 	}
 
-	function, err := makeLambda(ctx, "download-recording", statementEntries, env)
+	// Downloads are retried, then analysed, uploaded and checked, which
+	// takes more than a few seconds for a long recording.
+	function, err := makeLambda(ctx, "download-recording", statementEntries, env, 120)
 	if err != nil {
-		return pulumi.IDOutput{}, err
+		return err
 	}
 
 	_, err = lambda.NewPermission(ctx, "answering-machine-recording-download-lambda-permission", &lambda.PermissionArgs{
@@ -64,17 +124,25 @@ func configureRecordingDownload(ctx *pulumi.Context, answeringMachineTable dynam
 		SourceArn: answeringMachineTable.StreamArn,
 	})
 	if err != nil {
-		return pulumi.IDOutput{}, err
+		return err
 	}
 
+	// pulumi-aws v2 can't set FunctionResponseTypes to report batch item
+	// failures, so a failed record fails the whole batch. Bisecting it
+	// retries the records around the failure in ever smaller batches, the
+	// handler skips recordings it has archived already, and a record that
+	// still fails goes to the failure queue.
 	_, err = lambda.NewEventSourceMapping(ctx, "answering-machine-new-recording", &lambda.EventSourceMappingArgs{
-		EventSourceArn:   answeringMachineTable.StreamArn,
-		FunctionName:     function.Arn,
-		StartingPosition: pulumi.String("LATEST"),
+		EventSourceArn:             answeringMachineTable.StreamArn,
+		FunctionName:               function.Arn,
+		StartingPosition:           pulumi.String("LATEST"),
+		BisectBatchOnFunctionError: pulumi.Bool(true),
+		MaximumRetryAttempts:       pulumi.Int(streamRetryAttempts),
+		DestinationConfig:          onFailure(failureQueue),
 	})
 	if err != nil {
-		return pulumi.IDOutput{}, err
+		return err
 	}
 
-	return bucket.ID(), err
+	return err
 }
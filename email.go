@@ -4,16 +4,30 @@ import (
 	"os"
 
 	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/dynamodb"
+	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/kms"
 	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/lambda"
+	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/sqs"
 	"github.com/pulumi/pulumi/sdk/v2/go/pulumi"
 )
 
 func configureSendEmail(
 	ctx *pulumi.Context,
-	answeringMachineTable, transcriptionTable dynamodb.Table,
-	recordingBucketID pulumi.IDOutput) error {
+	answeringMachineTable, transcriptionTable, messagesTable, mailboxTable dynamodb.Table,
+	recordingBucketID pulumi.IDOutput, recordingKey *kms.Key, failureQueue *sqs.Queue) error {
 
 	statementEntries := []policyStatementEntry{
+		{
+			Effect:       "Allow",
+			Action:       []string{"sqs:SendMessage"},
+			Resource:     []string{"%s"},
+			resourceArgs: []interface{}{failureQueue.Arn},
+		},
+		{
+			Effect:       "Allow",
+			Action:       []string{"kms:Decrypt"},
+			Resource:     []string{"%s"},
+			resourceArgs: []interface{}{recordingKey.Arn},
+		},
 		{
 			Effect:   "Allow",
 			Action:   []string{"ses:SendRawEmail"},
@@ -33,10 +47,26 @@ func configureSendEmail(
 			Resource: []string{
 				"%s",
 				"%s",
+				"%s",
+			},
+			resourceArgs: []interface{}{
+				answeringMachineTable.Arn,
+				transcriptionTable.Arn,
+				mailboxTable.Arn,
+			},
+		},
+		{
+			Effect: "Allow",
+			Action: []string{"dynamodb:UpdateItem"},
+			Resource: []string{
+				"%s",
+				"%s",
+				"%s",
 			},
 			resourceArgs: []interface{}{
 				answeringMachineTable.Arn,
 				transcriptionTable.Arn,
+				messagesTable.Arn,
 			},
 		},
 		{
@@ -47,8 +77,16 @@ func configureSendEmail(
 				"dynamodb:DescribeStream",
 				"dynamodb:ListStreams",
 			},
-			Resource:     []string{"%s"},
-			resourceArgs: []interface{}{transcriptionTable.StreamArn},
+			Resource: []string{
+				"%s",
+				"%s",
+				"%s",
+			},
+			resourceArgs: []interface{}{
+				transcriptionTable.StreamArn,
+				answeringMachineTable.StreamArn,
+				messagesTable.StreamArn,
+			},
 		},
 	}
 
@@ -57,11 +95,14 @@ func configureSendEmail(
 			"ANSWERING_MACHINE_WEBHOOK_DATA_TABLE": answeringMachineTable.ID(),
 			"ANSWERING_MACHINE_TRANSCRIPTON_TABLE": transcriptionTable.ID(),
 			"ANSWERING_MACHINE_RECORDING_BUCKET":   recordingBucketID,
+			"MAILBOX_TABLE":                        mailboxTable.ID(),
 			"TO_EMAIL":                             pulumi.String(os.Getenv("TO_EMAIL")),
+			"FROM_EMAIL":                           pulumi.String(os.Getenv("FROM_EMAIL")),
+			"OPTION_EMAIL":                         pulumi.String(os.Getenv("OPTION_EMAIL")),
 		},
 	}
 
-	function, err := makeLambda(ctx, "send-email", statementEntries, env)
+	function, err := makeLambda(ctx, "send-email", statementEntries, env, 10)
 	if err != nil {
 		return err
 	}
@@ -76,10 +117,40 @@ func configureSendEmail(
 		return err
 	}
 
+	// As for the recording download, failing batches are bisected and then
+	// queued. The handler marks each item it emails, so retried records
+	// aren't emailed twice.
 	_, err = lambda.NewEventSourceMapping(ctx, "answering-machine-google-transcript-ready", &lambda.EventSourceMappingArgs{
-		EventSourceArn:   transcriptionTable.StreamArn,
-		FunctionName:     function.Arn,
-		StartingPosition: pulumi.String("LATEST"),
+		EventSourceArn:             transcriptionTable.StreamArn,
+		FunctionName:               function.Arn,
+		StartingPosition:           pulumi.String("LATEST"),
+		BisectBatchOnFunctionError: pulumi.Bool(true),
+		MaximumRetryAttempts:       pulumi.Int(streamRetryAttempts),
+		DestinationConfig:          onFailure(failureQueue),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = lambda.NewEventSourceMapping(ctx, "answering-machine-missed-call", &lambda.EventSourceMappingArgs{
+		EventSourceArn:             answeringMachineTable.StreamArn,
+		FunctionName:               function.Arn,
+		StartingPosition:           pulumi.String("LATEST"),
+		BisectBatchOnFunctionError: pulumi.Bool(true),
+		MaximumRetryAttempts:       pulumi.Int(streamRetryAttempts),
+		DestinationConfig:          onFailure(failureQueue),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = lambda.NewEventSourceMapping(ctx, "answering-machine-new-message", &lambda.EventSourceMappingArgs{
+		EventSourceArn:             messagesTable.StreamArn,
+		FunctionName:               function.Arn,
+		StartingPosition:           pulumi.String("LATEST"),
+		BisectBatchOnFunctionError: pulumi.Bool(true),
+		MaximumRetryAttempts:       pulumi.Int(streamRetryAttempts),
+		DestinationConfig:          onFailure(failureQueue),
 	})
 	if err != nil {
 		return err
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/lambda"
+	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/sqs"
+	"github.com/pulumi/pulumi/sdk/v2/go/pulumi"
+)
+
+// streamRetryAttempts bounds the retries of a failing stream batch, so a
+// record that always fails doesn't hold up its shard until it expires.
+const streamRetryAttempts = 3
+
+// configureStreamFailures creates the queue that stream event source
+// mappings send the records they gave up on to, kept for the longest SQS
+// allows so they can be looked into and replayed.
+func configureStreamFailures(ctx *pulumi.Context) (*sqs.Queue, error) {
+	queue, err := sqs.NewQueue(ctx, "answering-machine-stream-failures", &sqs.QueueArgs{
+		MessageRetentionSeconds: pulumi.Int(14 * 24 * 60 * 60),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.Export("Stream Failure Queue", queue.ID())
+
+	return queue, nil
+}
+
+// onFailure sends the records a mapping gives up on to the queue.
+func onFailure(queue *sqs.Queue) lambda.EventSourceMappingDestinationConfigArgs {
+	return lambda.EventSourceMappingDestinationConfigArgs{
+		OnFailure: lambda.EventSourceMappingDestinationConfigOnFailureArgs{
+			DestinationArn: queue.Arn,
+		},
+	}
+}
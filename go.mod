@@ -7,7 +7,10 @@ require (
 	github.com/aws/aws-lambda-go v1.17.0
 	github.com/aws/aws-sdk-go v1.33.7
 	github.com/aws/aws-xray-sdk-go v1.1.0
+	github.com/golang/protobuf v1.3.5
 	github.com/googleapis/gax-go v2.0.2+incompatible // indirect
+	github.com/googleapis/gax-go/v2 v2.0.5
+	github.com/hajimehoshi/go-mp3 v0.3.4
 	github.com/mitchellh/mapstructure v1.1.2
 	github.com/prometheus/common v0.4.0
 	github.com/pulumi/pulumi-aws/sdk v1.31.0
@@ -17,6 +20,6 @@ require (
 	github.com/uber/jaeger-client-go v2.22.1+incompatible
 	go.opencensus.io v0.22.4 // indirect
 	golang.org/x/net v0.0.0-20200324143707-d3edc9973b7e
-	google.golang.org/api v0.29.0 // indirect
+	google.golang.org/api v0.29.0
 	google.golang.org/genproto v0.0.0-20200331122359-1ee6d9798940
 )
@@ -4,12 +4,21 @@ import (
 	"os"
 
 	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/dynamodb"
+	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/kms"
 	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/lambda"
 	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/s3"
 	"github.com/pulumi/pulumi/sdk/v2/go/pulumi"
 )
 
-func configureGoogleSpeech(ctx *pulumi.Context, recordingBucketID pulumi.IDOutput) (dynamodb.Table, error) {
+// transcriptionTimeout is how many seconds the transcription function has,
+// enough for Amazon Transcribe to finish a voicemail.
+const transcriptionTimeout = 300
+
+func configureGoogleSpeech(
+	ctx *pulumi.Context,
+	answeringMachineTable, mailboxTable dynamodb.Table,
+	recordingBucketID pulumi.IDOutput, recordingKey *kms.Key) (dynamodb.Table, error) {
+
 	dynamodbTable, err := dynamodb.NewTable(ctx, "answering-machine-transcript-data", &dynamodb.TableArgs{
 		BillingMode: pulumi.String("PAY_PER_REQUEST"),
 		HashKey:     pulumi.String("RecordingSid"),
@@ -25,8 +34,15 @@ func configureGoogleSpeech(ctx *pulumi.Context, recordingBucketID pulumi.IDOutpu
 	if err != nil {
 		return dynamodb.Table{}, err
 	}
+	ctx.Export("Transcription Table", dynamodbTable.ID())
 
 	statementEntries := []policyStatementEntry{
+		{
+			Effect:       "Allow",
+			Action:       []string{"kms:Decrypt"},
+			Resource:     []string{"%s"},
+			resourceArgs: []interface{}{recordingKey.Arn},
+		},
 		{
 			Effect:   "Allow",
 			Action:   []string{"ses:SendRawEmail"},
@@ -38,23 +54,45 @@ func configureGoogleSpeech(ctx *pulumi.Context, recordingBucketID pulumi.IDOutpu
 			Resource:     []string{"arn:aws:s3:::%s/*"},
 			resourceArgs: []interface{}{recordingBucketID},
 		},
+		{
+			Effect:   "Allow",
+			Action:   []string{"transcribe:StartTranscriptionJob", "transcribe:GetTranscriptionJob"},
+			Resource: []string{"*"},
+		},
+		// Transcriptions still running when the function times out are handed
+		// off to another invocation. The function's auto-generated name isn't
+		// known until it has been created, which needs this policy.
+		{
+			Effect:   "Allow",
+			Action:   []string{"lambda:InvokeFunction"},
+			Resource: []string{"arn:aws:lambda:*:*:function:answering-machine-google-speech-*"},
+		},
 		{
 			Effect:       "Allow",
 			Action:       []string{"dynamodb:PutItem"},
 			Resource:     []string{"arn:aws:dynamodb:*:*:table/%s"},
 			resourceArgs: []interface{}{dynamodbTable.ID()},
 		},
+		{
+			Effect:       "Allow",
+			Action:       []string{"dynamodb:GetItem"},
+			Resource:     []string{"%s", "%s"},
+			resourceArgs: []interface{}{answeringMachineTable.Arn, mailboxTable.Arn},
+		},
 	}
 
 	env := lambda.FunctionEnvironmentArgs{
 		Variables: pulumi.StringMap{
 			"ANSWERING_MACHINE_TRANSCRIPTON_TABLE": dynamodbTable.ID(),
+			"ANSWERING_MACHINE_WEBHOOK_DATA_TABLE": answeringMachineTable.ID(),
+			"MAILBOX_TABLE":                        mailboxTable.ID(),
+			"TRANSCRIBER":                          pulumi.String(os.Getenv("TRANSCRIBER")),
+			"ALTERNATIVE_LANGUAGES":                pulumi.String(os.Getenv("ALTERNATIVE_LANGUAGES")),
 			"GOOGLE_AUTH_JSON_B64":                 pulumi.String(os.Getenv("GOOGLE_AUTH_JSON_B64")),
-			"GOOGLE_APPLICATION_CREDENTIALS":       pulumi.String("/tmp/auth.json"),
 		},
 	}
 
-	function, err := makeLambda(ctx, "google-speech", statementEntries, env)
+	function, err := makeLambda(ctx, "google-speech", statementEntries, env, transcriptionTimeout)
 	if err != nil {
 		return dynamodb.Table{}, err
 	}
@@ -73,9 +111,12 @@ func configureGoogleSpeech(ctx *pulumi.Context, recordingBucketID pulumi.IDOutpu
 		Bucket: recordingBucketID,
 		LambdaFunctions: s3.BucketNotificationLambdaFunctionArray{
 			s3.BucketNotificationLambdaFunctionArgs{
+				// Copies made by the recording migration aren't new recordings.
 				Events: pulumi.StringArray{
-					pulumi.String("s3:ObjectCreated:*"),
+					pulumi.String("s3:ObjectCreated:Put"),
+					pulumi.String("s3:ObjectCreated:CompleteMultipartUpload"),
 				},
+				FilterPrefix:      pulumi.String("mailbox/"),
 				LambdaFunctionArn: function.Arn,
 			},
 		},
@@ -1,71 +1,488 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-xray-sdk-go/xray"
+
+	"answering-machine/internal/audio"
+	"answering-machine/internal/carrier"
+	"answering-machine/internal/mailbox"
+	"answering-machine/internal/recording"
+	"answering-machine/internal/stream"
+	"answering-machine/internal/telephony"
+)
+
+// Outcomes of deleting a recording from the carrier, stored on the webhook
+// data item as CarrierDeletion.
+const (
+	deletionDeleted = "deleted"
+	deletionFailed  = "failed"
 )
 
 type deps struct {
-	httpClient httpclientiface
-	s3uploader s3manageriface.UploaderAPI
+	provider   recordingFetcher
+	deleter    telephony.RecordingDeleter
+	s3         s3iface.S3API
+	dynamodb   dynamodbiface.DynamoDBAPI
+	mailboxes  mailboxStore
 	bucketName string
+	kmsKeyID   string
+	tableName  string
+	backoff    backoff
+	thresholds audio.Thresholds
+	now        func() time.Time
+}
+
+// backoff bounds the retries of a download that failed with a temporary
+// status, such as a recording that isn't ready yet, and of deletions.
+// A retry is only waited for if reserve is left before the Lambda's
+// deadline afterwards, time to upload and store the recording.
+type backoff struct {
+	attempts int
+	delay    time.Duration
+	maxDelay time.Duration
+	reserve  time.Duration
+}
+
+// errOutOfTime is returned instead of waiting for a retry that would leave
+// too little time before the deadline.
+var errOutOfTime = errors.New("no time left to retry")
+
+type recordingFetcher interface {
+	FetchRecording(ctx context.Context, event telephony.RecordingEvent) (io.ReadCloser, error)
 }
 
-type httpclientiface interface {
-	Do(req *http.Request) (*http.Response, error)
+type mailboxStore interface {
+	Get(ctx context.Context, number string) (mailbox.Mailbox, error)
 }
 
+// deletion is the outcome of deleting the recording from the carrier, as
+// stored on the webhook data item.
+type deletion struct {
+	CarrierDeletion         string
+	CarrierDeletionAttempts int
+}
+
+// handler archives each new recording, then deletes it from the carrier if
+// the mailbox asks for that. A failed deletion updates the item, whose
+// MODIFY record comes back here to be retried, so it never holds up the
+// recording or its transcription.
+// Other updates, like the digest marking a hang-up sent, come back as
+// MODIFY records too. Only a failed deletion with attempts left is acted
+// on, and the attempts bound the retries however many records arrive.
 func (deps *deps) handler(ctx context.Context, event events.DynamoDBEvent) error {
-	for _, record := range event.Records {
-		sid := record.Change.NewImage["RecordingSid"].String()
-		url := record.Change.NewImage["RecordingUrl"].String() + ".mp3"
+	processor := stream.Processor{
+		Workers:    4,
+		EventNames: []string{"INSERT", "MODIFY"},
+	}
+
+	return processor.Process(ctx, event, deps.process)
+}
+
+func (deps *deps) process(ctx context.Context, record events.DynamoDBEventRecord) error {
+	callback := telephony.RecordingEvent{}
+	err := stream.UnmarshalImage(record.Change.NewImage, &callback)
+	if err != nil {
+		return err
+	}
 
-		request, err := http.NewRequest(http.MethodGet, url, nil)
+	if record.EventName == "INSERT" {
+		return deps.archive(ctx, callback)
+	}
+
+	return deps.retryDeletion(ctx, record, callback)
+}
+
+// archive copies a completed recording into the bucket with the call's
+// metadata, and once S3 has it all stores its key on the webhook data item
+// and deletes it from the carrier. Nothing after the key is stored fails
+// the record, and a recording whose key is stored isn't archived again
+// when its record is retried.
+// S3 verifies the upload against its Content-MD5. The SHA-256 stored in
+// the metadata is of the download, for later readers to check their copy
+// against; it doesn't verify the upload.
+func (deps *deps) archive(ctx context.Context, callback telephony.RecordingEvent) error {
+	if !callback.Completed() {
+		log.Printf("not downloading %s, recording %s", callback.RecordingSid, callback.RecordingStatus)
+
+		return nil
+	}
+
+	// A retried INSERT has the image from before it was archived, so the
+	// item itself is checked too.
+	archived := callback.RecordingKey != ""
+	if !archived {
+		var err error
+		archived, err = deps.archived(ctx, callback)
 		if err != nil {
 			return err
 		}
+	}
+	if archived {
+		log.Printf("not downloading %s, already archived", callback.RecordingSid)
 
-		resp, err := deps.httpClient.Do(request)
-		if err != nil {
-			return err
+		return nil
+	}
+
+	content, err := deps.fetch(ctx, callback)
+	if err != nil {
+		return err
+	}
+
+	metadata := recording.NewMetadata(callback)
+	metadata.SHA256 = recording.Sum(content)
+	format, err := audio.Probe(content)
+	if err != nil {
+		log.Printf("probing %s: %s", callback.RecordingSid, err)
+	}
+	metadata.Empty = deps.empty(callback, content, format)
+	metadata.Codec = format.Codec
+	metadata.SampleRate = format.SampleRate
+	metadata.Channels = format.Channels
+	md5sum := md5.Sum(content)
+	// Dated by the callback, so a retry the next day archives to the same
+	// key. Events from before start times were stored use the clock.
+	started, ok := callback.StartTime()
+	if !ok {
+		started = deps.now()
+	}
+	key := recording.Key(callback.To, started, callback.RecordingSid)
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(deps.bucketName),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(content),
+		ContentType: aws.String(recording.ContentType),
+		// S3 rejects the upload if the bytes it stores don't match.
+		ContentMD5: aws.String(base64.StdEncoding.EncodeToString(md5sum[:])),
+		Metadata:   metadata.Object(),
+		Tagging:    aws.String(metadata.Tagging()),
+	}
+	if deps.kmsKeyID != "" {
+		input.ServerSideEncryption = aws.String(s3.ServerSideEncryptionAwsKms)
+		input.SSEKMSKeyId = aws.String(deps.kmsKeyID)
+	}
+
+	_, err = deps.s3.PutObjectWithContext(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	object, err := deps.s3.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(deps.bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return err
+	}
+	if aws.Int64Value(object.ContentLength) != int64(len(content)) {
+		return fmt.Errorf("archived %d bytes of %s, downloaded %d", aws.Int64Value(object.ContentLength), key, len(content))
+	}
+
+	update := &dynamodb.UpdateItemInput{
+		TableName: aws.String(deps.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"RecordingSid": {
+				S: aws.String(callback.RecordingSid),
+			},
+		},
+		ConditionExpression: aws.String("attribute_exists(RecordingSid) AND attribute_not_exists(RecordingKey)"),
+		UpdateExpression:    aws.String("SET RecordingKey = :key, RecordingEmpty = :empty"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":key": {
+				S: aws.String(key),
+			},
+			":empty": {
+				BOOL: aws.Bool(metadata.Empty),
+			},
+		},
+	}
+	if metadata.Empty {
+		// Adds the hang-up to the digest's sparse index.
+		update.UpdateExpression = aws.String(*update.UpdateExpression + ", DigestPending = :pending")
+		update.ExpressionAttributeValues[":pending"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(started.Unix(), 10))}
+	}
+	if format.Codec != "" {
+		update.UpdateExpression = aws.String(*update.UpdateExpression +
+			", AudioCodec = :codec, AudioSampleRate = :rate, AudioChannels = :channels, AudioDuration = :duration")
+		update.ExpressionAttributeValues[":codec"] = &dynamodb.AttributeValue{S: aws.String(format.Codec)}
+		update.ExpressionAttributeValues[":rate"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(format.SampleRate))}
+		update.ExpressionAttributeValues[":channels"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(format.Channels))}
+		// In seconds, like RecordingDuration, to the millisecond.
+		update.ExpressionAttributeValues[":duration"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatFloat(format.Duration.Seconds(), 'f', 3, 64))}
+	}
+
+	_, err = deps.dynamodb.UpdateItemWithContext(ctx, update)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		// Another attempt stored its key first, and deletes it too.
+		log.Printf("not storing %s, already archived", callback.RecordingSid)
+
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// The recording is archived now. Failing the record would archive it
+	// again, transcribing and emailing it twice, so the deletion can only
+	// be logged.
+	err = deps.deleteArchived(ctx, callback)
+	if err != nil {
+		log.Printf("deleting archived %s from the carrier: %s", callback.RecordingSid, err)
+	}
+
+	return nil
+}
+
+// archived reports whether the recording's key is stored on its webhook
+// data item.
+func (deps *deps) archived(ctx context.Context, callback telephony.RecordingEvent) (bool, error) {
+	result, err := deps.dynamodb.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(deps.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"RecordingSid": {
+				S: aws.String(callback.RecordingSid),
+			},
+		},
+		ConsistentRead:       aws.Bool(true),
+		ProjectionExpression: aws.String("RecordingKey"),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	_, ok := result.Item["RecordingKey"]
+
+	return ok, nil
+}
+
+// deleteArchived deletes an archived recording from the carrier if its
+// mailbox asks for that.
+func (deps *deps) deleteArchived(ctx context.Context, callback telephony.RecordingEvent) error {
+	if deps.deleter == nil {
+		return nil
+	}
+
+	mailbox, err := deps.mailboxes.Get(ctx, callback.To)
+	if err != nil {
+		return err
+	}
+	if !mailbox.DeleteRecordings {
+		return nil
+	}
+
+	return deps.deleteRecording(ctx, callback, 1)
+}
+
+// empty reports whether the recording is a hang-up, too short or quiet to
+// hold a message. Recordings that can't be decoded are judged by the length
+// their headers give, without a level, or transcribed anyway if that's
+// unknown too.
+func (deps *deps) empty(callback telephony.RecordingEvent, content []byte, format audio.Format) bool {
+	analysis, err := audio.AnalyseMP3(content)
+	if err != nil && format.Duration > 0 {
+		// The decoder doesn't take MPEG 2.5, which narrow-band telephony
+		// uses, but the frame headers still give the length.
+		log.Printf("analysing %s: %s, judging by its length of %s alone", callback.RecordingSid, err, format.Duration)
+
+		// A level of 0 dBFS never counts as quiet.
+		return deps.thresholds.Empty(audio.Analysis{Duration: format.Duration})
+	}
+	if err != nil {
+		log.Printf("analysing %s: %s", callback.RecordingSid, err)
+
+		return false
+	}
+
+	empty := deps.thresholds.Empty(analysis)
+	log.Printf("recording %s lasts %s at %.1f dBFS, empty: %t", callback.RecordingSid, analysis.Duration, analysis.Level, empty)
+
+	return empty
+}
+
+// retryDeletion tries again to delete a recording whose last deletion
+// failed, after a backoff, until the attempts run out.
+func (deps *deps) retryDeletion(ctx context.Context, record events.DynamoDBEventRecord, callback telephony.RecordingEvent) error {
+	if deps.deleter == nil {
+		return nil
+	}
+
+	outcome := deletion{}
+	err := stream.UnmarshalImage(record.Change.NewImage, &outcome)
+	if err != nil {
+		return err
+	}
+
+	if outcome.CarrierDeletion != deletionFailed || outcome.CarrierDeletionAttempts >= deps.backoff.attempts {
+		return nil
+	}
+
+	err = deps.backoff.wait(ctx, outcome.CarrierDeletionAttempts)
+	if err != nil {
+		return err
+	}
+
+	return deps.deleteRecording(ctx, callback, outcome.CarrierDeletionAttempts+1)
+}
+
+// deleteRecording deletes the recording from the carrier and records the
+// outcome of the attempt on the webhook data item. Only failing to record
+// it is an error.
+func (deps *deps) deleteRecording(ctx context.Context, callback telephony.RecordingEvent, attempt int) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(deps.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"RecordingSid": {
+				S: aws.String(callback.RecordingSid),
+			},
+		},
+		ConditionExpression: aws.String("attribute_exists(RecordingSid)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":attempts": {
+				N: aws.String(strconv.Itoa(attempt)),
+			},
+		},
+	}
+
+	err := deps.deleter.DeleteRecording(ctx, callback)
+	if err != nil {
+		log.Printf("deleting %s from the carrier, attempt %d: %s", callback.RecordingSid, attempt, err)
+
+		input.UpdateExpression = aws.String("SET CarrierDeletion = :outcome, CarrierDeletionAttempts = :attempts, CarrierDeletionError = :error")
+		input.ExpressionAttributeValues[":outcome"] = &dynamodb.AttributeValue{S: aws.String(deletionFailed)}
+		input.ExpressionAttributeValues[":error"] = &dynamodb.AttributeValue{S: aws.String(err.Error())}
+	} else {
+		input.UpdateExpression = aws.String("SET CarrierDeletion = :outcome, CarrierDeletionAttempts = :attempts REMOVE CarrierDeletionError")
+		input.ExpressionAttributeValues[":outcome"] = &dynamodb.AttributeValue{S: aws.String(deletionDeleted)}
+	}
+
+	_, err = deps.dynamodb.UpdateItemWithContext(ctx, input)
+
+	return err
+}
+
+// fetch downloads the recording, retrying temporary failures with
+// exponential backoff until the attempts or the Lambda's time run out.
+func (deps *deps) fetch(ctx context.Context, callback telephony.RecordingEvent) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		content, err := deps.download(ctx, callback)
+
+		var statusErr *telephony.StatusError
+		if err == nil || attempt >= deps.backoff.attempts || !errors.As(err, &statusErr) || !statusErr.Temporary() {
+			return content, err
 		}
-		defer resp.Body.Close()
 
-		_, err = deps.s3uploader.UploadWithContext(ctx, &s3manager.UploadInput{
-			Bucket: aws.String(deps.bucketName),
-			Key:    aws.String(sid),
-			Body:   resp.Body,
-		})
-		if err != nil {
-			return err
+		log.Printf("downloading %s, attempt %d: %s", callback.RecordingSid, attempt, err)
+
+		if deps.backoff.wait(ctx, attempt) != nil {
+			return nil, err
 		}
 	}
+}
 
-	return nil
+// download reads the whole recording, which is small enough to checksum
+// in memory before it is uploaded.
+func (deps *deps) download(ctx context.Context, callback telephony.RecordingEvent) ([]byte, error) {
+	body, err := deps.provider.FetchRecording(ctx, callback)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return ioutil.ReadAll(body)
+}
+
+// wait sleeps before the retry that follows attempt, doubling the delay
+// each time up to maxDelay.
+func (backoff backoff) wait(ctx context.Context, attempt int) error {
+	delay := backoff.delay
+	for i := 1; i < attempt && delay < backoff.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > backoff.maxDelay {
+		delay = backoff.maxDelay
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay+backoff.reserve {
+		return errOutOfTime
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(delay):
+		return nil
+	}
 }
 
 func main() {
+	provider, err := carrier.FromEnv(&http.Client{})
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	sess := session.Must(session.NewSession())
 	s3client := s3.New(sess)
+	dynamodb := dynamodb.New(sess)
 
 	xray.AWS(s3client.Client)
-
-	s3uploader := s3manager.NewUploaderWithClient(s3client)
+	xray.AWS(dynamodb.Client)
 
 	deps := deps{
-		s3uploader: s3uploader,
-		httpClient: &http.Client{},
+		s3:         s3client,
+		dynamodb:   dynamodb,
+		provider:   provider,
+		mailboxes:  mailbox.NewStore(dynamodb, os.Getenv("MAILBOX_TABLE"), mailbox.Mailbox{}),
 		bucketName: os.Getenv("RECORDING_BUCKET_NAME"),
+		kmsKeyID:   os.Getenv("RECORDING_KMS_KEY_ID"),
+		tableName:  os.Getenv("ANSWERING_MACHINE_WEBHOOK_DATA_TABLE"),
+		now:        time.Now,
+		backoff: backoff{
+			attempts: 4,
+			delay:    500 * time.Millisecond,
+			maxDelay: 4 * time.Second,
+			reserve:  30 * time.Second,
+		},
+	}
+
+	// Recordings shorter than EMPTY_RECORDING_MIN_LENGTH seconds, or quieter
+	// than EMPTY_RECORDING_MIN_LEVEL dBFS, are hang-ups.
+	deps.thresholds = audio.Thresholds{
+		MinDuration: 3 * time.Second,
+		MinLevel:    -50,
+	}
+	if length, err := strconv.Atoi(os.Getenv("EMPTY_RECORDING_MIN_LENGTH")); err == nil {
+		deps.thresholds.MinDuration = time.Duration(length) * time.Second
+	}
+	if level, err := strconv.ParseFloat(os.Getenv("EMPTY_RECORDING_MIN_LEVEL"), 64); err == nil {
+		deps.thresholds.MinLevel = level
+	}
+
+	// Only some carriers let recordings be deleted.
+	if deleter, ok := provider.(telephony.RecordingDeleter); ok {
+		deps.deleter = deleter
 	}
 
 	lambda.Start(deps.handler)
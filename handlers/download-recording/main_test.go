@@ -2,35 +2,138 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/stretchr/testify/assert"
+
+	"answering-machine/internal/audio"
+	"answering-machine/internal/mailbox"
+	"answering-machine/internal/telephony"
+	"answering-machine/internal/twilio"
 )
 
-type mockUploaderAPI struct {
-	s3manageriface.UploaderAPI
+// mockS3API keeps what was put, storing short bytes of each object to
+// simulate a truncated upload.
+type mockS3API struct {
+	s3iface.S3API
+
+	mu    sync.Mutex
+	puts  []*s3.PutObjectInput
+	short int64
+	sizes map[string]int64
+	onPut func()
+}
+
+func (mock *mockS3API) PutObjectWithContext(ctx aws.Context, in *s3.PutObjectInput, opts ...request.Option) (*s3.PutObjectOutput, error) {
+	content, err := ioutil.ReadAll(in.Body)
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if mock.sizes == nil {
+		mock.sizes = map[string]int64{}
+	}
+	mock.sizes[*in.Key] = int64(len(content)) - mock.short
+	mock.puts = append(mock.puts, in)
+	if mock.onPut != nil {
+		mock.onPut()
+	}
+
+	return &s3.PutObjectOutput{}, err
+}
+
+func (mock *mockS3API) HeadObjectWithContext(ctx aws.Context, in *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	for _, put := range mock.puts {
+		if *put.Key == *in.Key {
+			return &s3.HeadObjectOutput{
+				ContentLength: aws.Int64(mock.sizes[*in.Key]),
+				Metadata:      put.Metadata,
+			}, nil
+		}
+	}
+
+	return nil, errors.New("NotFound")
+}
+
+// mockDynamoDBAPI keeps the updates, and reads back the RecordingKey of
+// the recordings in keys. Storing a key fails its condition if it has one.
+type mockDynamoDBAPI struct {
+	dynamodbiface.DynamoDBAPI
+
+	mu      sync.Mutex
+	updates []*dynamodb.UpdateItemInput
+	keys    map[string]string
+}
+
+func (mock *mockDynamoDBAPI) GetItemWithContext(ctx aws.Context, in *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	item := map[string]*dynamodb.AttributeValue{}
+	if key, ok := mock.keys[*in.Key["RecordingSid"].S]; ok {
+		item["RecordingKey"] = &dynamodb.AttributeValue{S: aws.String(key)}
+	}
+
+	return &dynamodb.GetItemOutput{Item: item}, nil
+}
+
+func (mock *mockDynamoDBAPI) UpdateItemWithContext(ctx aws.Context, in *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	mock.updates = append(mock.updates, in)
+
+	if _, ok := mock.keys[*in.Key["RecordingSid"].S]; ok && in.ExpressionAttributeValues[":key"] != nil {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	}
+
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+type mockMailboxStore map[string]mailbox.Mailbox
+
+func (mock mockMailboxStore) Get(ctx context.Context, number string) (mailbox.Mailbox, error) {
+	return mock[number], nil
+}
+
+type failingMailboxStore struct{}
+
+func (mock failingMailboxStore) Get(ctx context.Context, number string) (mailbox.Mailbox, error) {
+	return mailbox.Mailbox{}, errors.New("throttled")
+}
 
-	t            *testing.T
-	expectedIn   s3manager.UploadInput
-	uploadOutput s3manager.UploadOutput
-	mockHits     int
+// mockDeleter fails with each of errs in turn.
+type mockDeleter struct {
+	errs []error
+	hits int
 }
 
-func (mock mockUploaderAPI) Upload(in *s3manager.UploadInput, s3manager ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
-	// assert.Equal(mock.t, mock.expectedIn, *in, "they should be equal")
+func (mock *mockDeleter) DeleteRecording(ctx context.Context, event telephony.RecordingEvent) error {
+	mock.hits++
+	if mock.hits <= len(mock.errs) {
+		return mock.errs[mock.hits-1]
+	}
 
-	return &mock.uploadOutput, nil
+	return nil
 }
 
 type mockHTTPClient struct {
-	httpclientiface
+	telephony.HTTPClient
 
 	t        *testing.T
 	response io.ReadCloser
@@ -39,11 +142,52 @@ type mockHTTPClient struct {
 
 func (mock mockHTTPClient) Do(req *http.Request) (*http.Response, error) {
 	return &http.Response{
-		Body: mock.response,
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": {"audio/mpeg"}},
+		Body:       mock.response,
 	}, nil
 }
 
+// statusHTTPClient answers with each of statusCodes in turn, then audio.
+type statusHTTPClient struct {
+	statusCodes []int
+	hits        int
+}
+
+func (mock *statusHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	mock.hits++
+	if mock.hits <= len(mock.statusCodes) {
+		return &http.Response{
+			StatusCode: mock.statusCodes[mock.hits-1],
+			Header:     http.Header{"Content-Type": {"text/html"}},
+			Body:       ioutil.NopCloser(bytes.NewBufferString("<html>Error</html>")),
+			Request:    req,
+		}, nil
+	}
+
+	return &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": {"audio/mpeg"}},
+		Body:       ioutil.NopCloser(bytes.NewBufferString("audio")),
+		Request:    req,
+	}, nil
+}
+
+type unexpectedHTTPClient struct {
+	t *testing.T
+}
+
+func (mock unexpectedHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	mock.t.Errorf("unexpected request to %s", req.URL)
+
+	return nil, errors.New("unexpected request")
+}
+
 func TestLambdaHandler(t *testing.T) {
+	now := func() time.Time {
+		return time.Date(2026, 10, 19, 10, 15, 0, 0, time.UTC)
+	}
+
 	t.Run("Successful Request", func(t *testing.T) {
 		bucket := "test"
 		recordingSID := "123ABC"
@@ -55,29 +199,30 @@ func TestLambdaHandler(t *testing.T) {
 			response: ioutil.NopCloser(bytes.NewReader([]byte(mockResponse))),
 		}
 
-		mockUploaderAPI := mockUploaderAPI{
-			t:            t,
-			uploadOutput: s3manager.UploadOutput{},
-			expectedIn: s3manager.UploadInput{
-				Bucket: aws.String(bucket),
-				Key:    aws.String(recordingSID),
-				Body:   bytes.NewReader([]byte(mockResponse)),
-			},
-		}
+		s3api := &mockS3API{}
+		db := &mockDynamoDBAPI{}
 
 		deps := deps{
-			s3uploader: mockUploaderAPI,
-			httpClient: mockHTTPClient,
+			s3:         s3api,
+			dynamodb:   db,
+			now:        now,
+			provider:   twilio.NewProvider("", "", mockHTTPClient),
 			bucketName: bucket,
+			kmsKeyID:   "arn:aws:kms:eu-west-2:123456789012:key/recordings",
 		}
 
 		newImage := make(map[string]events.DynamoDBAttributeValue)
 		newImage["RecordingSid"] = events.NewStringAttribute(recordingSID)
 		newImage["RecordingUrl"] = events.NewStringAttribute(recordingURL)
+		newImage["RecordingDuration"] = events.NewNumberAttribute("14")
+		newImage["CallSid"] = events.NewStringAttribute("CA123")
+		newImage["Caller"] = events.NewStringAttribute("+447700900123")
+		newImage["To"] = events.NewStringAttribute("+441632960000")
 
 		err := deps.handler(aws.BackgroundContext(), events.DynamoDBEvent{
 			Records: []events.DynamoDBEventRecord{
 				{
+					EventName: "INSERT",
 					Change: events.DynamoDBStreamRecord{
 						NewImage: newImage,
 					},
@@ -88,5 +233,455 @@ func TestLambdaHandler(t *testing.T) {
 		if err != nil {
 			t.Error("Everything should OK")
 		}
+
+		assert.Len(t, s3api.puts, 1)
+		put := s3api.puts[0]
+		assert.Equal(t, bucket, *put.Bucket)
+		assert.Equal(t, "mailbox/+441632960000/2026/10/19/123ABC.mp3", *put.Key)
+		assert.Equal(t, "audio/mpeg", *put.ContentType)
+		assert.Equal(t, "ZajifYh5KDgxtmS9i38K1A==", *put.ContentMD5)
+		assert.Equal(t, "aws:kms", *put.ServerSideEncryption)
+		assert.Equal(t, deps.kmsKeyID, *put.SSEKMSKeyId)
+		assert.Equal(t, map[string]*string{
+			"recording-sid": aws.String(recordingSID),
+			"call-sid":      aws.String("CA123"),
+			"caller":        aws.String("+447700900123"),
+			"mailbox":       aws.String("+441632960000"),
+			"duration":      aws.String("14"),
+			"sha256":        aws.String("dffd6021bb2bd5b0af676290809ec3a53191dd81c7f70a4b28688a362182986f"),
+		}, put.Metadata)
+		assert.Equal(t, "call-sid=CA123&caller=%2B447700900123&duration=14&mailbox=%2B441632960000&recording-sid=123ABC", *put.Tagging)
+
+		assert.Len(t, db.updates, 1)
+		assert.Equal(t, "SET RecordingKey = :key, RecordingEmpty = :empty", *db.updates[0].UpdateExpression)
+		assert.Equal(t, *put.Key, *db.updates[0].ExpressionAttributeValues[":key"].S)
+		assert.False(t, *db.updates[0].ExpressionAttributeValues[":empty"].BOOL)
+		assert.Nil(t, db.updates[0].ExpressionAttributeValues[":pending"])
+	})
+
+	t.Run("Skips non-INSERT and incomplete records", func(t *testing.T) {
+		deps := deps{
+			dynamodb:   &mockDynamoDBAPI{},
+			provider:   twilio.NewProvider("", "", unexpectedHTTPClient{t: t}),
+			bucketName: "test",
+		}
+
+		newImage := make(map[string]events.DynamoDBAttributeValue)
+		newImage["RecordingSid"] = events.NewStringAttribute("123ABC")
+		newImage["RecordingUrl"] = events.NewStringAttribute("https://example.com/123ABC")
+
+		err := deps.handler(aws.BackgroundContext(), events.DynamoDBEvent{
+			Records: []events.DynamoDBEventRecord{
+				{
+					EventName: "MODIFY",
+					Change: events.DynamoDBStreamRecord{
+						NewImage: newImage,
+					},
+				},
+				{
+					EventName: "REMOVE",
+				},
+				{
+					EventName: "INSERT",
+					Change: events.DynamoDBStreamRecord{
+						NewImage: map[string]events.DynamoDBAttributeValue{
+							"RecordingSid":    events.NewStringAttribute("456DEF"),
+							"RecordingStatus": events.NewStringAttribute("absent"),
+						},
+					},
+				},
+			},
+		})
+
+		assert.NoError(t, err)
+	})
+
+	newEvent := func() events.DynamoDBEvent {
+		return events.DynamoDBEvent{
+			Records: []events.DynamoDBEventRecord{
+				{
+					EventName: "INSERT",
+					Change: events.DynamoDBStreamRecord{
+						NewImage: map[string]events.DynamoDBAttributeValue{
+							"RecordingSid": events.NewStringAttribute("123ABC"),
+							"RecordingUrl": events.NewStringAttribute("https://example.com/123ABC"),
+						},
+					},
+				},
+			},
+		}
+	}
+
+	t.Run("Fails the batch with the records that failed", func(t *testing.T) {
+		s3api := &mockS3API{}
+
+		event := newEvent()
+		event.Records[0].Change.SequenceNumber = "100"
+		event.Records = append(event.Records, events.DynamoDBEventRecord{
+			EventName: "INSERT",
+			Change: events.DynamoDBStreamRecord{
+				SequenceNumber: "200",
+				NewImage: map[string]events.DynamoDBAttributeValue{
+					"RecordingSid":      events.NewStringAttribute("456DEF"),
+					"RecordingDuration": events.NewStringAttribute("fourteen"),
+				},
+			},
+		})
+
+		deps := deps{
+			s3:         s3api,
+			dynamodb:   &mockDynamoDBAPI{},
+			now:        now,
+			provider:   twilio.NewProvider("", "", &statusHTTPClient{}),
+			bucketName: "test",
+		}
+
+		err := deps.handler(aws.BackgroundContext(), event)
+
+		assert.EqualError(t, err, "1 of 2 records failed, the first with: "+
+			"UnmarshalTypeError: cannot unmarshal string into Go value of type int")
+		assert.Len(t, s3api.puts, 1)
+	})
+
+	t.Run("Marks hang-ups as empty", func(t *testing.T) {
+		// Two seconds of MPEG-1 Layer III frames without audio data.
+		frame := make([]byte, 417)
+		copy(frame, []byte{0xFF, 0xFB, 0x90, 0xC0})
+
+		s3api := &mockS3API{}
+		db := &mockDynamoDBAPI{}
+
+		deps := deps{
+			s3:       s3api,
+			dynamodb: db,
+			now:      now,
+			provider: twilio.NewProvider("", "", mockHTTPClient{
+				t:        t,
+				response: ioutil.NopCloser(bytes.NewReader(bytes.Repeat(frame, 77))),
+			}),
+			bucketName: "test",
+			thresholds: audio.Thresholds{MinDuration: 3 * time.Second, MinLevel: -50},
+		}
+
+		err := deps.handler(aws.BackgroundContext(), newEvent())
+
+		assert.NoError(t, err)
+		assert.Equal(t, "true", *s3api.puts[0].Metadata["empty"])
+		assert.True(t, *db.updates[0].ExpressionAttributeValues[":empty"].BOOL)
+		// It waits in the digest's index, by when it was recorded.
+		assert.Contains(t, *db.updates[0].UpdateExpression, "DigestPending = :pending")
+		assert.Equal(t, strconv.FormatInt(now().Unix(), 10), *db.updates[0].ExpressionAttributeValues[":pending"].N)
+
+		// The probed format is stored with it.
+		assert.Equal(t, "mp3", *s3api.puts[0].Metadata["codec"])
+		assert.Equal(t, "44100", *s3api.puts[0].Metadata["sample-rate"])
+		assert.Equal(t, "1", *s3api.puts[0].Metadata["channels"])
+		assert.Contains(t, *db.updates[0].UpdateExpression, "AudioCodec = :codec")
+		assert.Equal(t, "44100", *db.updates[0].ExpressionAttributeValues[":rate"].N)
+		assert.Equal(t, "2.011", *db.updates[0].ExpressionAttributeValues[":duration"].N)
+	})
+
+	t.Run("Marks MPEG 2.5 hang-ups by their length", func(t *testing.T) {
+		// Two seconds of 8kHz MPEG 2.5 Layer III frames, which the decoder
+		// doesn't support.
+		frame := make([]byte, 72)
+		copy(frame, []byte{0xFF, 0xE3, 0x18, 0xC0})
+
+		s3api := &mockS3API{}
+		db := &mockDynamoDBAPI{}
+
+		deps := deps{
+			s3:       s3api,
+			dynamodb: db,
+			now:      now,
+			provider: twilio.NewProvider("", "", mockHTTPClient{
+				t:        t,
+				response: ioutil.NopCloser(bytes.NewReader(bytes.Repeat(frame, 28))),
+			}),
+			bucketName: "test",
+			thresholds: audio.Thresholds{MinDuration: 3 * time.Second, MinLevel: -50},
+		}
+
+		err := deps.handler(aws.BackgroundContext(), newEvent())
+
+		assert.NoError(t, err)
+		assert.Equal(t, "true", *s3api.puts[0].Metadata["empty"])
+		assert.Equal(t, "8000", *s3api.puts[0].Metadata["sample-rate"])
+		assert.True(t, *db.updates[0].ExpressionAttributeValues[":empty"].BOOL)
+	})
+
+	t.Run("Retries until the recording is ready", func(t *testing.T) {
+		httpClient := &statusHTTPClient{statusCodes: []int{404, 503}}
+
+		deps := deps{
+			s3:         &mockS3API{},
+			dynamodb:   &mockDynamoDBAPI{},
+			now:        now,
+			provider:   twilio.NewProvider("", "", httpClient),
+			bucketName: "test",
+			backoff:    backoff{attempts: 4},
+		}
+
+		err := deps.handler(aws.BackgroundContext(), newEvent())
+
+		assert.NoError(t, err)
+		assert.Equal(t, 3, httpClient.hits)
+	})
+
+	t.Run("Gives up after the last attempt", func(t *testing.T) {
+		httpClient := &statusHTTPClient{statusCodes: []int{404, 404, 404, 404}}
+
+		deps := deps{
+			dynamodb:   &mockDynamoDBAPI{},
+			provider:   twilio.NewProvider("", "", httpClient),
+			bucketName: "test",
+			backoff:    backoff{attempts: 3},
+		}
+
+		err := deps.handler(aws.BackgroundContext(), newEvent())
+
+		assert.Error(t, err)
+		assert.Equal(t, 3, httpClient.hits)
+	})
+
+	t.Run("Doesn't retry past the deadline", func(t *testing.T) {
+		httpClient := &statusHTTPClient{statusCodes: []int{503, 503}}
+
+		deps := deps{
+			dynamodb:   &mockDynamoDBAPI{},
+			provider:   twilio.NewProvider("", "", httpClient),
+			bucketName: "test",
+			backoff:    backoff{attempts: 4, delay: time.Second, maxDelay: time.Second, reserve: 30 * time.Second},
+		}
+
+		// Waiting for the retry would leave less than the reserve.
+		ctx, cancel := context.WithTimeout(aws.BackgroundContext(), 30*time.Second)
+		defer cancel()
+		started := time.Now()
+
+		err := deps.handler(ctx, newEvent())
+
+		assert.Error(t, err)
+		assert.Equal(t, 1, httpClient.hits)
+		assert.Less(t, int64(time.Since(started)), int64(time.Second))
+	})
+
+	t.Run("Doesn't retry a rejected download", func(t *testing.T) {
+		httpClient := &statusHTTPClient{statusCodes: []int{401}}
+
+		deps := deps{
+			dynamodb:   &mockDynamoDBAPI{},
+			provider:   twilio.NewProvider("", "", httpClient),
+			bucketName: "test",
+			backoff:    backoff{attempts: 4},
+		}
+
+		err := deps.handler(aws.BackgroundContext(), newEvent())
+
+		assert.Error(t, err)
+		assert.Equal(t, 1, httpClient.hits)
+	})
+
+	t.Run("Deletes archived recordings from the carrier", func(t *testing.T) {
+		db := &mockDynamoDBAPI{}
+		deleter := &mockDeleter{}
+
+		event := newEvent()
+		event.Records[0].Change.NewImage["To"] = events.NewStringAttribute("+447700900999")
+
+		deps := deps{
+			s3:         &mockS3API{},
+			dynamodb:   db,
+			now:        now,
+			provider:   twilio.NewProvider("", "", &statusHTTPClient{}),
+			deleter:    deleter,
+			mailboxes:  mockMailboxStore{"+447700900999": {DeleteRecordings: true}},
+			bucketName: "test",
+			tableName:  "webhook-data",
+		}
+
+		err := deps.handler(aws.BackgroundContext(), event)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, deleter.hits)
+		assert.Len(t, db.updates, 2)
+		assert.Equal(t, "deleted", *db.updates[1].ExpressionAttributeValues[":outcome"].S)
+		assert.Equal(t, "1", *db.updates[1].ExpressionAttributeValues[":attempts"].N)
+	})
+
+	t.Run("Keeps recordings for other mailboxes", func(t *testing.T) {
+		deleter := &mockDeleter{}
+
+		deps := deps{
+			s3:         &mockS3API{},
+			dynamodb:   &mockDynamoDBAPI{},
+			now:        now,
+			provider:   twilio.NewProvider("", "", &statusHTTPClient{}),
+			deleter:    deleter,
+			mailboxes:  mockMailboxStore{},
+			bucketName: "test",
+		}
+
+		err := deps.handler(aws.BackgroundContext(), newEvent())
+
+		assert.NoError(t, err)
+		assert.Equal(t, 0, deleter.hits)
+	})
+
+	t.Run("Dates the key by the recording", func(t *testing.T) {
+		s3api := &mockS3API{}
+
+		event := newEvent()
+		// Recorded just before midnight, retried the next morning.
+		event.Records[0].Change.NewImage["RecordingStartTime"] = events.NewStringAttribute("2026-10-18T23:59:30Z")
+
+		deps := deps{
+			s3:         s3api,
+			dynamodb:   &mockDynamoDBAPI{},
+			now:        now,
+			provider:   twilio.NewProvider("", "", &statusHTTPClient{}),
+			bucketName: "test",
+		}
+
+		err := deps.handler(aws.BackgroundContext(), event)
+
+		assert.NoError(t, err)
+		assert.Len(t, s3api.puts, 1)
+		assert.Equal(t, "mailbox/unknown/2026/10/18/123ABC.mp3", *s3api.puts[0].Key)
+	})
+
+	t.Run("Doesn't fail an archived recording", func(t *testing.T) {
+		s3api := &mockS3API{}
+		db := &mockDynamoDBAPI{}
+		deleter := &mockDeleter{}
+
+		deps := deps{
+			s3:         s3api,
+			dynamodb:   db,
+			now:        now,
+			provider:   twilio.NewProvider("", "", &statusHTTPClient{}),
+			deleter:    deleter,
+			mailboxes:  failingMailboxStore{},
+			bucketName: "test",
+		}
+
+		err := deps.handler(aws.BackgroundContext(), newEvent())
+
+		assert.NoError(t, err)
+		assert.Len(t, s3api.puts, 1)
+		assert.Len(t, db.updates, 1)
+		assert.Equal(t, 0, deleter.hits)
+	})
+
+	t.Run("Skips a recording archived before a retry", func(t *testing.T) {
+		s3api := &mockS3API{}
+		deleter := &mockDeleter{}
+
+		deps := deps{
+			s3: s3api,
+			dynamodb: &mockDynamoDBAPI{
+				keys: map[string]string{"123ABC": "mailbox/unknown/2026/10/19/123ABC.mp3"},
+			},
+			now:        now,
+			provider:   twilio.NewProvider("", "", unexpectedHTTPClient{t: t}),
+			deleter:    deleter,
+			mailboxes:  mockMailboxStore{"": {DeleteRecordings: true}},
+			bucketName: "test",
+		}
+
+		err := deps.handler(aws.BackgroundContext(), newEvent())
+
+		assert.NoError(t, err)
+		assert.Len(t, s3api.puts, 0)
+		assert.Equal(t, 0, deleter.hits)
+	})
+
+	t.Run("Leaves the recording to an attempt that stored it first", func(t *testing.T) {
+		s3api := &mockS3API{}
+		db := &mockDynamoDBAPI{}
+		deleter := &mockDeleter{}
+
+		deps := deps{
+			s3:         s3api,
+			dynamodb:   db,
+			now:        now,
+			provider:   twilio.NewProvider("", "", &statusHTTPClient{}),
+			deleter:    deleter,
+			mailboxes:  mockMailboxStore{"": {DeleteRecordings: true}},
+			bucketName: "test",
+		}
+		// Stored while this attempt was uploading.
+		s3api.onPut = func() {
+			db.keys = map[string]string{"123ABC": "mailbox/unknown/2026/10/19/123ABC.mp3"}
+		}
+
+		err := deps.handler(aws.BackgroundContext(), newEvent())
+
+		assert.NoError(t, err)
+		assert.Len(t, s3api.puts, 1)
+		assert.Equal(t, "attribute_exists(RecordingSid) AND attribute_not_exists(RecordingKey)", *db.updates[0].ConditionExpression)
+		assert.Equal(t, 0, deleter.hits)
+	})
+
+	t.Run("Doesn't delete an incomplete upload", func(t *testing.T) {
+		deleter := &mockDeleter{}
+
+		deps := deps{
+			s3:         &mockS3API{short: 2},
+			dynamodb:   &mockDynamoDBAPI{},
+			now:        now,
+			provider:   twilio.NewProvider("", "", &statusHTTPClient{}),
+			deleter:    deleter,
+			mailboxes:  mockMailboxStore{"": {DeleteRecordings: true}},
+			bucketName: "test",
+		}
+
+		err := deps.handler(aws.BackgroundContext(), newEvent())
+
+		assert.Error(t, err)
+		assert.Equal(t, 0, deleter.hits)
+	})
+
+	t.Run("Records a failed deletion and retries it", func(t *testing.T) {
+		db := &mockDynamoDBAPI{}
+		deleter := &mockDeleter{errs: []error{errors.New("503 Service Unavailable")}}
+
+		deps := deps{
+			s3:         &mockS3API{},
+			dynamodb:   db,
+			now:        now,
+			provider:   twilio.NewProvider("", "", &statusHTTPClient{}),
+			deleter:    deleter,
+			mailboxes:  mockMailboxStore{"": {DeleteRecordings: true}},
+			bucketName: "test",
+			backoff:    backoff{attempts: 3},
+		}
+
+		err := deps.handler(aws.BackgroundContext(), newEvent())
+
+		assert.NoError(t, err)
+		assert.Len(t, db.updates, 2)
+		assert.Equal(t, "failed", *db.updates[1].ExpressionAttributeValues[":outcome"].S)
+		assert.Equal(t, "503 Service Unavailable", *db.updates[1].ExpressionAttributeValues[":error"].S)
+
+		modified := newEvent()
+		modified.Records[0].EventName = "MODIFY"
+		modified.Records[0].Change.NewImage["CarrierDeletion"] = events.NewStringAttribute("failed")
+		modified.Records[0].Change.NewImage["CarrierDeletionAttempts"] = events.NewNumberAttribute("1")
+
+		err = deps.handler(aws.BackgroundContext(), modified)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, deleter.hits)
+		assert.Len(t, db.updates, 3)
+		assert.Equal(t, "deleted", *db.updates[2].ExpressionAttributeValues[":outcome"].S)
+		assert.Equal(t, "2", *db.updates[2].ExpressionAttributeValues[":attempts"].N)
+
+		modified.Records[0].Change.NewImage["CarrierDeletionAttempts"] = events.NewNumberAttribute("3")
+
+		err = deps.handler(aws.BackgroundContext(), modified)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, deleter.hits)
 	})
 }
@@ -1,130 +1,300 @@
 package main
 
 import (
-	"encoding/base64"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
-	"strings"
+	"time"
 
-	speech "cloud.google.com/go/speech/apiv1p1beta1"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	lambdaservice "github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
 	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-xray-sdk-go/xray"
-	"golang.org/x/net/context"
-	speechpb "google.golang.org/genproto/googleapis/cloud/speech/v1p1beta1"
+
+	"answering-machine/internal/audio"
+	"answering-machine/internal/mailbox"
+	"answering-machine/internal/recording"
+	"answering-machine/internal/telephony"
+	"answering-machine/internal/transcriber"
+	"answering-machine/internal/transcription"
 )
 
+// handoffMargin is how long before the function times out it stops waiting
+// for a transcription and hands it off to another invocation.
+const handoffMargin = 15 * time.Second
+
+// maxHandoffs limits how many invocations wait for one transcription.
+const maxHandoffs = 10
+
 type deps struct {
 	dynamodb               dynamodbiface.DynamoDBAPI
-	s3                     s3manageriface.DownloaderAPI
+	s3                     s3iface.S3API
+	lambda                 lambdaiface.LambdaAPI
+	transcriber            transcription.Transcriber
 	transcriptionTableName string
-	googleAuthJSONBase64   string
+	answeringMachineTable  string
+	mailboxes              mailboxStore
+	functionName           string
 }
 
-func (deps *deps) handler(ctx context.Context, s3Event events.S3Event) error {
-	authFile, err := os.Create("/tmp/auth.json")
-	if err != nil {
-		log.Fatal(err)
-	}
+// invocation is either an S3 event for new recordings or a handoff from an
+// invocation that ran out of time waiting for a transcription.
+type invocation struct {
+	Records []events.S3EventRecord
+	Handoff *handoff `json:",omitempty"`
+}
 
-	authString, err := base64.StdEncoding.DecodeString(deps.googleAuthJSONBase64)
-	if err != nil {
-		log.Fatal(err)
-	}
+// handoff carries on waiting for the transcription of Key.
+type handoff struct {
+	Bucket    string
+	Key       string
+	Operation string
+	Attempt   int
+}
 
-	_, err = authFile.WriteString(string(authString))
-	if err != nil {
-		log.Fatal(err)
-	}
+type mailboxStore interface {
+	Get(ctx context.Context, number string) (mailbox.Mailbox, error)
+}
 
-	client, err := speech.NewClient(ctx)
-	if err != nil {
-		log.Fatal(err)
+// handler transcribes each new recording in the mailbox's language with
+// the configured backend, storing the transcript for the email handler.
+// Transcriptions still running shortly before the function times out are
+// handed off to a new invocation.
+func (deps *deps) handler(ctx context.Context, event invocation) error {
+	budget := ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		budget, cancel = context.WithDeadline(ctx, deadline.Add(-handoffMargin))
+		defer cancel()
 	}
 
-	for _, record := range s3Event.Records {
-		recordingSID := strings.Split(record.S3.Object.Key, ".")[0]
-		recordingFilePath := fmt.Sprintf("/tmp/%s.mp3", recordingSID)
+	if event.Handoff != nil {
+		return deps.transcribe(ctx, budget, *event.Handoff)
+	}
 
-		recordingFile, err := os.Create(recordingFilePath)
+	for _, record := range event.Records {
+		// Keys in S3 events are URL encoded, + for a space and %2B for +.
+		key, err := url.QueryUnescape(record.S3.Object.Key)
 		if err != nil {
 			return err
 		}
 
-		_, err = deps.s3.DownloadWithContext(ctx, recordingFile, &s3.GetObjectInput{
-			Bucket: aws.String(record.S3.Bucket.Name),
-			Key:    aws.String(record.S3.Object.Key),
-		})
+		err = deps.transcribe(ctx, budget, handoff{Bucket: record.S3.Bucket.Name, Key: key})
 		if err != nil {
 			return err
 		}
+	}
 
-		audioData, err := ioutil.ReadFile(recordingFilePath)
-		if err != nil {
-			log.Fatal(err)
-		}
+	return nil
+}
 
-		googleSpeechResponse, err := client.Recognize(ctx, &speechpb.RecognizeRequest{
-			Config: &speechpb.RecognitionConfig{
-				Encoding:        speechpb.RecognitionConfig_MP3,
-				SampleRateHertz: 22000,
-				LanguageCode:    "en-US",
-			},
-			Audio: &speechpb.RecognitionAudio{
-				AudioSource: &speechpb.RecognitionAudio_Content{Content: audioData},
-			},
-		})
-		if err != nil {
-			log.Fatal(err)
-		}
+// transcribe waits for the transcription until budget is done, then hands
+// it off with ctx, which has a little longer.
+func (deps *deps) transcribe(ctx, budget context.Context, work handoff) error {
+	bucket, key := work.Bucket, work.Key
+	recordingSID := recording.SidFromKey(key)
 
-		params := make(map[string]string)
-		params["Transcription"] = googleSpeechResponse.Results[0].Alternatives[0].Transcript
-		params["RecordingSid"] = recordingSID
+	object, err := deps.s3.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return err
+	}
 
-		attributeValues, err := dynamodbattribute.MarshalMap(params)
-		if err != nil {
-			return err
-		}
+	audioData, err := ioutil.ReadAll(object.Body)
+	object.Body.Close()
+	if err != nil {
+		return err
+	}
 
-		_, err = deps.dynamodb.PutItemWithContext(ctx, &dynamodb.PutItemInput{
-			Item:      attributeValues,
-			TableName: aws.String(deps.transcriptionTableName),
-		})
+	metadata, ok := recording.ParseMetadata(object.Metadata)
+	if !ok {
+		metadata, err = deps.callMetadata(ctx, recordingSID)
 		if err != nil {
 			return err
 		}
 	}
+	if metadata.CallSid == "" {
+		log.Printf("not transcribing %s, it isn't a recording", key)
+
+		return nil
+	}
+	// Hang-ups are only listed in the digest.
+	if metadata.Empty {
+		log.Printf("not transcribing %s, it is empty", key)
+
+		return nil
+	}
+
+	err = metadata.Verify(audioData)
+	if err != nil {
+		return err
+	}
+
+	mailbox, err := deps.mailboxes.Get(ctx, metadata.Mailbox)
+	if err != nil {
+		return err
+	}
+
+	// Audio that can't be probed is left for the backend to reject.
+	format, err := audio.Probe(audioData)
+	if err != nil {
+		log.Printf("probing %s: %s", key, err)
+	}
+
+	duration := time.Duration(metadata.Duration) * time.Second
+	if duration == 0 {
+		duration = format.Duration
+	}
+
+	log.Printf("transcribing %s, %s from %s in %s", recordingSID, duration, metadata.Caller, mailbox.Language)
+
+	transcript, err := deps.transcriber.Transcribe(budget, transcription.Request{
+		Bucket:               bucket,
+		Key:                  key,
+		Audio:                audioData,
+		Language:             mailbox.Language,
+		AlternativeLanguages: mailbox.AlternativeLanguages,
+		Duration:             duration,
+		Format:               format,
+		Operation:            work.Operation,
+	})
+	var pending *transcription.PendingError
+	if errors.As(err, &pending) {
+		work.Operation = pending.Operation
+		work.Attempt++
+
+		return deps.handOff(ctx, work)
+	}
+	if err != nil {
+		return err
+	}
+
+	text := transcript.Text
+	if text == "" {
+		text = "(No speech was recognised.)"
+	}
+
+	params := make(map[string]string)
+	params["Transcription"] = text
+	params["Transcriber"] = transcript.Backend
+	params["Language"] = transcript.Language
+	params["RecordingSid"] = recordingSID
+	params["RecordingKey"] = key
+	params["CallSid"] = metadata.CallSid
+
+	attributeValues, err := dynamodbattribute.MarshalMap(params)
+	if err != nil {
+		return err
+	}
+
+	// The email only needs Transcription, the segments keep each
+	// utterance's confidence and word timings.
+	attributeValues["Segments"], err = dynamodbattribute.Marshal(transcript.Segments)
+	if err != nil {
+		return err
+	}
+
+	_, err = deps.dynamodb.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		Item:      attributeValues,
+		TableName: aws.String(deps.transcriptionTableName),
+	})
 
 	return err
 }
 
+// handOff invokes the function again to carry on waiting for work.
+func (deps *deps) handOff(ctx context.Context, work handoff) error {
+	if work.Attempt > maxHandoffs {
+		return fmt.Errorf("transcription %s of %s didn't finish after %d invocations", work.Operation, work.Key, maxHandoffs)
+	}
+
+	log.Printf("handing off transcription %s of %s, attempt %d", work.Operation, work.Key, work.Attempt)
+
+	payload, err := json.Marshal(invocation{Handoff: &work})
+	if err != nil {
+		return err
+	}
+
+	_, err = deps.lambda.InvokeWithContext(ctx, &lambdaservice.InvokeInput{
+		FunctionName:   aws.String(deps.functionName),
+		InvocationType: aws.String(lambdaservice.InvocationTypeEvent),
+		Payload:        payload,
+	})
+
+	return err
+}
+
+// callMetadata looks up the call of a recording archived without its
+// metadata in the webhook data table.
+func (deps *deps) callMetadata(ctx context.Context, recordingSID string) (recording.Metadata, error) {
+	result, err := deps.dynamodb.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(deps.answeringMachineTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"RecordingSid": {
+				S: aws.String(recordingSID),
+			},
+		},
+	})
+	if err != nil {
+		return recording.Metadata{}, err
+	}
+
+	callback := telephony.RecordingEvent{}
+	err = dynamodbattribute.UnmarshalMap(result.Item, &callback)
+	if err != nil {
+		return recording.Metadata{}, err
+	}
+
+	return recording.NewMetadata(callback), nil
+}
+
 func main() {
 	sess := session.Must(session.NewSession())
 
 	dynamodb := dynamodb.New(sess)
 	s3client := s3.New(sess)
+	lambdaClient := lambdaservice.New(sess)
 
 	xray.AWS(dynamodb.Client)
 	xray.AWS(s3client.Client)
+	xray.AWS(lambdaClient.Client)
 
-	s3downloader := s3manager.NewDownloaderWithClient(s3client)
+	backend, err := transcriber.FromEnv(context.Background(), sess, &http.Client{})
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	deps := deps{
 		dynamodb:               dynamodb,
-		s3:                     s3downloader,
+		s3:                     s3client,
+		lambda:                 lambdaClient,
+		transcriber:            backend,
 		transcriptionTableName: os.Getenv("ANSWERING_MACHINE_TRANSCRIPTON_TABLE"),
-		googleAuthJSONBase64:   os.Getenv("GOOGLE_AUTH_JSON_B64"),
+		answeringMachineTable:  os.Getenv("ANSWERING_MACHINE_WEBHOOK_DATA_TABLE"),
+		// ALTERNATIVE_LANGUAGES lists the other languages callers to mailboxes
+		// without their own may speak, as "cy-GB,pl-PL,es-ES".
+		mailboxes: mailbox.NewStore(dynamodb, os.Getenv("MAILBOX_TABLE"), mailbox.Mailbox{
+			Language:             "en-US",
+			AlternativeLanguages: mailbox.ParseLanguages(os.Getenv("ALTERNATIVE_LANGUAGES")),
+		}),
+		functionName: lambdacontext.FunctionName,
 	}
 
 	lambda.Start(deps.handler)
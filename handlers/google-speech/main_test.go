@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/stretchr/testify/assert"
+
+	"answering-machine/internal/mailbox"
+	"answering-machine/internal/recording"
+	"answering-machine/internal/transcription"
+)
+
+type mockS3API struct {
+	s3iface.S3API
+
+	metadata map[string]*string
+}
+
+func (mock *mockS3API) GetObjectWithContext(ctx aws.Context, in *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	return &s3.GetObjectOutput{
+		Body:     ioutil.NopCloser(bytes.NewBufferString("audio")),
+		Metadata: mock.metadata,
+	}, nil
+}
+
+type mockDynamoDBAPI struct {
+	dynamodbiface.DynamoDBAPI
+
+	puts []*dynamodb.PutItemInput
+}
+
+func (mock *mockDynamoDBAPI) PutItemWithContext(ctx aws.Context, in *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	mock.puts = append(mock.puts, in)
+
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+type mockMailboxStore map[string]mailbox.Mailbox
+
+func (mock mockMailboxStore) Get(ctx context.Context, number string) (mailbox.Mailbox, error) {
+	return mock[number], nil
+}
+
+type mockLambdaAPI struct {
+	lambdaiface.LambdaAPI
+
+	invocations []*lambda.InvokeInput
+}
+
+func (mock *mockLambdaAPI) InvokeWithContext(ctx aws.Context, in *lambda.InvokeInput, opts ...request.Option) (*lambda.InvokeOutput, error) {
+	mock.invocations = append(mock.invocations, in)
+
+	return &lambda.InvokeOutput{}, nil
+}
+
+// pendingTranscriber never finishes within the context.
+type pendingTranscriber struct {
+	requests []transcription.Request
+}
+
+func (mock *pendingTranscriber) Transcribe(ctx context.Context, request transcription.Request) (transcription.Transcript, error) {
+	mock.requests = append(mock.requests, request)
+	<-ctx.Done()
+
+	return transcription.Transcript{}, &transcription.PendingError{Operation: "operation-1"}
+}
+
+func newEvent(key string) invocation {
+	return invocation{
+		Records: []events.S3EventRecord{
+			{
+				S3: events.S3Entity{
+					Bucket: events.S3Bucket{Name: "recordings"},
+					Object: events.S3Object{Key: key},
+				},
+			},
+		},
+	}
+}
+
+func TestLambdaHandler(t *testing.T) {
+	metadata := recording.Metadata{
+		RecordingSid: "RE123",
+		CallSid:      "CA123",
+		Mailbox:      "+441632960000",
+		Duration:     90,
+		SHA256:       recording.Sum([]byte("audio")),
+	}
+
+	t.Run("Stores the transcript", func(t *testing.T) {
+		db := &mockDynamoDBAPI{}
+
+		deps := deps{
+			dynamodb:               db,
+			s3:                     &mockS3API{metadata: metadata.Object()},
+			transcriber:            transcription.Fake{},
+			transcriptionTableName: "transcriptions",
+			mailboxes:              mockMailboxStore{"+441632960000": {Language: "cy-GB"}},
+		}
+
+		err := deps.handler(context.Background(), newEvent("mailbox/%2B441632960000/2026/10/19/RE123.mp3"))
+
+		assert.NoError(t, err)
+		assert.Len(t, db.puts, 1)
+		item := db.puts[0].Item
+		assert.Equal(t, "Transcript of RE123.mp3 in cy-GB.", *item["Transcription"].S)
+		assert.Equal(t, "fake", *item["Transcriber"].S)
+		assert.Equal(t, "cy-GB", *item["Language"].S)
+		assert.Equal(t, "RE123", *item["RecordingSid"].S)
+		assert.Equal(t, "mailbox/+441632960000/2026/10/19/RE123.mp3", *item["RecordingKey"].S)
+		assert.Equal(t, "CA123", *item["CallSid"].S)
+
+		var segments []transcription.Segment
+		assert.NoError(t, dynamodbattribute.Unmarshal(item["Segments"], &segments))
+		assert.Equal(t, []transcription.Segment{{Text: "Transcript of RE123.mp3 in cy-GB.", Confidence: 1}}, segments)
+	})
+
+	t.Run("Skips hang-ups", func(t *testing.T) {
+		db := &mockDynamoDBAPI{}
+		empty := metadata
+		empty.Empty = true
+
+		deps := deps{
+			dynamodb:    db,
+			s3:          &mockS3API{metadata: empty.Object()},
+			transcriber: transcription.Fake{},
+			mailboxes:   mockMailboxStore{},
+		}
+
+		err := deps.handler(context.Background(), newEvent("mailbox/%2B441632960000/2026/10/19/RE123.mp3"))
+
+		assert.NoError(t, err)
+		assert.Empty(t, db.puts)
+	})
+	t.Run("Hands off transcriptions still running", func(t *testing.T) {
+		db := &mockDynamoDBAPI{}
+		invoker := &mockLambdaAPI{}
+		backend := &pendingTranscriber{}
+
+		deps := deps{
+			dynamodb:     db,
+			s3:           &mockS3API{metadata: metadata.Object()},
+			lambda:       invoker,
+			transcriber:  backend,
+			mailboxes:    mockMailboxStore{},
+			functionName: "answering-machine-google-speech",
+		}
+
+		// Only just long enough to hand off.
+		ctx, cancel := context.WithTimeout(context.Background(), handoffMargin+10*time.Millisecond)
+		defer cancel()
+
+		err := deps.handler(ctx, newEvent("mailbox/%2B441632960000/2026/10/19/RE123.mp3"))
+
+		assert.NoError(t, err)
+		assert.Empty(t, db.puts)
+		assert.Equal(t, 90*time.Second, backend.requests[0].Duration)
+		assert.Len(t, invoker.invocations, 1)
+		assert.Equal(t, "answering-machine-google-speech", *invoker.invocations[0].FunctionName)
+		assert.Equal(t, "Event", *invoker.invocations[0].InvocationType)
+
+		var next invocation
+		assert.NoError(t, json.Unmarshal(invoker.invocations[0].Payload, &next))
+		assert.Equal(t, &handoff{
+			Bucket:    "recordings",
+			Key:       "mailbox/+441632960000/2026/10/19/RE123.mp3",
+			Operation: "operation-1",
+			Attempt:   1,
+		}, next.Handoff)
+
+		ctx, cancel = context.WithTimeout(context.Background(), handoffMargin+10*time.Millisecond)
+		defer cancel()
+
+		err = deps.handler(ctx, next)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "operation-1", backend.requests[1].Operation)
+		assert.Len(t, invoker.invocations, 2)
+
+		next.Handoff.Attempt = maxHandoffs
+		ctx, cancel = context.WithTimeout(context.Background(), handoffMargin+10*time.Millisecond)
+		defer cancel()
+
+		err = deps.handler(ctx, next)
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/aws/aws-sdk-go/service/ses/sesiface"
+	"github.com/aws/aws-xray-sdk-go/xray"
+
+	"answering-machine/internal/mailbox"
+	"answering-machine/internal/telephony"
+)
+
+type deps struct {
+	ses       sesiface.SESAPI
+	dynamodb  dynamodbiface.DynamoDBAPI
+	mailboxes mailboxStore
+	fromEmail string
+	tableName string
+}
+
+type mailboxStore interface {
+	Get(ctx context.Context, number string) (mailbox.Mailbox, error)
+}
+
+// digestIndex is the sparse index of hang-ups not yet in a digest, those
+// with DigestPending set.
+const digestIndex = "DigestPending"
+
+// handler runs daily, sending each mailbox that wants one a digest of the
+// hang-ups since the last. Every hang-up is then marked as sent and leaves
+// the digest index, so the next digest only has new ones.
+func (deps *deps) handler(ctx context.Context) error {
+	hangUps := map[string][]telephony.RecordingEvent{}
+
+	var unmarshalErr error
+	err := deps.dynamodb.ScanPagesWithContext(ctx, &dynamodb.ScanInput{
+		TableName: aws.String(deps.tableName),
+		IndexName: aws.String(digestIndex),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			callback := telephony.RecordingEvent{}
+			unmarshalErr = dynamodbattribute.UnmarshalMap(item, &callback)
+			if unmarshalErr != nil {
+				return false
+			}
+			hangUps[callback.To] = append(hangUps[callback.To], callback)
+		}
+
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if unmarshalErr != nil {
+		return unmarshalErr
+	}
+
+	numbers := make([]string, 0, len(hangUps))
+	for number := range hangUps {
+		numbers = append(numbers, number)
+	}
+	sort.Strings(numbers)
+
+	for _, number := range numbers {
+		err = deps.sendDigest(ctx, number, hangUps[number])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sendDigest lists the hang-ups to number, oldest first, if its mailbox
+// asks for that.
+func (deps *deps) sendDigest(ctx context.Context, number string, hangUps []telephony.RecordingEvent) error {
+	settings, err := deps.mailboxes.Get(ctx, number)
+	if err != nil {
+		return err
+	}
+
+	recipients := settings.Recipients("")
+	if settings.HangUps == mailbox.HangUpsDigest && len(recipients) > 0 {
+		sort.Slice(hangUps, func(i, j int) bool {
+			if hangUps[i].DigestPending != hangUps[j].DigestPending {
+				return hangUps[i].DigestPending < hangUps[j].DigestPending
+			}
+
+			return hangUps[i].RecordingSid < hangUps[j].RecordingSid
+		})
+
+		lines := make([]string, 0, len(hangUps))
+		for _, hangUp := range hangUps {
+			lines = append(lines, fmt.Sprintf("Hang-up from %s (%ds)", hangUp.CallerNumber(), hangUp.RecordingDuration))
+		}
+
+		subject := fmt.Sprintf("%d hang-ups", len(hangUps))
+		if len(hangUps) == 1 {
+			subject = "1 hang-up"
+		}
+
+		log.Printf("sending %s a digest of %d hang-ups", number, len(hangUps))
+
+		_, err = deps.ses.SendEmailWithContext(ctx, &ses.SendEmailInput{
+			Source: aws.String(deps.fromEmail),
+			Destination: &ses.Destination{
+				ToAddresses: aws.StringSlice(recipients),
+			},
+			Message: &ses.Message{
+				Subject: &ses.Content{
+					Charset: aws.String("UTF-8"),
+					Data:    aws.String(subject),
+				},
+				Body: &ses.Body{
+					Text: &ses.Content{
+						Charset: aws.String("UTF-8"),
+						Data:    aws.String(strings.Join(lines, "\n")),
+					},
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, hangUp := range hangUps {
+		_, err = deps.dynamodb.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+			TableName: aws.String(deps.tableName),
+			Key: map[string]*dynamodb.AttributeValue{
+				"RecordingSid": {
+					S: aws.String(hangUp.RecordingSid),
+				},
+			},
+			ConditionExpression: aws.String("attribute_exists(RecordingSid)"),
+			UpdateExpression:    aws.String("SET DigestSent = :sent REMOVE DigestPending"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":sent": {
+					BOOL: aws.Bool(true),
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func main() {
+	sess := session.Must(session.NewSession())
+
+	ses := ses.New(sess)
+	dynamodb := dynamodb.New(sess)
+
+	xray.AWS(dynamodb.Client)
+
+	// Numbers without a mailbox are sent to TO_EMAIL, from FROM_EMAIL if
+	// set, and get a digest when HANG_UPS is "digest".
+	toEmail := os.Getenv("TO_EMAIL")
+	fromEmail := os.Getenv("FROM_EMAIL")
+	if fromEmail == "" {
+		fromEmail = toEmail
+	}
+
+	deps := deps{
+		ses:      ses,
+		dynamodb: dynamodb,
+		mailboxes: mailbox.NewStore(dynamodb, os.Getenv("MAILBOX_TABLE"), mailbox.Mailbox{
+			Email:   toEmail,
+			HangUps: os.Getenv("HANG_UPS"),
+		}),
+		fromEmail: fromEmail,
+		tableName: os.Getenv("ANSWERING_MACHINE_WEBHOOK_DATA_TABLE"),
+	}
+
+	lambda.Start(deps.handler)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/aws/aws-sdk-go/service/ses/sesiface"
+	"github.com/stretchr/testify/assert"
+
+	"answering-machine/internal/mailbox"
+	"answering-machine/internal/telephony"
+)
+
+type mockDynamoDBAPI struct {
+	dynamodbiface.DynamoDBAPI
+
+	items   []telephony.RecordingEvent
+	scans   []*dynamodb.ScanInput
+	updates []*dynamodb.UpdateItemInput
+}
+
+func (mock *mockDynamoDBAPI) ScanPagesWithContext(ctx aws.Context, in *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput, bool) bool, opts ...request.Option) error {
+	mock.scans = append(mock.scans, in)
+
+	page := &dynamodb.ScanOutput{}
+	for _, callback := range mock.items {
+		item, err := dynamodbattribute.MarshalMap(callback)
+		if err != nil {
+			return err
+		}
+		page.Items = append(page.Items, item)
+	}
+	fn(page, true)
+
+	return nil
+}
+
+func (mock *mockDynamoDBAPI) UpdateItemWithContext(ctx aws.Context, in *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	mock.updates = append(mock.updates, in)
+
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+type mockSESAPI struct {
+	sesiface.SESAPI
+
+	sent []*ses.SendEmailInput
+}
+
+func (mock *mockSESAPI) SendEmailWithContext(ctx aws.Context, in *ses.SendEmailInput, opts ...request.Option) (*ses.SendEmailOutput, error) {
+	mock.sent = append(mock.sent, in)
+
+	return &ses.SendEmailOutput{}, nil
+}
+
+type mockMailboxStore map[string]mailbox.Mailbox
+
+func (mock mockMailboxStore) Get(ctx context.Context, number string) (mailbox.Mailbox, error) {
+	return mock[number], nil
+}
+
+func TestLambdaHandler(t *testing.T) {
+	db := &mockDynamoDBAPI{
+		items: []telephony.RecordingEvent{
+			// Recording SIDs don't sort by time, so the digest goes by
+			// when each started.
+			{RecordingSid: "RE1", To: "+441632960000", Caller: "+447700900002", RecordingDuration: 1, DigestPending: 1792400100},
+			{RecordingSid: "RE2", To: "+441632960000", Caller: "+447700900001", RecordingDuration: 2, DigestPending: 1792400000},
+			{RecordingSid: "RE3", To: "+441632960001", Caller: "+447700900003", RecordingDuration: 2, DigestPending: 1792400200},
+		},
+	}
+	sesAPI := &mockSESAPI{}
+
+	deps := deps{
+		ses:      sesAPI,
+		dynamodb: db,
+		mailboxes: mockMailboxStore{
+			"+441632960000": {Email: "alice@example.com", HangUps: mailbox.HangUpsDigest},
+			"+441632960001": {Email: "bob@example.com", HangUps: mailbox.HangUpsIgnore},
+		},
+		fromEmail: "machine@example.com",
+		tableName: "webhook-data",
+	}
+
+	err := deps.handler(context.Background())
+
+	assert.NoError(t, err)
+	assert.Len(t, sesAPI.sent, 1)
+	assert.Equal(t, []*string{aws.String("alice@example.com")}, sesAPI.sent[0].Destination.ToAddresses)
+	assert.Equal(t, "2 hang-ups", *sesAPI.sent[0].Message.Subject.Data)
+	assert.Equal(t, "Hang-up from +447700900001 (2s)\nHang-up from +447700900002 (1s)", *sesAPI.sent[0].Message.Body.Text.Data)
+	assert.Equal(t, "DigestPending", *db.scans[0].IndexName)
+	assert.Len(t, db.updates, 3)
+	assert.Equal(t, "SET DigestSent = :sent REMOVE DigestPending", *db.updates[0].UpdateExpression)
+}
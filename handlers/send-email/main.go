@@ -10,112 +10,350 @@ import (
 	"mime/multipart"
 	"net/textproto"
 	"os"
+	"path"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/ses"
 	"github.com/aws/aws-sdk-go/service/ses/sesiface"
 	"github.com/aws/aws-xray-sdk-go/xray"
+
+	"answering-machine/internal/mailbox"
+	"answering-machine/internal/recording"
+	"answering-machine/internal/stream"
+	"answering-machine/internal/telephony"
 )
 
 type deps struct {
 	ses                   sesiface.SESAPI
 	dynamodb              dynamodbiface.DynamoDBAPI
-	s3                    s3manageriface.DownloadWithIterator
-	toEmail               string
+	s3                    s3iface.S3API
+	mailboxes             mailboxStore
+	fromEmail             string
 	answeringMachineTable string
 	recordingBucket       string
 }
 
-type webhookData struct {
-	RecordingSid string
-	Caller       string
+type mailboxStore interface {
+	Get(ctx context.Context, number string) (mailbox.Mailbox, error)
 }
 
+// handler receives transcription table records, which are voicemails ready
+// to send, webhook data table records, where only the calls that ended
+// without a recording need a notification, and messages table records.
 func (deps *deps) handler(ctx context.Context, ddbEvent events.DynamoDBEvent) error {
-	for _, record := range ddbEvent.Records {
-		recordingSID := record.Change.NewImage["RecordingSid"].String()
-		transcription := record.Change.NewImage["Transcription"].String()
+	processor := stream.Processor{
+		Workers: 4,
+	}
 
-		log.Printf("recordingSID: %s", recordingSID)
+	return processor.Process(ctx, ddbEvent, deps.send)
+}
 
-		recordingFilePath := fmt.Sprintf("/tmp/%s.mp3", recordingSID)
+func (deps *deps) send(ctx context.Context, record events.DynamoDBEventRecord) error {
+	if _, ok := record.Change.NewImage["Transcription"]; ok {
+		return deps.sendVoicemail(ctx, record)
+	}
+	if _, ok := record.Change.NewImage["MessageSid"]; ok {
+		return deps.sendMessage(ctx, record)
+	}
 
-		log.Printf("recordingFilePath: %s", recordingFilePath)
+	return deps.sendMissedCall(ctx, record)
+}
 
-		recordingFile, err := os.Create(recordingFilePath)
-		if err != nil {
-			return err
-		}
+func (deps *deps) sendVoicemail(ctx context.Context, record events.DynamoDBEventRecord) error {
+	recordingSID := record.Change.NewImage["RecordingSid"].String()
+	transcription := record.Change.NewImage["Transcription"].String()
+	// Transcriptions from before languages were detected don't have one.
+	language := ""
+	if value, ok := record.Change.NewImage["Language"]; ok {
+		language = value.String()
+	}
 
-		iter := &s3manager.DownloadObjectsIterator{
-			Objects: []s3manager.BatchDownloadObject{
-				{
-					Object: &s3.GetObjectInput{
-						Bucket: aws.String(deps.recordingBucket),
-						Key:    aws.String(recordingSID),
-					},
-					Writer: recordingFile,
-				},
-			},
-		}
+	log.Printf("recordingSID: %s", recordingSID)
+
+	key, err := deps.recordingKey(ctx, record)
+	if err != nil {
+		return err
+	}
+
+	content, object, err := deps.download(ctx, key)
+	if err != nil {
+		return err
+	}
 
-		err = deps.s3.DownloadWithIterator(ctx, iter)
+	metadata, ok := recording.ParseMetadata(object)
+	if !ok {
+		metadata, err = deps.callMetadata(ctx, recordingSID)
 		if err != nil {
 			return err
 		}
+	}
+
+	err = metadata.Verify(content)
+	if err != nil {
+		return err
+	}
+
+	mailbox, err := deps.mailboxes.Get(ctx, metadata.Mailbox)
+	if err != nil {
+		return err
+	}
+
+	body := transcription
+	if language == "" {
+		language = mailbox.Language
+	} else {
+		body = fmt.Sprintf("%s\n\nTranscribed from %s.", transcription, language)
+	}
+
+	var attachments []attachment
+	if !mailbox.SkipAttachment {
+		attachments = append(attachments, attachment{
+			name:        "voicemail.mp3",
+			contentType: recording.ContentType,
+			content:     content,
+		})
+	}
+
+	input, err := buildEmailInput(
+		deps.fromEmail,
+		mailbox.Recipients(metadata.Option),
+		subject(metadata.Option, fmt.Sprintf("New voicemail from %s", metadata.Caller)),
+		language,
+		body,
+		attachments...,
+	)
+	if err != nil {
+		return err
+	}
+
+	return deps.sendOnce(ctx, record, input)
+}
 
-		result, err := deps.dynamodb.GetItemWithContext(ctx, &dynamodb.GetItemInput{
-			TableName: aws.String(deps.answeringMachineTable),
-			Key: map[string]*dynamodb.AttributeValue{
-				"RecordingSid": {
-					S: aws.String(recordingSID),
-				},
+// sendOnce sends the email for a record unless its item says it has been
+// sent already. Lambda retries whole batches, so without the EmailSent
+// marker every record before a failing one would be emailed again. The
+// marker is set before sending and removed if SES fails, so an email is
+// sent at most once.
+func (deps *deps) sendOnce(ctx context.Context, record events.DynamoDBEventRecord, input *ses.SendRawEmailInput) error {
+	tableName, err := stream.TableName(record)
+	if err != nil {
+		return err
+	}
+	key, err := stream.Item(record.Change.Keys)
+	if err != nil {
+		return err
+	}
+
+	_, err = deps.dynamodb.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(tableName),
+		Key:                 key,
+		ConditionExpression: aws.String("attribute_not_exists(EmailSent)"),
+		UpdateExpression:    aws.String("SET EmailSent = :sent"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":sent": {
+				BOOL: aws.Bool(true),
 			},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		log.Printf("already emailed %s record %s", tableName, record.Change.SequenceNumber)
+
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = deps.ses.SendRawEmailWithContext(ctx, input)
+	if err != nil {
+		_, removeErr := deps.dynamodb.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+			TableName:        aws.String(tableName),
+			Key:              key,
+			UpdateExpression: aws.String("REMOVE EmailSent"),
 		})
-		if err != nil {
-			return err
+		if removeErr != nil {
+			log.Printf("unmarking %s record %s: %s", tableName, record.Change.SequenceNumber, removeErr)
 		}
+	}
 
-		webhookData := webhookData{}
-		err = dynamodbattribute.UnmarshalMap(result.Item, &webhookData)
-		if err != nil {
-			return err
-		}
+	return err
+}
 
-		subject := fmt.Sprintf("New voicemail from %s", webhookData.Caller)
-		recording, err := ioutil.ReadFile(recordingFilePath)
-		if err != nil {
-			return err
-		}
+// download reads an object from the recordings bucket, with its metadata.
+func (deps *deps) download(ctx context.Context, key string) ([]byte, map[string]*string, error) {
+	object, err := deps.s3.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(deps.recordingBucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer object.Body.Close()
 
-		input, err := buildEmailInput(
-			deps.toEmail,
-			deps.toEmail,
-			subject,
-			transcription,
-			recording,
-		)
-		if err != nil {
-			return err
-		}
+	content, err := ioutil.ReadAll(object.Body)
 
-		_, err = deps.ses.SendRawEmailWithContext(ctx, input)
-		if err != nil {
-			return err
+	return content, object.Metadata, err
+}
+
+// recordingKey finds where a transcribed recording was archived: on the
+// transcription, on the webhook data item for transcriptions from before
+// keys were stored, or at the bare SID for recordings from before keys.
+func (deps *deps) recordingKey(ctx context.Context, record events.DynamoDBEventRecord) (string, error) {
+	if key, ok := record.Change.NewImage["RecordingKey"]; ok {
+		return key.String(), nil
+	}
+
+	recordingSID := record.Change.NewImage["RecordingSid"].String()
+
+	callback, err := deps.callback(ctx, recordingSID)
+	if err != nil {
+		return "", err
+	}
+
+	if callback.RecordingKey != "" {
+		return callback.RecordingKey, nil
+	}
+
+	return recordingSID, nil
+}
+
+// callMetadata looks up the call of a recording archived without its
+// metadata in the webhook data table.
+func (deps *deps) callMetadata(ctx context.Context, recordingSID string) (recording.Metadata, error) {
+	callback, err := deps.callback(ctx, recordingSID)
+
+	return recording.NewMetadata(callback), err
+}
+
+// callback reads a recording's item from the webhook data table.
+func (deps *deps) callback(ctx context.Context, recordingSID string) (telephony.RecordingEvent, error) {
+	callback := telephony.RecordingEvent{}
+
+	result, err := deps.dynamodb.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(deps.answeringMachineTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"RecordingSid": {
+				S: aws.String(recordingSID),
+			},
+		},
+	})
+	if err != nil {
+		return callback, err
+	}
+
+	err = dynamodbattribute.UnmarshalMap(result.Item, &callback)
+
+	return callback, err
+}
+
+func (deps *deps) sendMissedCall(ctx context.Context, record events.DynamoDBEventRecord) error {
+	callback := telephony.RecordingEvent{}
+	err := stream.UnmarshalImage(record.Change.NewImage, &callback)
+	if err != nil {
+		return err
+	}
+
+	if callback.Completed() {
+		return nil
+	}
+
+	mailbox, err := deps.mailboxes.Get(ctx, callback.To)
+	if err != nil {
+		return err
+	}
+
+	if mailbox.SkipMissedCalls {
+		return nil
+	}
+
+	log.Printf("missed call %s, recording %s", callback.CallSid, callback.RecordingStatus)
+
+	input, err := buildEmailInput(
+		deps.fromEmail,
+		mailbox.Recipients(callback.Option),
+		subject(callback.Option, fmt.Sprintf("Missed call from %s", callback.CallerNumber())),
+		mailbox.Language,
+		fmt.Sprintf("%s called but didn't leave a message.", callback.CallerNumber()),
+	)
+	if err != nil {
+		return err
+	}
+
+	return deps.sendOnce(ctx, record, input)
+}
+
+// subject prefixes the subject with the menu option the caller chose, so
+// "urgent" gives "[Urgent] New voicemail from …".
+func subject(option, text string) string {
+	if option == "" {
+		return text
+	}
+
+	first, size := utf8.DecodeRuneInString(option)
+
+	return fmt.Sprintf("[%c%s] %s", unicode.ToUpper(first), option[size:], text)
+}
+
+func (deps *deps) sendMessage(ctx context.Context, record events.DynamoDBEventRecord) error {
+	message := telephony.Message{}
+	err := stream.UnmarshalImage(record.Change.NewImage, &message)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("messageSID: %s", message.MessageSid)
+
+	mailbox, err := deps.mailboxes.Get(ctx, message.To)
+	if err != nil {
+		return err
+	}
+
+	body := new(bytes.Buffer)
+	err = messageTemplate.Execute(body, message)
+	if err != nil {
+		return err
+	}
+
+	var attachments []attachment
+	if !mailbox.SkipAttachment {
+		for _, media := range message.Media {
+			content, _, err := deps.download(ctx, media.Key)
+			if err != nil {
+				return err
+			}
+			attachments = append(attachments, attachment{
+				name:        path.Base(media.Key),
+				contentType: media.ContentType,
+				content:     content,
+			})
 		}
 	}
 
-	return nil
+	input, err := buildEmailInput(
+		deps.fromEmail,
+		mailbox.Recipients(""),
+		fmt.Sprintf("New text message from %s", message.From),
+		mailbox.Language,
+		body.String(),
+		attachments...,
+	)
+	if err != nil {
+		return err
+	}
+
+	return deps.sendOnce(ctx, record, input)
 }
 
 func main() {
@@ -128,13 +366,25 @@ func main() {
 	xray.AWS(dynamodb.Client)
 	xray.AWS(s3client.Client)
 
-	s3downloader := s3manager.NewDownloaderWithClient(s3client)
+	// Numbers without a mailbox are delivered to TO_EMAIL, which is also the
+	// sender unless FROM_EMAIL is set. OPTION_EMAIL routes calls by the menu
+	// option, as "urgent=oncall@example.com;billing=accounts@example.com".
+	toEmail := os.Getenv("TO_EMAIL")
+	fromEmail := os.Getenv("FROM_EMAIL")
+	if fromEmail == "" {
+		fromEmail = toEmail
+	}
 
 	deps := deps{
-		ses:                   ses,
-		dynamodb:              dynamodb,
-		s3:                    s3downloader,
-		toEmail:               os.Getenv("TO_EMAIL"),
+		ses:      ses,
+		dynamodb: dynamodb,
+		s3:       s3client,
+		mailboxes: mailbox.NewStore(dynamodb, os.Getenv("MAILBOX_TABLE"), mailbox.Mailbox{
+			Email:       toEmail,
+			Language:    "en-US",
+			OptionEmail: mailbox.ParseOptionEmail(os.Getenv("OPTION_EMAIL")),
+		}),
+		fromEmail:             fromEmail,
 		answeringMachineTable: os.Getenv("ANSWERING_MACHINE_WEBHOOK_DATA_TABLE"),
 		recordingBucket:       os.Getenv("ANSWERING_MACHINE_RECORDING_BUCKET"),
 	}
@@ -142,11 +392,17 @@ func main() {
 	lambda.Start(deps.handler)
 }
 
+type attachment struct {
+	name        string
+	contentType string
+	content     []byte
+}
+
 // https://gist.github.com/carelvwyk/60100f2421c6284391d08374bc887dca
-func buildEmailInput(source, destination, subject, message string, fileContent []byte) (*ses.SendRawEmailInput, error) {
+func buildEmailInput(source string, destinations []string, subject, language, message string, attachments ...attachment) (*ses.SendRawEmailInput, error) {
 
 	log.Printf("source: %s", source)
-	log.Printf("destination: %s", destination)
+	log.Printf("destinations: %s", strings.Join(destinations, ", "))
 	log.Printf("subject: %s", subject)
 	log.Printf("message: %s", message)
 
@@ -156,10 +412,10 @@ func buildEmailInput(source, destination, subject, message string, fileContent [
 	// email main header:
 	h := make(textproto.MIMEHeader)
 	h.Set("From", source)
-	h.Set("To", destination)
+	h.Set("To", strings.Join(destinations, ", "))
 	h.Set("Return-Path", source)
 	h.Set("Subject", subject)
-	h.Set("Content-Language", "en-US")
+	h.Set("Content-Language", language)
 	h.Set("Content-Type", "multipart/mixed; boundary=\""+writer.Boundary()+"\"")
 	h.Set("MIME-Version", "1.0")
 	_, err := writer.CreatePart(h)
@@ -169,8 +425,8 @@ func buildEmailInput(source, destination, subject, message string, fileContent [
 
 	// body:
 	h = make(textproto.MIMEHeader)
-	h.Set("Content-Transfer-Encoding", "7bit")
-	h.Set("Content-Type", "text/plain; charset=us-ascii")
+	h.Set("Content-Transfer-Encoding", "8bit")
+	h.Set("Content-Type", "text/plain; charset=UTF-8")
 	part, err := writer.CreatePart(h)
 	if err != nil {
 		return nil, err
@@ -180,25 +436,35 @@ func buildEmailInput(source, destination, subject, message string, fileContent [
 		return nil, err
 	}
 
-	// file attachment:
-	fn := "voicemail.mp3"
-	h = make(textproto.MIMEHeader)
-	h.Set("Content-Disposition", "attachment")
-	h.Set("Content-Type", "audio/mpeg; name=\""+fn+"\"")
-	h.Set("Content-Transfer-Encoding", "base64")
-	part, err = writer.CreatePart(h)
-	if err != nil {
-		return nil, err
-	}
+	for _, file := range attachments {
+		contentType := file.contentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
 
-	// Encode as base64.
-	encoded := base64.StdEncoding.EncodeToString(fileContent)
+		h = make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", "attachment; filename=\""+file.name+"\"")
+		h.Set("Content-Type", contentType+"; name=\""+file.name+"\"")
+		h.Set("Content-Transfer-Encoding", "base64")
+		part, err = writer.CreatePart(h)
+		if err != nil {
+			return nil, err
+		}
 
-	_, err = part.Write([]byte(encoded))
-	if err != nil {
-		return nil, err
+		// Encode as base64.
+		encoded := base64.StdEncoding.EncodeToString(file.content)
+
+		_, err = part.Write([]byte(encoded))
+		if err != nil {
+			return nil, err
+		}
 	}
-	err = writer.Close()
+
+	return rawEmailInput(buf, writer, source, destinations)
+}
+
+func rawEmailInput(buf *bytes.Buffer, writer *multipart.Writer, source string, destinations []string) (*ses.SendRawEmailInput, error) {
+	err := writer.Close()
 	if err != nil {
 		return nil, err
 	}
@@ -214,7 +480,7 @@ func buildEmailInput(source, destination, subject, message string, fileContent [
 		Data: []byte(s),
 	}
 	input := &ses.SendRawEmailInput{
-		Destinations: []*string{aws.String(destination)},
+		Destinations: aws.StringSlice(destinations),
 		Source:       aws.String(source),
 		RawMessage:   &raw,
 	}
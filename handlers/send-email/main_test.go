@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/aws/aws-sdk-go/service/ses/sesiface"
+	"github.com/stretchr/testify/assert"
+
+	"answering-machine/internal/mailbox"
+)
+
+type mockSESAPI struct {
+	sesiface.SESAPI
+
+	inputs []*ses.SendRawEmailInput
+	err    error
+}
+
+func (mock *mockSESAPI) SendRawEmailWithContext(ctx aws.Context, in *ses.SendRawEmailInput, opts ...request.Option) (*ses.SendRawEmailOutput, error) {
+	mock.inputs = append(mock.inputs, in)
+
+	return &ses.SendRawEmailOutput{}, mock.err
+}
+
+// mockDynamoDBAPI keeps the EmailSent marker of each table and key.
+type mockDynamoDBAPI struct {
+	dynamodbiface.DynamoDBAPI
+
+	sent map[string]bool
+}
+
+func (mock *mockDynamoDBAPI) UpdateItemWithContext(ctx aws.Context, in *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	if mock.sent == nil {
+		mock.sent = map[string]bool{}
+	}
+
+	key := *in.TableName + "/" + aws.StringValue(in.Key["RecordingSid"].S)
+	switch *in.UpdateExpression {
+	case "SET EmailSent = :sent":
+		if mock.sent[key] {
+			return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+		}
+		mock.sent[key] = true
+	case "REMOVE EmailSent":
+		delete(mock.sent, key)
+	}
+
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+const webhookStreamArn = "arn:aws:dynamodb:eu-west-2:123456789012:table/webhook-data/stream/2026-10-18T08:00:00.000"
+
+type mockMailboxStore map[string]mailbox.Mailbox
+
+func (mock mockMailboxStore) Get(ctx context.Context, number string) (mailbox.Mailbox, error) {
+	return mock[number], nil
+}
+
+// header reads a header of the email's first part.
+func header(in *ses.SendRawEmailInput, name string) string {
+	for _, line := range strings.Split(string(in.RawMessage.Data), "\r\n") {
+		if strings.HasPrefix(line, name+": ") {
+			return strings.TrimPrefix(line, name+": ")
+		}
+	}
+
+	return ""
+}
+
+func TestSubject(t *testing.T) {
+	cases := []struct {
+		option   string
+		expected string
+	}{
+		{"", "Missed call"},
+		{"urgent", "[Urgent] Missed call"},
+		{"Urgent", "[Urgent] Missed call"},
+		{"été", "[Été] Missed call"},
+		{"ñ", "[Ñ] Missed call"},
+		{"1", "[1] Missed call"},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.expected, subject(c.option, "Missed call"), c.option)
+	}
+}
+
+func TestSendMissedCall(t *testing.T) {
+	mailboxes := mockMailboxStore{
+		"+441632960000": {
+			Email:       "office@example.com",
+			Language:    "en-GB",
+			OptionEmail: map[string]string{"urgent": "oncall@example.com, manager@example.com"},
+		},
+	}
+
+	cases := []struct {
+		name         string
+		option       string
+		destinations []string
+		subject      string
+	}{
+		{"No option", "", []string{"office@example.com"}, "Missed call from +447700900123"},
+		{"Routed option", "urgent", []string{"oncall@example.com", "manager@example.com"}, "[Urgent] Missed call from +447700900123"},
+		{"Unrouted option", "billing", []string{"office@example.com"}, "[Billing] Missed call from +447700900123"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mockSES := &mockSESAPI{}
+			deps := deps{
+				ses:       mockSES,
+				dynamodb:  &mockDynamoDBAPI{},
+				mailboxes: mailboxes,
+				fromEmail: "machine@example.com",
+			}
+
+			// Only From is set, calls recorded without Caller are named by it.
+			err := deps.send(context.Background(), events.DynamoDBEventRecord{
+				EventSourceArn: webhookStreamArn,
+				Change: events.DynamoDBStreamRecord{
+					Keys: map[string]events.DynamoDBAttributeValue{
+						"RecordingSid": events.NewStringAttribute("RE123"),
+					},
+					NewImage: map[string]events.DynamoDBAttributeValue{
+						"CallSid":         events.NewStringAttribute("CA123"),
+						"RecordingSid":    events.NewStringAttribute("RE123"),
+						"RecordingStatus": events.NewStringAttribute("absent"),
+						"From":            events.NewStringAttribute("+447700900123"),
+						"To":              events.NewStringAttribute("+441632960000"),
+						"Option":          events.NewStringAttribute(c.option),
+					},
+				},
+			})
+
+			assert.NoError(t, err)
+			assert.Len(t, mockSES.inputs, 1)
+			assert.Equal(t, strings.Join(c.destinations, ", "), header(mockSES.inputs[0], "To"))
+			assert.Equal(t, c.subject, header(mockSES.inputs[0], "Subject"))
+			assert.Equal(t, "en-GB", header(mockSES.inputs[0], "Content-Language"))
+			assert.Contains(t, string(mockSES.inputs[0].RawMessage.Data), "+447700900123 called but didn't leave a message.")
+		})
+	}
+}
+
+func TestSendOnce(t *testing.T) {
+	record := events.DynamoDBEventRecord{
+		EventSourceArn: webhookStreamArn,
+		Change: events.DynamoDBStreamRecord{
+			Keys: map[string]events.DynamoDBAttributeValue{
+				"RecordingSid": events.NewStringAttribute("RE123"),
+			},
+			NewImage: map[string]events.DynamoDBAttributeValue{
+				"CallSid":         events.NewStringAttribute("CA123"),
+				"RecordingSid":    events.NewStringAttribute("RE123"),
+				"RecordingStatus": events.NewStringAttribute("absent"),
+				"From":            events.NewStringAttribute("+447700900123"),
+				"To":              events.NewStringAttribute("+441632960000"),
+			},
+		},
+	}
+
+	t.Run("Doesn't email a retried record again", func(t *testing.T) {
+		mockSES := &mockSESAPI{}
+		db := &mockDynamoDBAPI{}
+		deps := deps{
+			ses:       mockSES,
+			dynamodb:  db,
+			mailboxes: mockMailboxStore{},
+		}
+
+		err := deps.send(context.Background(), record)
+		assert.NoError(t, err)
+		err = deps.send(context.Background(), record)
+		assert.NoError(t, err)
+
+		assert.Len(t, mockSES.inputs, 1)
+		assert.True(t, db.sent["webhook-data/RE123"])
+	})
+
+	t.Run("Unmarks the record if sending fails", func(t *testing.T) {
+		mockSES := &mockSESAPI{err: errors.New("throttled")}
+		db := &mockDynamoDBAPI{}
+		deps := deps{
+			ses:       mockSES,
+			dynamodb:  db,
+			mailboxes: mockMailboxStore{},
+		}
+
+		err := deps.send(context.Background(), record)
+
+		assert.EqualError(t, err, "throttled")
+		assert.False(t, db.sent["webhook-data/RE123"])
+
+		mockSES.err = nil
+		err = deps.send(context.Background(), record)
+
+		assert.NoError(t, err)
+		assert.Len(t, mockSES.inputs, 2)
+	})
+}
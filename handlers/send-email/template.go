@@ -0,0 +1,11 @@
+package main
+
+import "text/template"
+
+// messageTemplate is the body of the email for a text message.
+var messageTemplate = template.Must(template.New("message").Parse(`{{.Body}}
+
+--
+Text message from {{.From}} to {{.To}}.
+{{- with .Media}} {{len .}} attachment{{if gt (len .) 1}}s{{end}}.{{end}}
+`))
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-xray-sdk-go/xray"
+
+	"answering-machine/internal/sms"
+)
+
+func main() {
+	sess := session.Must(session.NewSession())
+	dynamodb := dynamodb.New(sess)
+	s3client := s3.New(sess)
+
+	xray.AWS(dynamodb.Client)
+	xray.AWS(s3client.Client)
+
+	deps, err := sms.NewFromEnv(dynamodb, s3manager.NewUploaderWithClient(s3client), &http.Client{})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	lambda.Start(deps.Handler)
+}
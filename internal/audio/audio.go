@@ -0,0 +1,87 @@
+// Package audio measures recordings, so the pipeline can tell hang-ups
+// from messages without paying for a transcription, and probes their
+// format for the speech backends.
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+// Analysis is what a recording sounds like.
+type Analysis struct {
+	Duration time.Duration
+	// Level is the RMS energy of the whole recording in dBFS, from 0 for a
+	// full scale square wave down to -Inf for digital silence.
+	Level float64
+}
+
+// Thresholds below which a recording is too short or too quiet to hold a
+// message. A zero MinDuration or MinLevel disables that check.
+type Thresholds struct {
+	MinDuration time.Duration
+	MinLevel    float64
+}
+
+// Empty reports whether the analysis falls below either threshold.
+func (thresholds Thresholds) Empty(analysis Analysis) bool {
+	if thresholds.MinDuration > 0 && analysis.Duration < thresholds.MinDuration {
+		return true
+	}
+
+	return thresholds.MinLevel != 0 && analysis.Level < thresholds.MinLevel
+}
+
+// AnalyseMP3 decodes an MP3 recording and measures it.
+func AnalyseMP3(content []byte) (Analysis, error) {
+	decoder, err := mp3.NewDecoder(bytes.NewReader(content))
+	if err != nil {
+		return Analysis{}, fmt.Errorf("decoding MP3: %w", err)
+	}
+
+	return measure(decoder, decoder.SampleRate())
+}
+
+// measure reads 16-bit little-endian stereo PCM, the format the decoder
+// always produces.
+func measure(pcm io.Reader, sampleRate int) (Analysis, error) {
+	if sampleRate <= 0 {
+		return Analysis{}, fmt.Errorf("invalid sample rate %d", sampleRate)
+	}
+
+	var samples int64
+	var sumSquares float64
+	buf := make([]byte, 4096)
+	for {
+		n, err := io.ReadFull(pcm, buf)
+		for i := 0; i+1 < n; i += 2 {
+			sample := float64(int16(binary.LittleEndian.Uint16(buf[i:])))
+			sumSquares += sample * sample
+			samples++
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return Analysis{}, err
+		}
+	}
+
+	analysis := Analysis{
+		// Two channels, so each frame is two samples.
+		Duration: time.Duration(samples/2) * time.Second / time.Duration(sampleRate),
+		Level:    math.Inf(-1),
+	}
+	if samples > 0 {
+		rms := math.Sqrt(sumSquares / float64(samples))
+		analysis.Level = 20 * math.Log10(rms/math.MaxInt16)
+	}
+
+	return analysis, nil
+}
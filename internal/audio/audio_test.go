@@ -0,0 +1,72 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// silentMP3 returns frames of MPEG-1 Layer III, 128kbps, 44.1kHz mono
+// with no audio data, each 1152 samples long.
+func silentMP3(frames int) []byte {
+	frame := make([]byte, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0xC0})
+
+	return bytes.Repeat(frame, frames)
+}
+
+// silentMPEG25 returns frames of MPEG 2.5 Layer III, 8kbps, 8kHz mono
+// with no audio data, each 576 samples long, as narrow-band telephony
+// records.
+func silentMPEG25(frames int) []byte {
+	frame := make([]byte, 72)
+	copy(frame, []byte{0xFF, 0xE3, 0x18, 0xC0})
+
+	return bytes.Repeat(frame, frames)
+}
+
+func TestAnalyseMP3(t *testing.T) {
+	analysis, err := AnalyseMP3(silentMP3(100))
+
+	assert.NoError(t, err)
+	assert.InDelta(t, float64(100*1152*time.Second/44100), float64(analysis.Duration), float64(50*time.Millisecond))
+	assert.True(t, math.IsInf(analysis.Level, -1))
+
+	_, err = AnalyseMP3([]byte("<html>Error</html>"))
+
+	assert.Error(t, err)
+
+	// The decoder can't, Probe measures these instead.
+	_, err = AnalyseMP3(silentMPEG25(28))
+
+	assert.Error(t, err)
+}
+
+func TestMeasure(t *testing.T) {
+	// A second of a half scale sine wave on both channels.
+	pcm := new(bytes.Buffer)
+	for i := 0; i < 8000; i++ {
+		sample := int16(math.MaxInt16 / 2 * math.Sin(2*math.Pi*440*float64(i)/8000))
+		binary.Write(pcm, binary.LittleEndian, [2]int16{sample, sample})
+	}
+
+	analysis, err := measure(pcm, 8000)
+
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, analysis.Duration)
+	// A sine's RMS is 1/√2 of its peak, 3dB below it.
+	assert.InDelta(t, -9.03, analysis.Level, 0.05)
+}
+
+func TestThresholds(t *testing.T) {
+	thresholds := Thresholds{MinDuration: 3 * time.Second, MinLevel: -50}
+
+	assert.True(t, thresholds.Empty(Analysis{Duration: 2 * time.Second, Level: -10}))
+	assert.True(t, thresholds.Empty(Analysis{Duration: 20 * time.Second, Level: math.Inf(-1)}))
+	assert.False(t, thresholds.Empty(Analysis{Duration: 20 * time.Second, Level: -30}))
+	assert.False(t, Thresholds{}.Empty(Analysis{Level: math.Inf(-1)}))
+}
@@ -0,0 +1,210 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Codecs Probe recognises.
+const (
+	CodecMP3 = "mp3"
+	// CodecLinear16 is 16-bit signed little-endian PCM in a WAV file.
+	CodecLinear16 = "linear16"
+	// CodecMulaw is G.711 μ-law in a WAV file.
+	CodecMulaw = "mulaw"
+)
+
+// ErrUnknownFormat is returned for audio that is neither MP3 nor WAV in a
+// codec Probe recognises.
+var ErrUnknownFormat = errors.New("unknown audio format")
+
+// Format is how a recording is encoded, as its headers describe it.
+type Format struct {
+	Codec      string
+	SampleRate int
+	Channels   int
+	Duration   time.Duration
+}
+
+// Probe reads the format of an MP3 or WAV recording from its headers.
+func Probe(content []byte) (Format, error) {
+	if len(content) >= 12 && string(content[:4]) == "RIFF" && string(content[8:12]) == "WAVE" {
+		return probeWAV(content[12:])
+	}
+
+	return probeMP3(content)
+}
+
+// WAV format tags.
+const (
+	wavPCM        = 1
+	wavMulaw      = 7
+	wavExtensible = 0xFFFE
+)
+
+// probeWAV reads the chunks after the RIFF header, which must have a fmt
+// chunk before the data.
+// http://soundfile.sapp.org/doc/WaveFormat/
+func probeWAV(chunks []byte) (Format, error) {
+	format := Format{}
+	byteRate := 0
+	for len(chunks) >= 8 {
+		id := string(chunks[:4])
+		size := int(binary.LittleEndian.Uint32(chunks[4:8]))
+		chunks = chunks[8:]
+
+		switch id {
+		case "fmt ":
+			if size < 16 || len(chunks) < 16 {
+				return Format{}, fmt.Errorf("WAV fmt chunk of %d bytes: %w", size, ErrUnknownFormat)
+			}
+
+			tag := binary.LittleEndian.Uint16(chunks[0:2])
+			// The sub-format GUID of an extensible fmt chunk starts with the tag.
+			if tag == wavExtensible && size >= 26 && len(chunks) >= 26 {
+				tag = binary.LittleEndian.Uint16(chunks[24:26])
+			}
+			bits := binary.LittleEndian.Uint16(chunks[14:16])
+
+			switch {
+			case tag == wavPCM && bits == 16:
+				format.Codec = CodecLinear16
+			case tag == wavMulaw:
+				format.Codec = CodecMulaw
+			default:
+				return Format{}, fmt.Errorf("WAV format %d with %d bits: %w", tag, bits, ErrUnknownFormat)
+			}
+			format.Channels = int(binary.LittleEndian.Uint16(chunks[2:4]))
+			format.SampleRate = int(binary.LittleEndian.Uint32(chunks[4:8]))
+			byteRate = int(binary.LittleEndian.Uint32(chunks[8:12]))
+		case "data":
+			if format.Codec == "" {
+				return Format{}, fmt.Errorf("WAV data before fmt: %w", ErrUnknownFormat)
+			}
+			// Recordings still being written can claim more than they hold.
+			if size > len(chunks) {
+				size = len(chunks)
+			}
+			if byteRate > 0 {
+				format.Duration = time.Duration(size) * time.Second / time.Duration(byteRate)
+			}
+
+			return format, nil
+		}
+
+		// Chunks are padded to an even length.
+		size += size % 2
+		if size > len(chunks) {
+			break
+		}
+		chunks = chunks[size:]
+	}
+
+	return Format{}, fmt.Errorf("WAV without data: %w", ErrUnknownFormat)
+}
+
+// MPEG audio versions, from bits 19 and 20 of a frame header.
+const (
+	mpeg25 = 0
+	mpeg2  = 2
+	mpeg1  = 3
+)
+
+// Layer III bit rates in kbps by version and the header's index.
+var mp3BitRates = map[int][15]int{
+	mpeg1: {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	mpeg2: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+}
+
+// Sample rates in Hz by version and the header's index.
+var mp3SampleRates = map[int][3]int{
+	mpeg1:  {44100, 48000, 32000},
+	mpeg2:  {22050, 24000, 16000},
+	mpeg25: {11025, 12000, 8000},
+}
+
+// mp3Frame is what a Layer III frame header says about its frame.
+type mp3Frame struct {
+	sampleRate int
+	channels   int
+	samples    int
+	length     int
+}
+
+// probeMP3 skips any ID3v2 tag, then walks the Layer III frames, adding up
+// their samples, which holds for variable bit rates too.
+// http://www.mp3-tech.org/programmer/frame_header.html
+func probeMP3(content []byte) (Format, error) {
+	if len(content) >= 10 && bytes.HasPrefix(content, []byte("ID3")) {
+		// The size is syncsafe, seven bits to a byte.
+		size := int(content[6])<<21 | int(content[7])<<14 | int(content[8])<<7 | int(content[9])
+		if 10+size > len(content) {
+			return Format{}, fmt.Errorf("ID3 tag longer than the file: %w", ErrUnknownFormat)
+		}
+		content = content[10+size:]
+	}
+
+	first, ok := parseMP3Frame(content)
+	if !ok {
+		return Format{}, ErrUnknownFormat
+	}
+
+	samples := 0
+	for {
+		frame, ok := parseMP3Frame(content)
+		if !ok {
+			break
+		}
+		samples += frame.samples
+		if frame.length > len(content) {
+			break
+		}
+		content = content[frame.length:]
+	}
+
+	return Format{
+		Codec:      CodecMP3,
+		SampleRate: first.sampleRate,
+		Channels:   first.channels,
+		Duration:   time.Duration(samples) * time.Second / time.Duration(first.sampleRate),
+	}, nil
+}
+
+// parseMP3Frame reads the Layer III frame header at the start of content.
+func parseMP3Frame(content []byte) (mp3Frame, bool) {
+	if len(content) < 4 || content[0] != 0xFF || content[1]&0xE0 != 0xE0 {
+		return mp3Frame{}, false
+	}
+
+	version := int(content[1]>>3) & 3
+	layer := int(content[1]>>1) & 3
+	bitRateIndex := int(content[2] >> 4)
+	sampleRateIndex := int(content[2]>>2) & 3
+	padding := int(content[2]>>1) & 1
+	mode := int(content[3] >> 6)
+
+	// Layer III is 1, 0 is reserved as are index 15 and sample rate 3.
+	if version == 1 || layer != 1 || bitRateIndex == 0 || bitRateIndex == 15 || sampleRateIndex == 3 {
+		return mp3Frame{}, false
+	}
+
+	bitRates := mp3BitRates[mpeg2]
+	frame := mp3Frame{
+		sampleRate: mp3SampleRates[version][sampleRateIndex],
+		channels:   2,
+		samples:    576,
+	}
+	if version == mpeg1 {
+		bitRates = mp3BitRates[mpeg1]
+		frame.samples = 1152
+	}
+	if mode == 3 {
+		frame.channels = 1
+	}
+	frame.length = frame.samples/8*bitRates[bitRateIndex]*1000/frame.sampleRate + padding
+
+	return frame, true
+}
@@ -0,0 +1,91 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// wav returns a WAV file of seconds of silence in format tag, with a LIST
+// chunk before the data as some encoders write.
+func wav(tag uint16, bits uint16, sampleRate uint32, channels uint16, seconds int) []byte {
+	blockAlign := channels * bits / 8
+	data := make([]byte, seconds*int(sampleRate)*int(blockAlign))
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("RIFF")
+	binary.Write(buf, binary.LittleEndian, uint32(4+8+16+8+3+1+8+len(data)))
+	buf.WriteString("WAVE")
+	buf.WriteString("fmt ")
+	for _, field := range []interface{}{uint32(16), tag, channels, sampleRate, sampleRate * uint32(blockAlign), blockAlign, bits} {
+		binary.Write(buf, binary.LittleEndian, field)
+	}
+	buf.WriteString("LIST")
+	binary.Write(buf, binary.LittleEndian, uint32(3))
+	buf.WriteString("abc\x00")
+	buf.WriteString("data")
+	binary.Write(buf, binary.LittleEndian, uint32(len(data)))
+	buf.Write(data)
+
+	return buf.Bytes()
+}
+
+func TestProbe(t *testing.T) {
+	t.Run("MP3", func(t *testing.T) {
+		format, err := Probe(silentMP3(100))
+
+		assert.NoError(t, err)
+		assert.Equal(t, CodecMP3, format.Codec)
+		assert.Equal(t, 44100, format.SampleRate)
+		assert.Equal(t, 1, format.Channels)
+		assert.Equal(t, 100*1152*time.Second/44100, format.Duration)
+	})
+
+	t.Run("MPEG 2.5", func(t *testing.T) {
+		format, err := Probe(silentMPEG25(28))
+
+		assert.NoError(t, err)
+		assert.Equal(t, CodecMP3, format.Codec)
+		assert.Equal(t, 8000, format.SampleRate)
+		assert.Equal(t, 1, format.Channels)
+		assert.Equal(t, 28*576*time.Second/8000, format.Duration)
+	})
+
+	t.Run("MP3 with an ID3 tag", func(t *testing.T) {
+		tag := append([]byte("ID3\x03\x00\x00\x00\x00\x01\x00"), make([]byte, 128)...)
+
+		format, err := Probe(append(tag, silentMP3(10)...))
+
+		assert.NoError(t, err)
+		assert.Equal(t, CodecMP3, format.Codec)
+		assert.Equal(t, 10*1152*time.Second/44100, format.Duration)
+	})
+
+	t.Run("WAV", func(t *testing.T) {
+		format, err := Probe(wav(wavPCM, 16, 8000, 1, 2))
+
+		assert.NoError(t, err)
+		assert.Equal(t, Format{Codec: CodecLinear16, SampleRate: 8000, Channels: 1, Duration: 2 * time.Second}, format)
+	})
+
+	t.Run("μ-law WAV", func(t *testing.T) {
+		format, err := Probe(wav(wavMulaw, 8, 8000, 2, 1))
+
+		assert.NoError(t, err)
+		assert.Equal(t, Format{Codec: CodecMulaw, SampleRate: 8000, Channels: 2, Duration: time.Second}, format)
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := Probe(wav(wavPCM, 24, 48000, 2, 1))
+
+		assert.True(t, errors.Is(err, ErrUnknownFormat))
+
+		_, err = Probe([]byte("<html>Error</html>"))
+
+		assert.True(t, errors.Is(err, ErrUnknownFormat))
+	})
+}
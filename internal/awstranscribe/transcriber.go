@@ -0,0 +1,202 @@
+// Package awstranscribe transcribes recordings with Amazon Transcribe,
+// which reads them from the recordings bucket itself. Jobs are named after
+// the recording, so a retried transcription picks up the job already
+// started rather than paying for another.
+package awstranscribe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/transcribeservice"
+	"github.com/aws/aws-sdk-go/service/transcribeservice/transcribeserviceiface"
+
+	"answering-machine/internal/audio"
+	"answering-machine/internal/transcription"
+)
+
+// Name identifies Amazon Transcribe on stored transcripts and in
+// TRANSCRIBER.
+const Name = "aws"
+
+// HTTPClient fetches finished transcripts.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// mediaFormats are the Transcribe media formats of the codecs audio.Probe
+// reports. Recordings that couldn't be probed go by their extension.
+var mediaFormats = map[string]string{
+	audio.CodecMP3:      transcribeservice.MediaFormatMp3,
+	audio.CodecLinear16: transcribeservice.MediaFormatWav,
+	audio.CodecMulaw:    transcribeservice.MediaFormatWav,
+}
+
+// invalidJobName matches the characters job names can't have.
+var invalidJobName = regexp.MustCompile(`[^0-9A-Za-z._-]+`)
+
+// Transcriber is the Amazon Transcribe transcription.Transcriber.
+type Transcriber struct {
+	transcribe transcribeserviceiface.TranscribeServiceAPI
+	httpClient HTTPClient
+	poll       time.Duration
+}
+
+// NewTranscriber returns a Transcriber that checks on its jobs every few
+// seconds.
+func NewTranscriber(transcribe transcribeserviceiface.TranscribeServiceAPI, httpClient HTTPClient) *Transcriber {
+	return &Transcriber{
+		transcribe: transcribe,
+		httpClient: httpClient,
+		poll:       5 * time.Second,
+	}
+}
+
+// Transcribe starts a job for the recording at request.Key, or resumes
+// request.Operation, and waits for it to finish. The SDK can't ask
+// Transcribe to identify the language, so request.AlternativeLanguages are
+// ignored. A job still running when
+// ctx is done is returned as a transcription.PendingError.
+func (transcriber *Transcriber) Transcribe(ctx context.Context, request transcription.Request) (transcription.Transcript, error) {
+	name := request.Operation
+	if name == "" {
+		name = jobName(request.Key)
+
+		input := &transcribeservice.StartTranscriptionJobInput{
+			TranscriptionJobName: aws.String(name),
+			LanguageCode:         aws.String(request.Language),
+			MediaFormat:          aws.String(strings.TrimPrefix(path.Ext(request.Key), ".")),
+			Media: &transcribeservice.Media{
+				MediaFileUri: aws.String(fmt.Sprintf("s3://%s/%s", request.Bucket, request.Key)),
+			},
+		}
+		if mediaFormat, ok := mediaFormats[request.Format.Codec]; ok {
+			input.MediaFormat = aws.String(mediaFormat)
+			// Transcribe only takes rates from 8000 Hz up.
+			if request.Format.SampleRate >= 8000 {
+				input.MediaSampleRateHertz = aws.Int64(int64(request.Format.SampleRate))
+			}
+		}
+
+		_, err := transcriber.transcribe.StartTranscriptionJobWithContext(ctx, input)
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == transcribeservice.ErrCodeConflictException {
+			err = nil
+		}
+		if err != nil {
+			return transcription.Transcript{}, err
+		}
+	}
+
+	for {
+		output, err := transcriber.transcribe.GetTranscriptionJobWithContext(ctx, &transcribeservice.GetTranscriptionJobInput{
+			TranscriptionJobName: aws.String(name),
+		})
+		if ctx.Err() != nil {
+			return transcription.Transcript{}, &transcription.PendingError{Operation: name}
+		}
+		if err != nil {
+			return transcription.Transcript{}, err
+		}
+
+		job := output.TranscriptionJob
+		switch aws.StringValue(job.TranscriptionJobStatus) {
+		case transcribeservice.TranscriptionJobStatusCompleted:
+			return transcriber.fetch(ctx, aws.StringValue(job.LanguageCode), aws.StringValue(job.Transcript.TranscriptFileUri))
+		case transcribeservice.TranscriptionJobStatusFailed:
+			return transcription.Transcript{}, fmt.Errorf("transcription job %s failed: %s", name, aws.StringValue(job.FailureReason))
+		}
+
+		select {
+		case <-ctx.Done():
+			return transcription.Transcript{}, &transcription.PendingError{Operation: name}
+		case <-time.After(transcriber.poll):
+		}
+	}
+}
+
+// fetch downloads a finished job's transcript in language.
+// https://docs.aws.amazon.com/transcribe/latest/dg/how-input.html#how-it-works-output
+func (transcriber *Transcriber) fetch(ctx context.Context, language, uri string) (transcription.Transcript, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return transcription.Transcript{}, err
+	}
+
+	resp, err := transcriber.httpClient.Do(req)
+	if err != nil {
+		return transcription.Transcript{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return transcription.Transcript{}, fmt.Errorf("fetching transcript: %s", resp.Status)
+	}
+
+	var output struct {
+		Results struct {
+			Transcripts []struct {
+				Transcript string `json:"transcript"`
+			} `json:"transcripts"`
+			Items []struct {
+				Type         string `json:"type"`
+				StartTime    string `json:"start_time"`
+				EndTime      string `json:"end_time"`
+				Alternatives []struct {
+					Confidence string `json:"confidence"`
+					Content    string `json:"content"`
+				} `json:"alternatives"`
+			} `json:"items"`
+		} `json:"results"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&output)
+	if err != nil {
+		return transcription.Transcript{}, err
+	}
+
+	var parts []string
+	for _, transcript := range output.Results.Transcripts {
+		parts = append(parts, transcript.Transcript)
+	}
+
+	// The whole recording is one segment, as confident as its words are on
+	// average. Punctuation items have no times.
+	segment := transcription.Segment{Text: strings.Join(parts, " ")}
+	var confidence float64
+	for _, item := range output.Results.Items {
+		if item.Type != "pronunciation" || len(item.Alternatives) == 0 {
+			continue
+		}
+
+		word := transcription.Word{Word: item.Alternatives[0].Content}
+		word.Start, _ = strconv.ParseFloat(item.StartTime, 64)
+		word.End, _ = strconv.ParseFloat(item.EndTime, 64)
+		segment.Words = append(segment.Words, word)
+
+		wordConfidence, _ := strconv.ParseFloat(item.Alternatives[0].Confidence, 64)
+		confidence += wordConfidence
+	}
+	if len(segment.Words) > 0 {
+		segment.Confidence = confidence / float64(len(segment.Words))
+	}
+
+	return transcription.NewTranscript(Name, language, []transcription.Segment{segment}), nil
+}
+
+// jobName is the job for the recording at key, unique in the account.
+func jobName(key string) string {
+	name := "answering-machine-" + invalidJobName.ReplaceAllString(strings.TrimSuffix(key, path.Ext(key)), "-")
+	if len(name) > 200 {
+		name = name[len(name)-200:]
+	}
+
+	return name
+}
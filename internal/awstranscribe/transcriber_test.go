@@ -0,0 +1,166 @@
+package awstranscribe
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/transcribeservice"
+	"github.com/aws/aws-sdk-go/service/transcribeservice/transcribeserviceiface"
+	"github.com/stretchr/testify/assert"
+
+	"answering-machine/internal/audio"
+	"answering-machine/internal/transcription"
+)
+
+// mockTranscribeAPI reports each of statuses in turn.
+type mockTranscribeAPI struct {
+	transcribeserviceiface.TranscribeServiceAPI
+
+	started  *transcribeservice.StartTranscriptionJobInput
+	conflict bool
+	statuses []string
+	polls    int
+}
+
+func (mock *mockTranscribeAPI) StartTranscriptionJobWithContext(ctx aws.Context, in *transcribeservice.StartTranscriptionJobInput, opts ...request.Option) (*transcribeservice.StartTranscriptionJobOutput, error) {
+	mock.started = in
+	if mock.conflict {
+		return nil, awserr.New(transcribeservice.ErrCodeConflictException, "job exists", nil)
+	}
+
+	return &transcribeservice.StartTranscriptionJobOutput{}, nil
+}
+
+func (mock *mockTranscribeAPI) GetTranscriptionJobWithContext(ctx aws.Context, in *transcribeservice.GetTranscriptionJobInput, opts ...request.Option) (*transcribeservice.GetTranscriptionJobOutput, error) {
+	status := mock.statuses[mock.polls]
+	mock.polls++
+
+	return &transcribeservice.GetTranscriptionJobOutput{
+		TranscriptionJob: &transcribeservice.TranscriptionJob{
+			TranscriptionJobName:   in.TranscriptionJobName,
+			TranscriptionJobStatus: aws.String(status),
+			LanguageCode:           aws.String("en-GB"),
+			FailureReason:          aws.String("unsupported media"),
+			Transcript: &transcribeservice.Transcript{
+				TranscriptFileUri: aws.String("https://s3.eu-west-2.amazonaws.com/transcripts/job.json"),
+			},
+		},
+	}, nil
+}
+
+// transcriptJSON is an abridged transcript, with punctuation items that
+// have no times.
+const transcriptJSON = `{"results":{
+	"transcripts":[{"transcript":"Hi, it's Sam."}],
+	"items":[
+		{"start_time":"0.04","end_time":"0.3","alternatives":[{"confidence":"1.0","content":"Hi"}],"type":"pronunciation"},
+		{"alternatives":[{"confidence":"0.0","content":","}],"type":"punctuation"},
+		{"start_time":"0.3","end_time":"0.55","alternatives":[{"confidence":"0.5","content":"it's"}],"type":"pronunciation"},
+		{"start_time":"0.55","end_time":"1.0","alternatives":[{"confidence":"0.75","content":"Sam"}],"type":"pronunciation"},
+		{"alternatives":[{"confidence":"0.0","content":"."}],"type":"punctuation"}
+	]
+}}`
+
+type mockHTTPClient struct{}
+
+func (mockHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(bytes.NewBufferString(transcriptJSON)),
+	}, nil
+}
+
+func TestTranscribe(t *testing.T) {
+	request := transcription.Request{
+		Bucket:   "recordings",
+		Key:      "mailbox/+441632960000/2026/10/19/RE123.mp3",
+		Language: "en-GB",
+	}
+
+	t.Run("Waits for the job", func(t *testing.T) {
+		api := &mockTranscribeAPI{statuses: []string{"QUEUED", "IN_PROGRESS", "COMPLETED"}}
+		transcriber := NewTranscriber(api, mockHTTPClient{})
+		transcriber.poll = 0
+
+		transcript, err := transcriber.Transcribe(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.Equal(t, transcription.Transcript{
+			Text: "Hi, it's Sam.",
+			Segments: []transcription.Segment{{
+				Text:       "Hi, it's Sam.",
+				Confidence: 0.75,
+				Words: []transcription.Word{
+					{Word: "Hi", Start: 0.04, End: 0.3},
+					{Word: "it's", Start: 0.3, End: 0.55},
+					{Word: "Sam", Start: 0.55, End: 1},
+				},
+			}},
+			Language: "en-GB",
+			Backend:  Name,
+		}, transcript)
+		assert.Equal(t, "answering-machine-mailbox-441632960000-2026-10-19-RE123", *api.started.TranscriptionJobName)
+		assert.Equal(t, "s3://recordings/mailbox/+441632960000/2026/10/19/RE123.mp3", *api.started.Media.MediaFileUri)
+		assert.Equal(t, "mp3", *api.started.MediaFormat)
+		assert.Nil(t, api.started.MediaSampleRateHertz)
+		assert.Equal(t, 3, api.polls)
+	})
+
+	t.Run("Uses the probed format", func(t *testing.T) {
+		api := &mockTranscribeAPI{statuses: []string{"COMPLETED"}}
+		wav := request
+		wav.Format = audio.Format{Codec: audio.CodecLinear16, SampleRate: 8000, Channels: 1}
+
+		_, err := NewTranscriber(api, mockHTTPClient{}).Transcribe(context.Background(), wav)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "wav", *api.started.MediaFormat)
+		assert.Equal(t, int64(8000), *api.started.MediaSampleRateHertz)
+	})
+
+	t.Run("Picks up a job already started", func(t *testing.T) {
+		api := &mockTranscribeAPI{conflict: true, statuses: []string{"COMPLETED"}}
+
+		transcript, err := NewTranscriber(api, mockHTTPClient{}).Transcribe(context.Background(), request)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Hi, it's Sam.", transcript.Text)
+	})
+
+	t.Run("Hands off a job still running", func(t *testing.T) {
+		api := &mockTranscribeAPI{statuses: []string{"IN_PROGRESS"}}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := NewTranscriber(api, mockHTTPClient{}).Transcribe(ctx, request)
+
+		assert.Equal(t, &transcription.PendingError{Operation: "answering-machine-mailbox-441632960000-2026-10-19-RE123"}, err)
+	})
+
+	t.Run("Resumes a job", func(t *testing.T) {
+		api := &mockTranscribeAPI{statuses: []string{"COMPLETED"}}
+		resumed := request
+		resumed.Operation = "answering-machine-mailbox-441632960000-2026-10-19-RE123"
+
+		transcript, err := NewTranscriber(api, mockHTTPClient{}).Transcribe(context.Background(), resumed)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Hi, it's Sam.", transcript.Text)
+		assert.Nil(t, api.started)
+	})
+
+	t.Run("Failed job", func(t *testing.T) {
+		api := &mockTranscribeAPI{statuses: []string{"FAILED"}}
+
+		_, err := NewTranscriber(api, mockHTTPClient{}).Transcribe(context.Background(), request)
+
+		assert.EqualError(t, err, "transcription job answering-machine-mailbox-441632960000-2026-10-19-RE123 failed: unsupported media")
+	})
+}
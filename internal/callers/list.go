@@ -0,0 +1,151 @@
+// Package callers matches caller numbers against lists of E.164 numbers and
+// prefix patterns kept in DynamoDB, such as the caller blocklist.
+package callers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// KeyAttribute is the hash key of every caller list table.
+const KeyAttribute = "Pattern"
+
+var patternRegexp = regexp.MustCompile(`^\+[0-9]{1,15}\*?$`)
+
+// List is a caller list table. Entries are either a full E.164 number or a
+// prefix ending in "*", so "+4470*" matches every number starting +4470.
+type List struct {
+	dynamodb  dynamodbiface.DynamoDBAPI
+	tableName string
+}
+
+// NewList returns the list stored in tableName.
+func NewList(dynamodb dynamodbiface.DynamoDBAPI, tableName string) *List {
+	return &List{
+		dynamodb:  dynamodb,
+		tableName: tableName,
+	}
+}
+
+// ValidatePattern checks pattern is an E.164 number or prefix pattern.
+func ValidatePattern(pattern string) error {
+	if !patternRegexp.MatchString(pattern) {
+		return fmt.Errorf("invalid pattern %q, expected an E.164 number like +447700900123 or a prefix like +4470*", pattern)
+	}
+
+	return nil
+}
+
+// Patterns returns every entry that would match number: the number itself
+// and each of its prefixes as a pattern.
+func Patterns(number string) []string {
+	if !strings.HasPrefix(number, "+") {
+		return nil
+	}
+
+	patterns := []string{number}
+	for i := len(number); i > 1; i-- {
+		patterns = append(patterns, number[:i]+"*")
+	}
+
+	return patterns
+}
+
+// Contains reports whether number matches any entry in the list. Callers
+// that withhold their number never match.
+func (list *List) Contains(ctx context.Context, number string) (bool, error) {
+	patterns := Patterns(number)
+	if len(patterns) == 0 {
+		return false, nil
+	}
+
+	keys := make([]map[string]*dynamodb.AttributeValue, 0, len(patterns))
+	for _, pattern := range patterns {
+		keys = append(keys, map[string]*dynamodb.AttributeValue{
+			KeyAttribute: {
+				S: aws.String(pattern),
+			},
+		})
+	}
+
+	requestItems := map[string]*dynamodb.KeysAndAttributes{
+		list.tableName: {
+			Keys:                 keys,
+			ProjectionExpression: aws.String(KeyAttribute),
+		},
+	}
+
+	for len(requestItems) > 0 {
+		result, err := list.dynamodb.BatchGetItemWithContext(ctx, &dynamodb.BatchGetItemInput{
+			RequestItems: requestItems,
+		})
+		if err != nil {
+			return false, err
+		}
+
+		if len(result.Responses[list.tableName]) > 0 {
+			return true, nil
+		}
+
+		requestItems = result.UnprocessedKeys
+	}
+
+	return false, nil
+}
+
+// Add puts pattern on the list.
+func (list *List) Add(ctx context.Context, pattern string) error {
+	err := ValidatePattern(pattern)
+	if err != nil {
+		return err
+	}
+
+	_, err = list.dynamodb.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(list.tableName),
+		Item: map[string]*dynamodb.AttributeValue{
+			KeyAttribute: {
+				S: aws.String(pattern),
+			},
+		},
+	})
+
+	return err
+}
+
+// Remove takes pattern off the list.
+func (list *List) Remove(ctx context.Context, pattern string) error {
+	_, err := list.dynamodb.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(list.tableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			KeyAttribute: {
+				S: aws.String(pattern),
+			},
+		},
+	})
+
+	return err
+}
+
+// Entries returns every pattern on the list.
+func (list *List) Entries(ctx context.Context) ([]string, error) {
+	var entries []string
+
+	err := list.dynamodb.ScanPagesWithContext(ctx, &dynamodb.ScanInput{
+		TableName:            aws.String(list.tableName),
+		ProjectionExpression: aws.String(KeyAttribute),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			entries = append(entries, aws.StringValue(item[KeyAttribute].S))
+		}
+
+		return true
+	})
+
+	return entries, err
+}
@@ -0,0 +1,69 @@
+package callers
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+)
+
+type mock struct {
+	dynamodbiface.DynamoDBAPI
+
+	entries map[string]bool
+}
+
+func (mock mock) BatchGetItemWithContext(ctx aws.Context, in *dynamodb.BatchGetItemInput, opts ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	out := &dynamodb.BatchGetItemOutput{
+		Responses: map[string][]map[string]*dynamodb.AttributeValue{},
+	}
+
+	for table, keys := range in.RequestItems {
+		for _, key := range keys.Keys {
+			if mock.entries[*key[KeyAttribute].S] {
+				out.Responses[table] = append(out.Responses[table], key)
+			}
+		}
+	}
+
+	return out, nil
+}
+
+func TestPatterns(t *testing.T) {
+	assert.Equal(t, []string{"+4412", "+4412*", "+441*", "+44*", "+4*"}, Patterns("+4412"))
+	assert.Empty(t, Patterns("anonymous"))
+}
+
+func TestValidatePattern(t *testing.T) {
+	assert.NoError(t, ValidatePattern("+447700900123"))
+	assert.NoError(t, ValidatePattern("+4470*"))
+	assert.Error(t, ValidatePattern("07700900123"))
+	assert.Error(t, ValidatePattern("+44*70"))
+}
+
+func TestContains(t *testing.T) {
+	list := NewList(mock{
+		entries: map[string]bool{
+			"+447700900123": true,
+			"+1900*":        true,
+		},
+	}, "blocklist")
+
+	cases := map[string]bool{
+		"+447700900123": true,
+		"+447700900124": false,
+		"+19005550100":  true,
+		"+18005550100":  false,
+		"":              false,
+	}
+
+	for number, expected := range cases {
+		contains, err := list.Contains(aws.BackgroundContext(), number)
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected, contains, number)
+	}
+}
@@ -0,0 +1,51 @@
+// Package carrier picks the telephony provider a deployment uses, so the
+// handlers don't depend on any one of them.
+package carrier
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"answering-machine/internal/telephony"
+	"answering-machine/internal/telnyx"
+	"answering-machine/internal/twilio"
+	"answering-machine/internal/vonage"
+)
+
+// FromEnv returns the provider named by TELEPHONY_PROVIDER, Twilio if it
+// isn't set, configured from that provider's credential variables.
+func FromEnv(httpClient telephony.HTTPClient) (telephony.Provider, error) {
+	switch name := os.Getenv("TELEPHONY_PROVIDER"); name {
+	case "", twilio.Name:
+		return twilio.NewProvider(os.Getenv("TWILIO_ACCOUNT_SID"), os.Getenv("TWILIO_AUTH_TOKEN"), httpClient), nil
+	case vonage.Name:
+		privateKey, err := vonage.ParsePrivateKey([]byte(os.Getenv("VONAGE_PRIVATE_KEY")))
+		if err != nil {
+			return nil, fmt.Errorf("VONAGE_PRIVATE_KEY: %w", err)
+		}
+
+		return vonage.NewProvider(
+			os.Getenv("VONAGE_SIGNATURE_SECRET"),
+			os.Getenv("VONAGE_APPLICATION_ID"),
+			privateKey,
+			httpClient,
+		), nil
+	case telnyx.Name:
+		publicKey, err := telnyx.ParsePublicKey(os.Getenv("TELNYX_PUBLIC_KEY"))
+		if err != nil {
+			return nil, fmt.Errorf("TELNYX_PUBLIC_KEY: %w", err)
+		}
+
+		// Any long random string, it signs the callback URLs Render hands
+		// to Telnyx.
+		querySecret := os.Getenv("TELNYX_QUERY_SECRET")
+		if querySecret == "" {
+			return nil, errors.New("TELNYX_QUERY_SECRET is not set")
+		}
+
+		return telnyx.NewProvider(publicKey, os.Getenv("TELNYX_API_KEY"), querySecret, httpClient), nil
+	default:
+		return nil, fmt.Errorf("unknown TELEPHONY_PROVIDER %q", name)
+	}
+}
@@ -0,0 +1,49 @@
+package carrier
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromEnv(t *testing.T) {
+	defer os.Unsetenv("TELEPHONY_PROVIDER")
+
+	t.Run("Defaults to Twilio", func(t *testing.T) {
+		os.Unsetenv("TELEPHONY_PROVIDER")
+
+		provider, err := FromEnv(nil)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "twilio", provider.Name())
+	})
+
+	t.Run("Unknown provider", func(t *testing.T) {
+		os.Setenv("TELEPHONY_PROVIDER", "carrier-pigeon")
+
+		_, err := FromEnv(nil)
+
+		assert.EqualError(t, err, `unknown TELEPHONY_PROVIDER "carrier-pigeon"`)
+	})
+
+	t.Run("Telnyx needs a public key", func(t *testing.T) {
+		os.Setenv("TELEPHONY_PROVIDER", "telnyx")
+		os.Setenv("TELNYX_PUBLIC_KEY", "")
+
+		_, err := FromEnv(nil)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("Telnyx needs a query secret", func(t *testing.T) {
+		defer os.Unsetenv("TELNYX_PUBLIC_KEY")
+		os.Setenv("TELEPHONY_PROVIDER", "telnyx")
+		os.Setenv("TELNYX_PUBLIC_KEY", "11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=")
+		os.Setenv("TELNYX_QUERY_SECRET", "")
+
+		_, err := FromEnv(nil)
+
+		assert.EqualError(t, err, "TELNYX_QUERY_SECRET is not set")
+	})
+}
@@ -0,0 +1,191 @@
+// Package googlespeech transcribes recordings with Google Cloud
+// Speech-to-Text, sending the audio with the request. Recordings up to a
+// minute are recognised synchronously, longer ones with a long-running
+// operation, which takes inline audio of up to 10MB.
+package googlespeech
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	speech "cloud.google.com/go/speech/apiv1p1beta1"
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/googleapis/gax-go/v2"
+	speechpb "google.golang.org/genproto/googleapis/cloud/speech/v1p1beta1"
+
+	"answering-machine/internal/audio"
+	"answering-machine/internal/transcription"
+)
+
+// Name identifies Google on stored transcripts and in TRANSCRIBER.
+const Name = "google"
+
+// syncLimit is the longest recording sent to Recognize, which rejects
+// more than a minute of audio. Webhook durations are rounded, so it leaves
+// some slack.
+const syncLimit = 55 * time.Second
+
+// encodings are the recognition encodings of the codecs audio.Probe
+// reports.
+var encodings = map[string]speechpb.RecognitionConfig_AudioEncoding{
+	audio.CodecMP3:      speechpb.RecognitionConfig_MP3,
+	audio.CodecLinear16: speechpb.RecognitionConfig_LINEAR16,
+	audio.CodecMulaw:    speechpb.RecognitionConfig_MULAW,
+}
+
+// extensionEncodings are the encodings of recordings Probe couldn't
+// identify, or archived before it existed, by their key's extension.
+// Recordings are archived as MP3, so that's assumed for anything else.
+var extensionEncodings = map[string]speechpb.RecognitionConfig_AudioEncoding{
+	".mp3": speechpb.RecognitionConfig_MP3,
+	".wav": speechpb.RecognitionConfig_LINEAR16,
+}
+
+// Recognizer is the part of the Speech-to-Text client the Transcriber
+// uses.
+type Recognizer interface {
+	Recognize(ctx context.Context, req *speechpb.RecognizeRequest, opts ...gax.CallOption) (*speechpb.RecognizeResponse, error)
+	LongRunningRecognize(ctx context.Context, req *speechpb.LongRunningRecognizeRequest, opts ...gax.CallOption) (Operation, error)
+	LongRunningRecognizeOperation(name string) Operation
+}
+
+// Operation is a long-running recognition.
+type Operation interface {
+	Wait(ctx context.Context, opts ...gax.CallOption) (*speechpb.LongRunningRecognizeResponse, error)
+	Name() string
+}
+
+// client adapts the Speech-to-Text client's operations to Operation.
+type client struct {
+	*speech.Client
+}
+
+func (client client) LongRunningRecognize(ctx context.Context, req *speechpb.LongRunningRecognizeRequest, opts ...gax.CallOption) (Operation, error) {
+	return client.Client.LongRunningRecognize(ctx, req, opts...)
+}
+
+func (client client) LongRunningRecognizeOperation(name string) Operation {
+	return client.Client.LongRunningRecognizeOperation(name)
+}
+
+// Transcriber is the Google transcription.Transcriber.
+type Transcriber struct {
+	client Recognizer
+}
+
+// NewTranscriber returns a Transcriber that uses speechClient.
+func NewTranscriber(speechClient *speech.Client) *Transcriber {
+	return &Transcriber{client: client{speechClient}}
+}
+
+// Transcribe recognises the speech in request.Audio. A long-running
+// recognition still going when ctx is done is returned as a
+// transcription.PendingError.
+func (transcriber *Transcriber) Transcribe(ctx context.Context, request transcription.Request) (transcription.Transcript, error) {
+	encoding, ok := encodings[request.Format.Codec]
+	if request.Format.Codec == "" {
+		// Without a probed format the rate is left for Google to work out.
+		encoding, ok = extensionEncodings[strings.ToLower(path.Ext(request.Key))]
+		if !ok {
+			encoding, ok = speechpb.RecognitionConfig_MP3, true
+		}
+	}
+	if !ok {
+		return transcription.Transcript{}, fmt.Errorf("can't recognise audio in format %q", request.Format.Codec)
+	}
+
+	config := &speechpb.RecognitionConfig{
+		Encoding:          encoding,
+		SampleRateHertz:   int32(request.Format.SampleRate),
+		AudioChannelCount: int32(request.Format.Channels),
+		LanguageCode:      request.Language,
+		// Google picks the most likely of these and LanguageCode.
+		AlternativeLanguageCodes: request.AlternativeLanguages,
+		// Each word's time is stored with the transcript.
+		EnableWordTimeOffsets: true,
+	}
+	content := &speechpb.RecognitionAudio{
+		AudioSource: &speechpb.RecognitionAudio_Content{Content: request.Audio},
+	}
+
+	if request.Operation == "" && request.Duration <= syncLimit {
+		response, err := transcriber.client.Recognize(ctx, &speechpb.RecognizeRequest{
+			Config: config,
+			Audio:  content,
+		})
+		if err != nil {
+			return transcription.Transcript{}, err
+		}
+
+		return transcript(request.Language, response.Results), nil
+	}
+
+	var operation Operation
+	if request.Operation != "" {
+		operation = transcriber.client.LongRunningRecognizeOperation(request.Operation)
+	} else {
+		var err error
+		operation, err = transcriber.client.LongRunningRecognize(ctx, &speechpb.LongRunningRecognizeRequest{
+			Config: config,
+			Audio:  content,
+		})
+		if err != nil {
+			return transcription.Transcript{}, err
+		}
+	}
+
+	response, err := operation.Wait(ctx)
+	if ctx.Err() != nil {
+		return transcription.Transcript{}, &transcription.PendingError{Operation: operation.Name()}
+	}
+	if err != nil {
+		return transcription.Transcript{}, err
+	}
+
+	return transcript(request.Language, response.Results), nil
+}
+
+// transcript assembles the most likely alternative of each result, a
+// consecutive part of the recording. Its language is the one detected in
+// the first result, or language if none was.
+func transcript(language string, results []*speechpb.SpeechRecognitionResult) transcription.Transcript {
+	detected := ""
+	var segments []transcription.Segment
+	for _, result := range results {
+		if len(result.Alternatives) == 0 {
+			continue
+		}
+
+		if detected == "" {
+			detected = result.LanguageCode
+		}
+
+		alternative := result.Alternatives[0]
+		segment := transcription.Segment{
+			Text:       alternative.Transcript,
+			Confidence: float64(alternative.Confidence),
+		}
+		for _, word := range alternative.Words {
+			segment.Words = append(segment.Words, transcription.Word{
+				Word:  word.Word,
+				Start: seconds(word.StartTime),
+				End:   seconds(word.EndTime),
+			})
+		}
+
+		segments = append(segments, segment)
+	}
+
+	if detected == "" {
+		detected = language
+	}
+
+	return transcription.NewTranscript(Name, detected, segments)
+}
+
+func seconds(offset *duration.Duration) float64 {
+	return float64(offset.GetSeconds()) + float64(offset.GetNanos())/1e9
+}
@@ -0,0 +1,242 @@
+package googlespeech
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/duration"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/stretchr/testify/assert"
+	speechpb "google.golang.org/genproto/googleapis/cloud/speech/v1p1beta1"
+
+	"answering-machine/internal/audio"
+	"answering-machine/internal/transcription"
+)
+
+type mockRecognizer struct {
+	request          *speechpb.RecognizeRequest
+	response         *speechpb.RecognizeResponse
+	longRequest      *speechpb.LongRunningRecognizeRequest
+	operation        *mockOperation
+	resumedOperation string
+}
+
+func (mock *mockRecognizer) Recognize(ctx context.Context, req *speechpb.RecognizeRequest, opts ...gax.CallOption) (*speechpb.RecognizeResponse, error) {
+	mock.request = req
+
+	return mock.response, nil
+}
+
+func (mock *mockRecognizer) LongRunningRecognize(ctx context.Context, req *speechpb.LongRunningRecognizeRequest, opts ...gax.CallOption) (Operation, error) {
+	mock.longRequest = req
+
+	return mock.operation, nil
+}
+
+func (mock *mockRecognizer) LongRunningRecognizeOperation(name string) Operation {
+	mock.resumedOperation = name
+
+	return mock.operation
+}
+
+// mockOperation finishes with response, or never if it is nil.
+type mockOperation struct {
+	response *speechpb.LongRunningRecognizeResponse
+}
+
+func (mock *mockOperation) Wait(ctx context.Context, opts ...gax.CallOption) (*speechpb.LongRunningRecognizeResponse, error) {
+	if mock.response == nil {
+		<-ctx.Done()
+
+		return nil, ctx.Err()
+	}
+
+	return mock.response, nil
+}
+
+func (mock *mockOperation) Name() string {
+	return "operation-1"
+}
+
+var results = []*speechpb.SpeechRecognitionResult{
+	{LanguageCode: "cy-gb", Alternatives: []*speechpb.SpeechRecognitionAlternative{
+		{
+			Transcript: "Hi, it's Sam.",
+			Confidence: 0.75,
+			Words: []*speechpb.WordInfo{
+				{Word: "Hi,", StartTime: &duration.Duration{}, EndTime: &duration.Duration{Nanos: 500000000}},
+				{Word: "it's", StartTime: &duration.Duration{Nanos: 500000000}, EndTime: &duration.Duration{Seconds: 1}},
+				{Word: "Sam.", StartTime: &duration.Duration{Seconds: 1}, EndTime: &duration.Duration{Seconds: 1, Nanos: 250000000}},
+			},
+		},
+		{Transcript: "Hi, it's Pam."},
+	}},
+	{Alternatives: []*speechpb.SpeechRecognitionAlternative{{Transcript: " Call me back.", Confidence: 0.5}}},
+	{},
+}
+
+var mp3 = audio.Format{Codec: audio.CodecMP3, SampleRate: 22050, Channels: 1}
+
+func TestTranscribe(t *testing.T) {
+	t.Run("Recognizes short recordings", func(t *testing.T) {
+		client := &mockRecognizer{
+			response: &speechpb.RecognizeResponse{Results: results},
+		}
+
+		transcript, err := (&Transcriber{client: client}).Transcribe(context.Background(), transcription.Request{
+			Audio:                []byte("audio"),
+			Format:               mp3,
+			Language:             "en-GB",
+			AlternativeLanguages: []string{"cy-GB", "pl-PL"},
+			Duration:             20 * time.Second,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, transcription.Transcript{
+			Text: "Hi, it's Sam. Call me back.",
+			Segments: []transcription.Segment{
+				{
+					Text:       "Hi, it's Sam.",
+					Confidence: 0.75,
+					Words: []transcription.Word{
+						{Word: "Hi,", Start: 0, End: 0.5},
+						{Word: "it's", Start: 0.5, End: 1},
+						{Word: "Sam.", Start: 1, End: 1.25},
+					},
+				},
+				{Text: "Call me back.", Confidence: 0.5},
+			},
+			Language: "cy-gb",
+			Backend:  Name,
+		}, transcript)
+		assert.Equal(t, "en-GB", client.request.Config.LanguageCode)
+		assert.Equal(t, []string{"cy-GB", "pl-PL"}, client.request.Config.AlternativeLanguageCodes)
+		assert.True(t, client.request.Config.EnableWordTimeOffsets)
+		assert.Equal(t, speechpb.RecognitionConfig_MP3, client.request.Config.Encoding)
+		assert.Equal(t, int32(22050), client.request.Config.SampleRateHertz)
+		assert.Equal(t, []byte("audio"), client.request.Audio.GetContent())
+		assert.Nil(t, client.longRequest)
+	})
+
+	t.Run("Waits for long recordings", func(t *testing.T) {
+		client := &mockRecognizer{
+			operation: &mockOperation{response: &speechpb.LongRunningRecognizeResponse{Results: results}},
+		}
+
+		transcript, err := (&Transcriber{client: client}).Transcribe(context.Background(), transcription.Request{
+			Audio:    []byte("audio"),
+			Format:   mp3,
+			Language: "en-GB",
+			Duration: 3 * time.Minute,
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Hi, it's Sam. Call me back.", transcript.Text)
+		assert.Equal(t, "en-GB", client.longRequest.Config.LanguageCode)
+		assert.Equal(t, "cy-gb", transcript.Language)
+		assert.Equal(t, []byte("audio"), client.longRequest.Audio.GetContent())
+		assert.Nil(t, client.request)
+	})
+
+	t.Run("Hands off recognitions still running", func(t *testing.T) {
+		client := &mockRecognizer{operation: &mockOperation{}}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := (&Transcriber{client: client}).Transcribe(ctx, transcription.Request{
+			Audio:    []byte("audio"),
+			Format:   mp3,
+			Duration: 3 * time.Minute,
+		})
+
+		assert.Equal(t, &transcription.PendingError{Operation: "operation-1"}, err)
+	})
+
+	t.Run("Resumes recognitions", func(t *testing.T) {
+		client := &mockRecognizer{
+			operation: &mockOperation{response: &speechpb.LongRunningRecognizeResponse{Results: results}},
+		}
+
+		transcript, err := (&Transcriber{client: client}).Transcribe(context.Background(), transcription.Request{
+			Audio:     []byte("audio"),
+			Format:    mp3,
+			Operation: "operation-1",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Hi, it's Sam. Call me back.", transcript.Text)
+		assert.Equal(t, "operation-1", client.resumedOperation)
+		assert.Nil(t, client.longRequest)
+	})
+
+	t.Run("Falls back to the requested language", func(t *testing.T) {
+		client := &mockRecognizer{
+			response: &speechpb.RecognizeResponse{Results: results[1:]},
+		}
+
+		transcript, err := (&Transcriber{client: client}).Transcribe(context.Background(), transcription.Request{
+			Audio:    []byte("audio"),
+			Format:   mp3,
+			Language: "en-GB",
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "en-GB", transcript.Language)
+	})
+
+	t.Run("Builds the config from the format", func(t *testing.T) {
+		client := &mockRecognizer{
+			response: &speechpb.RecognizeResponse{Results: results},
+		}
+
+		_, err := (&Transcriber{client: client}).Transcribe(context.Background(), transcription.Request{
+			Audio:  []byte("audio"),
+			Format: audio.Format{Codec: audio.CodecLinear16, SampleRate: 8000, Channels: 2},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, speechpb.RecognitionConfig_LINEAR16, client.request.Config.Encoding)
+		assert.Equal(t, int32(8000), client.request.Config.SampleRateHertz)
+		assert.Equal(t, int32(2), client.request.Config.AudioChannelCount)
+	})
+
+	t.Run("Unprobed recording", func(t *testing.T) {
+		client := &mockRecognizer{
+			response: &speechpb.RecognizeResponse{Results: results},
+		}
+
+		_, err := (&Transcriber{client: client}).Transcribe(context.Background(), transcription.Request{
+			Key:   "mailbox/+441632960000/2026/10/19/RE123.mp3",
+			Audio: []byte("audio"),
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, speechpb.RecognitionConfig_MP3, client.request.Config.Encoding)
+		assert.Equal(t, int32(0), client.request.Config.SampleRateHertz)
+		assert.Equal(t, int32(0), client.request.Config.AudioChannelCount)
+	})
+
+	t.Run("Unprobed WAV", func(t *testing.T) {
+		client := &mockRecognizer{
+			response: &speechpb.RecognizeResponse{Results: results},
+		}
+
+		_, err := (&Transcriber{client: client}).Transcribe(context.Background(), transcription.Request{
+			Key:   "mailbox/+441632960000/2026/10/19/RE123.WAV",
+			Audio: []byte("audio"),
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, speechpb.RecognitionConfig_LINEAR16, client.request.Config.Encoding)
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		_, err := (&Transcriber{client: &mockRecognizer{}}).Transcribe(context.Background(), transcription.Request{
+			Audio:  []byte("audio"),
+			Format: audio.Format{Codec: "flac"},
+		})
+
+		assert.EqualError(t, err, `can't recognise audio in format "flac"`)
+	})
+}
@@ -0,0 +1,184 @@
+// Package greetings keeps recorded greetings in S3, one for each mailbox
+// and schedule slot, and hands them to the carrier as presigned URLs.
+package greetings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+
+	"answering-machine/internal/schedule"
+)
+
+// Prefix is where greetings are kept in the bucket, as
+// greetings/<number>/<slot>.mp3 or .wav.
+const Prefix = "greetings/"
+
+// DefaultMailbox stands in for the number to upload a greeting used by
+// every number without its own.
+const DefaultMailbox = "default"
+
+// ErrNotFound is returned when no greeting has been uploaded.
+var ErrNotFound = errors.New("greeting not found")
+
+// contentTypes are the formats Twilio can <Play>.
+var contentTypes = map[string]string{
+	".mp3": "audio/mpeg",
+	".wav": "audio/wav",
+}
+
+// Greeting is an uploaded greeting.
+type Greeting struct {
+	Number string
+	Slot   schedule.Slot
+	Key    string
+}
+
+// Store is the greetings prefix of a bucket.
+type Store struct {
+	s3     s3iface.S3API
+	bucket string
+	expiry time.Duration
+}
+
+// NewStore returns the greetings in bucket, with URLs that expire after
+// expiry.
+func NewStore(s3 s3iface.S3API, bucket string, expiry time.Duration) *Store {
+	return &Store{
+		s3:     s3,
+		bucket: bucket,
+		expiry: expiry,
+	}
+}
+
+// Key is the object key of a greeting, ext is ".mp3" or ".wav".
+func Key(number string, slot schedule.Slot, ext string) string {
+	return Prefix + number + "/" + string(slot) + ext
+}
+
+// ContentType returns the type a greeting file is uploaded with, by its
+// extension.
+func ContentType(filename string) (string, error) {
+	contentType, ok := contentTypes[strings.ToLower(path.Ext(filename))]
+	if !ok {
+		return "", fmt.Errorf("unsupported greeting %q, expected an .mp3 or .wav file", filename)
+	}
+
+	return contentType, nil
+}
+
+// URL presigns the greeting for the first of slots that number has one
+// uploaded for.
+func (store *Store) URL(ctx context.Context, number string, slots ...schedule.Slot) (string, error) {
+	greetings, err := store.list(ctx, Prefix+number+"/")
+	if err != nil {
+		return "", err
+	}
+
+	for _, slot := range slots {
+		for _, greeting := range greetings {
+			if greeting.Slot != slot {
+				continue
+			}
+
+			request, _ := store.s3.GetObjectRequest(&s3.GetObjectInput{
+				Bucket: aws.String(store.bucket),
+				Key:    aws.String(greeting.Key),
+			})
+
+			return request.Presign(store.expiry)
+		}
+	}
+
+	return "", ErrNotFound
+}
+
+// List returns every uploaded greeting.
+func (store *Store) List(ctx context.Context) ([]Greeting, error) {
+	return store.list(ctx, Prefix)
+}
+
+func (store *Store) list(ctx context.Context, prefix string) ([]Greeting, error) {
+	var greetings []Greeting
+
+	err := store.s3.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(store.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			if greeting, ok := parseKey(aws.StringValue(object.Key)); ok {
+				greetings = append(greetings, greeting)
+			}
+		}
+
+		return true
+	})
+
+	return greetings, err
+}
+
+// parseKey reads the number and slot from a greeting's key, skipping
+// anything else under the prefix.
+func parseKey(key string) (Greeting, bool) {
+	parts := strings.Split(strings.TrimPrefix(key, Prefix), "/")
+	if len(parts) != 2 || !strings.HasPrefix(key, Prefix) {
+		return Greeting{}, false
+	}
+
+	ext := path.Ext(parts[1])
+	if _, ok := contentTypes[ext]; !ok {
+		return Greeting{}, false
+	}
+
+	return Greeting{
+		Number: parts[0],
+		Slot:   schedule.Slot(strings.TrimSuffix(parts[1], ext)),
+		Key:    key,
+	}, true
+}
+
+// Put uploads the greeting for number and slot, replacing one in another
+// format.
+func (store *Store) Put(ctx context.Context, number string, slot schedule.Slot, filename string, body io.ReadSeeker) error {
+	contentType, err := ContentType(filename)
+	if err != nil {
+		return err
+	}
+
+	err = store.Delete(ctx, number, slot)
+	if err != nil {
+		return err
+	}
+
+	_, err = store.s3.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(store.bucket),
+		Key:         aws.String(Key(number, slot, strings.ToLower(path.Ext(filename)))),
+		ContentType: aws.String(contentType),
+		Body:        body,
+	})
+
+	return err
+}
+
+// Delete removes the greeting for number and slot, whatever its format.
+func (store *Store) Delete(ctx context.Context, number string, slot schedule.Slot) error {
+	for ext := range contentTypes {
+		_, err := store.s3.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(store.bucket),
+			Key:    aws.String(Key(number, slot, ext)),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
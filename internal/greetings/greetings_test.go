@@ -0,0 +1,86 @@
+package greetings
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/assert"
+
+	"answering-machine/internal/schedule"
+)
+
+// mock lists keys, and presigns with a real client, which signs locally.
+type mock struct {
+	*s3.S3
+
+	keys []string
+}
+
+func (mock mock) ListObjectsV2PagesWithContext(ctx aws.Context, in *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool, opts ...request.Option) error {
+	page := &s3.ListObjectsV2Output{}
+	for _, key := range mock.keys {
+		if len(key) >= len(*in.Prefix) && key[:len(*in.Prefix)] == *in.Prefix {
+			page.Contents = append(page.Contents, &s3.Object{Key: aws.String(key)})
+		}
+	}
+	fn(page, true)
+
+	return nil
+}
+
+func TestURL(t *testing.T) {
+	client := s3.New(session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("eu-west-2"),
+		Credentials: credentials.NewStaticCredentials("AKID", "SECRET", ""),
+	})))
+
+	store := NewStore(mock{
+		S3: client,
+		keys: []string{
+			"greetings/+447700900999/open.mp3",
+			"greetings/+447700900999/closed.wav",
+			"greetings/+447700900999/notes.txt",
+		},
+	}, "recordings", 10*time.Minute)
+
+	t.Run("Presigns the greeting", func(t *testing.T) {
+		greetingURL, err := store.URL(aws.BackgroundContext(), "+447700900999", schedule.SlotOpen)
+		assert.NoError(t, err)
+
+		parsed, err := url.Parse(greetingURL)
+		assert.NoError(t, err)
+		assert.Equal(t, "/greetings/+447700900999/open.mp3", parsed.Path)
+		assert.Equal(t, "600", parsed.Query().Get("X-Amz-Expires"))
+		assert.NotEmpty(t, parsed.Query().Get("X-Amz-Signature"))
+	})
+
+	t.Run("Falls back through slots", func(t *testing.T) {
+		greetingURL, err := store.URL(aws.BackgroundContext(), "+447700900999", schedule.SlotHoliday, schedule.SlotClosed)
+		assert.NoError(t, err)
+		assert.Contains(t, greetingURL, "/greetings/%2B447700900999/closed.wav?")
+	})
+
+	t.Run("Missing greeting", func(t *testing.T) {
+		_, err := store.URL(aws.BackgroundContext(), "+447700900123", schedule.SlotOpen)
+		assert.Equal(t, ErrNotFound, err)
+	})
+}
+
+func TestContentType(t *testing.T) {
+	contentType, err := ContentType("hello.MP3")
+	assert.NoError(t, err)
+	assert.Equal(t, "audio/mpeg", contentType)
+
+	contentType, err = ContentType("hello.wav")
+	assert.NoError(t, err)
+	assert.Equal(t, "audio/wav", contentType)
+
+	_, err = ContentType("hello.ogg")
+	assert.Error(t, err)
+}
@@ -0,0 +1,138 @@
+// Package lambdahttp serves API Gateway proxy handlers over net/http, so
+// the call flow can run on a laptop behind a tunnel.
+package lambdahttp
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"answering-machine/internal/telephony"
+)
+
+// ProxyHandler is the signature of a Lambda proxy integration handler.
+type ProxyHandler func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// Handler adapts handler to net/http. The request has no stage, so URLs
+// the handler rebuilds match the ones the tunnel was asked for. Errors
+// become 502s as they would behind API Gateway.
+func Handler(handler ProxyHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request, err := NewRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		response, err := handler(r.Context(), request)
+		if err != nil {
+			log.Printf("%s %s: %s", r.Method, r.URL.Path, err)
+			http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+			return
+		}
+
+		err = WriteResponse(w, response)
+		if err != nil {
+			log.Printf("%s %s: writing response: %s", r.Method, r.URL.Path, err)
+		}
+	})
+}
+
+// NewRequest converts an HTTP request to the proxy request API Gateway
+// would have sent.
+func NewRequest(r *http.Request) (events.APIGatewayProxyRequest, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+
+	request := events.APIGatewayProxyRequest{
+		Resource:                        r.URL.Path,
+		Path:                            r.URL.Path,
+		HTTPMethod:                      r.Method,
+		Headers:                         map[string]string{"Host": r.Host},
+		MultiValueHeaders:               map[string][]string{"Host": {r.Host}},
+		QueryStringParameters:           map[string]string{},
+		MultiValueQueryStringParameters: map[string][]string{},
+		RequestContext: events.APIGatewayProxyRequestContext{
+			DomainName: r.Host,
+			HTTPMethod: r.Method,
+		},
+	}
+
+	for name, values := range r.Header {
+		request.Headers[name] = values[len(values)-1]
+		request.MultiValueHeaders[name] = values
+	}
+
+	// Tunnels say which scheme the provider used, direct requests are
+	// whatever this server is.
+	if r.Header.Get("X-Forwarded-Proto") == "" && r.TLS == nil {
+		request.Headers["X-Forwarded-Proto"] = "http"
+		request.MultiValueHeaders["X-Forwarded-Proto"] = []string{"http"}
+	}
+
+	// Signatures cover the query as sent, which the parameters lose. The
+	// client's own copy of the header, in any case, is dropped first.
+	for name := range request.Headers {
+		if strings.EqualFold(name, telephony.RawQueryHeader) {
+			delete(request.Headers, name)
+			delete(request.MultiValueHeaders, name)
+		}
+	}
+	if r.URL.RawQuery != "" {
+		request.Headers[telephony.RawQueryHeader] = r.URL.RawQuery
+		request.MultiValueHeaders[telephony.RawQueryHeader] = []string{r.URL.RawQuery}
+	}
+
+	for name, values := range r.URL.Query() {
+		request.QueryStringParameters[name] = values[len(values)-1]
+		request.MultiValueQueryStringParameters[name] = values
+	}
+
+	if utf8.Valid(body) {
+		request.Body = string(body)
+	} else {
+		request.Body = base64.StdEncoding.EncodeToString(body)
+		request.IsBase64Encoded = true
+	}
+
+	return request, nil
+}
+
+// WriteResponse writes a proxy response to w.
+func WriteResponse(w http.ResponseWriter, response events.APIGatewayProxyResponse) error {
+	for name, value := range response.Headers {
+		w.Header().Set(name, value)
+	}
+	for name, values := range response.MultiValueHeaders {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+
+	body := []byte(response.Body)
+	if response.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(response.Body)
+		if err != nil {
+			return err
+		}
+		body = decoded
+	}
+
+	statusCode := response.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	w.WriteHeader(statusCode)
+
+	_, err := w.Write(body)
+
+	return err
+}
@@ -0,0 +1,83 @@
+package lambdahttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+
+	"answering-machine/internal/telephony"
+)
+
+func TestHandler(t *testing.T) {
+	t.Run("Converts request and response", func(t *testing.T) {
+		var got events.APIGatewayProxyRequest
+		handler := Handler(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			got = request
+
+			return events.APIGatewayProxyResponse{
+				StatusCode: 201,
+				Headers:    map[string]string{"Content-Type": "text/xml"},
+				Body:       "<Response></Response>",
+			}, nil
+		})
+
+		body := url.Values{"CallSid": {"CA123"}}.Encode()
+		r := httptest.NewRequest(http.MethodPost, "/webhook?Step=recorded", strings.NewReader(body))
+		r.Host = "abc.ngrok.io"
+		r.Header.Set("X-Forwarded-Proto", "https")
+		r.Header.Set("X-Twilio-Signature", "sig")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, 201, w.Code)
+		assert.Equal(t, "text/xml", w.Header().Get("Content-Type"))
+		assert.Equal(t, "<Response></Response>", w.Body.String())
+
+		assert.Equal(t, "https://abc.ngrok.io/webhook?Step=recorded", telephony.RequestURL(got))
+		assert.Equal(t, "sig", telephony.Header(got, "X-Twilio-Signature"))
+		assert.Equal(t, "recorded", got.QueryStringParameters["Step"])
+		assert.Equal(t, body, got.Body)
+		assert.Equal(t, http.MethodPost, got.HTTPMethod)
+	})
+
+	t.Run("Direct requests are plain HTTP", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/voice", nil)
+		r.Host = "localhost:8080"
+
+		request, err := NewRequest(r)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "http://localhost:8080/voice", telephony.RequestURL(request))
+	})
+
+	t.Run("Keeps the query as sent", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/voice?Step=dialled&Caller=%2b441632960000", nil)
+		r.Host = "abc.ngrok.io"
+		r.Header.Set("X-Forwarded-Proto", "https")
+		r.Header.Set("x-raw-query", "Caller=%2B441632960999&Step=dialled")
+
+		request, err := NewRequest(r)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "https://abc.ngrok.io/voice?Step=dialled&Caller=%2b441632960000", telephony.RequestURL(request))
+	})
+
+	t.Run("Errors are bad gateways", func(t *testing.T) {
+		handler := Handler(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			return events.APIGatewayProxyResponse{StatusCode: 400}, errors.New("boom")
+		})
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook", nil))
+
+		assert.Equal(t, 502, w.Code)
+	})
+}
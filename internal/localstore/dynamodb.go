@@ -0,0 +1,303 @@
+// Package localstore keeps the pipeline's tables and bucket in a directory,
+// so the handlers can run without AWS. Each item is a JSON file named
+// after its key, which makes it easy to look at what a call stored.
+package localstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// conditionTerms are the condition expression terms evaluate supports:
+// attribute_exists, attribute_not_exists and equality with a value.
+var (
+	attributeExists    = regexp.MustCompile(`^attribute_exists\((#?\w+)\)$`)
+	attributeNotExists = regexp.MustCompile(`^attribute_not_exists\((#?\w+)\)$`)
+	attributeEquals    = regexp.MustCompile(`^(#?\w+) = (:\w+)$`)
+)
+
+// DynamoDB implements the DynamoDB calls the handlers make against
+// <dir>/<table>/<key>.json. Calls it doesn't implement panic.
+type DynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+
+	dir  string
+	keys map[string]string
+	mu   sync.Mutex
+}
+
+// NewDynamoDB stores tables under dir. keys maps each table name to its
+// hash key attribute.
+func NewDynamoDB(dir string, keys map[string]string) *DynamoDB {
+	return &DynamoDB{
+		dir:  dir,
+		keys: keys,
+	}
+}
+
+// PutItemWithContext writes an item, if it meets any condition.
+func (db *DynamoDB) PutItemWithContext(ctx aws.Context, in *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	table := aws.StringValue(in.TableName)
+	keyName, ok := db.keys[table]
+	if !ok {
+		return nil, fmt.Errorf("localstore: unknown table %q", table)
+	}
+
+	key, ok := in.Item[keyName]
+	if !ok || key.S == nil {
+		return nil, fmt.Errorf("localstore: item has no %s", keyName)
+	}
+
+	existing, err := db.read(table, *key.S)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkCondition(in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues, existing)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.PutItemOutput{}, db.write(table, *key.S, in.Item)
+}
+
+// UpdateItemWithContext applies SET expressions, if the item meets any
+// condition.
+func (db *DynamoDB) UpdateItemWithContext(ctx aws.Context, in *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	table := aws.StringValue(in.TableName)
+	keyName, key, err := singleKey(in.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := db.read(table, key)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkCondition(in.ConditionExpression, in.ExpressionAttributeNames, in.ExpressionAttributeValues, item)
+	if err != nil {
+		return nil, err
+	}
+
+	if item == nil {
+		item = map[string]*dynamodb.AttributeValue{keyName: in.Key[keyName]}
+	}
+
+	expression := strings.TrimSpace(aws.StringValue(in.UpdateExpression))
+	if !strings.HasPrefix(expression, "SET ") {
+		return nil, fmt.Errorf("localstore: unsupported update %q", expression)
+	}
+	for _, assignment := range strings.Split(strings.TrimPrefix(expression, "SET "), ",") {
+		parts := strings.SplitN(assignment, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("localstore: unsupported update %q", expression)
+		}
+
+		name := strings.TrimSpace(parts[0])
+		if strings.HasPrefix(name, "#") {
+			name = aws.StringValue(in.ExpressionAttributeNames[name])
+		}
+
+		value, ok := in.ExpressionAttributeValues[strings.TrimSpace(parts[1])]
+		if !ok {
+			return nil, fmt.Errorf("localstore: unsupported update %q", expression)
+		}
+		item[name] = value
+	}
+
+	return &dynamodb.UpdateItemOutput{}, db.write(table, key, item)
+}
+
+// GetItemWithContext reads an item, returning no item if there isn't one.
+func (db *DynamoDB) GetItemWithContext(ctx aws.Context, in *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	_, key, err := singleKey(in.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := db.read(aws.StringValue(in.TableName), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamodb.GetItemOutput{Item: item}, nil
+}
+
+// BatchGetItemWithContext reads every requested item that exists.
+func (db *DynamoDB) BatchGetItemWithContext(ctx aws.Context, in *dynamodb.BatchGetItemInput, opts ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	out := &dynamodb.BatchGetItemOutput{
+		Responses: map[string][]map[string]*dynamodb.AttributeValue{},
+	}
+
+	for table, keys := range in.RequestItems {
+		for _, k := range keys.Keys {
+			_, key, err := singleKey(k)
+			if err != nil {
+				return nil, err
+			}
+
+			item, err := db.read(table, key)
+			if err != nil {
+				return nil, err
+			}
+			if item != nil {
+				out.Responses[table] = append(out.Responses[table], item)
+			}
+		}
+	}
+
+	return out, nil
+}
+
+// checkCondition evaluates a condition expression against item, nil if
+// there is none, returning the error DynamoDB would if it fails. Terms
+// may be joined with OR or AND, but not both; anything else is an error
+// rather than being ignored.
+func checkCondition(condition *string, names map[string]*string, values map[string]*dynamodb.AttributeValue, item map[string]*dynamodb.AttributeValue) error {
+	expression := strings.TrimSpace(aws.StringValue(condition))
+	if expression == "" {
+		return nil
+	}
+
+	operator := " OR "
+	if strings.Contains(expression, " AND ") {
+		operator = " AND "
+	}
+	if strings.Contains(expression, " OR ") && operator == " AND " {
+		return fmt.Errorf("localstore: unsupported condition %q", expression)
+	}
+
+	met := operator == " AND "
+	for _, term := range strings.Split(expression, operator) {
+		ok, err := evaluate(strings.TrimSpace(term), names, values, item)
+		if err != nil {
+			return fmt.Errorf("localstore: unsupported condition %q", expression)
+		}
+
+		if operator == " OR " {
+			met = met || ok
+		} else {
+			met = met && ok
+		}
+	}
+
+	if !met {
+		return awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	}
+
+	return nil
+}
+
+func evaluate(term string, names map[string]*string, values map[string]*dynamodb.AttributeValue, item map[string]*dynamodb.AttributeValue) (bool, error) {
+	name := func(name string) string {
+		if strings.HasPrefix(name, "#") {
+			return aws.StringValue(names[name])
+		}
+
+		return name
+	}
+
+	if match := attributeExists.FindStringSubmatch(term); match != nil {
+		_, ok := item[name(match[1])]
+
+		return ok, nil
+	}
+
+	if match := attributeNotExists.FindStringSubmatch(term); match != nil {
+		_, ok := item[name(match[1])]
+
+		return !ok, nil
+	}
+
+	if match := attributeEquals.FindStringSubmatch(term); match != nil {
+		value, ok := values[match[2]]
+		if !ok {
+			return false, fmt.Errorf("localstore: no value for %s", match[2])
+		}
+
+		return reflect.DeepEqual(item[name(match[1])], value), nil
+	}
+
+	return false, fmt.Errorf("localstore: unsupported term %q", term)
+}
+
+func singleKey(key map[string]*dynamodb.AttributeValue) (string, string, error) {
+	for name, value := range key {
+		if len(key) == 1 && value.S != nil {
+			return name, *value.S, nil
+		}
+	}
+
+	return "", "", fmt.Errorf("localstore: only single string keys are supported")
+}
+
+func (db *DynamoDB) path(table, key string) string {
+	return filepath.Join(db.dir, table, url.PathEscape(key)+".json")
+}
+
+func (db *DynamoDB) read(table, key string) (map[string]*dynamodb.AttributeValue, error) {
+	data, err := ioutil.ReadFile(db.path(table, key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var item map[string]interface{}
+	err = json.Unmarshal(data, &item)
+	if err != nil {
+		return nil, err
+	}
+
+	return dynamodbattribute.MarshalMap(item)
+}
+
+func (db *DynamoDB) write(table, key string, item map[string]*dynamodb.AttributeValue) error {
+	var plain map[string]interface{}
+	err := dynamodbattribute.UnmarshalMap(item, &plain)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(plain, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := db.path(table, key)
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
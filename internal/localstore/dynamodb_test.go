@@ -0,0 +1,139 @@
+package localstore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamoDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "localstore")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	db := NewDynamoDB(dir, map[string]string{"recordings": "RecordingSid", "status": "CallSid"})
+	ctx := aws.BackgroundContext()
+
+	t.Run("Conditional put", func(t *testing.T) {
+		in := &dynamodb.PutItemInput{
+			TableName: aws.String("recordings"),
+			Item: map[string]*dynamodb.AttributeValue{
+				"RecordingSid":      {S: aws.String("RE123")},
+				"RecordingDuration": {N: aws.String("14")},
+			},
+			ConditionExpression: aws.String("attribute_not_exists(RecordingSid)"),
+		}
+
+		_, err := db.PutItemWithContext(ctx, in)
+		assert.NoError(t, err)
+
+		_, err = db.PutItemWithContext(ctx, in)
+		aerr, ok := err.(awserr.Error)
+		assert.True(t, ok)
+		assert.Equal(t, dynamodb.ErrCodeConditionalCheckFailedException, aerr.Code())
+
+		out, err := db.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+			TableName: aws.String("recordings"),
+			Key:       map[string]*dynamodb.AttributeValue{"RecordingSid": {S: aws.String("RE123")}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, in.Item, out.Item)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		_, err := db.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+			TableName:        aws.String("status"),
+			Key:              map[string]*dynamodb.AttributeValue{"CallSid": {S: aws.String("CA123")}},
+			UpdateExpression: aws.String("SET RecordingSid = :sid, RecordingStatus = :status"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":sid":    {S: aws.String("RE123")},
+				":status": {S: aws.String("absent")},
+			},
+		})
+		assert.NoError(t, err)
+
+		out, err := db.BatchGetItemWithContext(ctx, &dynamodb.BatchGetItemInput{
+			RequestItems: map[string]*dynamodb.KeysAndAttributes{
+				"status": {
+					Keys: []map[string]*dynamodb.AttributeValue{
+						{"CallSid": {S: aws.String("CA123")}},
+						{"CallSid": {S: aws.String("CA456")}},
+					},
+				},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []map[string]*dynamodb.AttributeValue{
+			{
+				"CallSid":         {S: aws.String("CA123")},
+				"RecordingSid":    {S: aws.String("RE123")},
+				"RecordingStatus": {S: aws.String("absent")},
+			},
+		}, out.Responses["status"])
+	})
+	t.Run("Conditional update", func(t *testing.T) {
+		update := func(callSid, status string) error {
+			_, err := db.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+				TableName:           aws.String("status"),
+				Key:                 map[string]*dynamodb.AttributeValue{"CallSid": {S: aws.String(callSid)}},
+				UpdateExpression:    aws.String("SET RecordingStatus = :status"),
+				ConditionExpression: aws.String("attribute_not_exists(RecordingStatus) OR RecordingStatus = :status"),
+				ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+					":status": {S: aws.String(status)},
+				},
+			})
+
+			return err
+		}
+
+		assert.NoError(t, update("CA789", "in-progress"))
+		assert.NoError(t, update("CA789", "in-progress"))
+
+		err := update("CA789", "completed")
+		aerr, ok := err.(awserr.Error)
+		assert.True(t, ok)
+		assert.Equal(t, dynamodb.ErrCodeConditionalCheckFailedException, aerr.Code())
+	})
+
+	t.Run("Update of a missing item", func(t *testing.T) {
+		_, err := db.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+			TableName:           aws.String("recordings"),
+			Key:                 map[string]*dynamodb.AttributeValue{"RecordingSid": {S: aws.String("RE999")}},
+			UpdateExpression:    aws.String("SET RecordingKey = :key"),
+			ConditionExpression: aws.String("attribute_exists(RecordingSid)"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":key": {S: aws.String("mailbox/RE999.mp3")},
+			},
+		})
+		aerr, ok := err.(awserr.Error)
+		assert.True(t, ok)
+		assert.Equal(t, dynamodb.ErrCodeConditionalCheckFailedException, aerr.Code())
+
+		out, err := db.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+			TableName: aws.String("recordings"),
+			Key:       map[string]*dynamodb.AttributeValue{"RecordingSid": {S: aws.String("RE999")}},
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, out.Item)
+	})
+
+	t.Run("Unsupported condition", func(t *testing.T) {
+		_, err := db.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+			TableName:           aws.String("status"),
+			Key:                 map[string]*dynamodb.AttributeValue{"CallSid": {S: aws.String("CA123")}},
+			UpdateExpression:    aws.String("SET RecordingStatus = :status"),
+			ConditionExpression: aws.String("RecordingDuration > :duration"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":status":   {S: aws.String("completed")},
+				":duration": {N: aws.String("0")},
+			},
+		})
+
+		assert.EqualError(t, err, `localstore: unsupported condition "RecordingDuration > :duration"`)
+	})
+}
@@ -0,0 +1,46 @@
+package localstore
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+)
+
+// Uploader writes uploads to <dir>/<bucket>/<key>.
+type Uploader struct {
+	s3manageriface.UploaderAPI
+
+	dir string
+}
+
+// NewUploader stores buckets under dir.
+func NewUploader(dir string) *Uploader {
+	return &Uploader{dir: dir}
+}
+
+// UploadWithContext writes the object's body, other fields are dropped.
+func (uploader *Uploader) UploadWithContext(ctx aws.Context, in *s3manager.UploadInput, opts ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
+	path := filepath.Join(uploader.dir, aws.StringValue(in.Bucket), filepath.FromSlash(aws.StringValue(in.Key)))
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(file, in.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3manager.UploadOutput{Location: "file://" + path}, nil
+}
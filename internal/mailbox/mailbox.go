@@ -0,0 +1,162 @@
+// Package mailbox holds the per-number settings of each person sharing the
+// deployment, keyed on the number that was dialled.
+package mailbox
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// KeyAttribute is the hash key of the mailbox table.
+const KeyAttribute = "Number"
+
+// How hang-ups, recordings too short or quiet to hold a message, are
+// notified.
+const (
+	HangUpsIgnore = "ignore"
+	HangUpsDigest = "digest"
+)
+
+// Mailbox is the owner and settings of a Twilio number.
+type Mailbox struct {
+	// Number is the E.164 number callers dial, the To of each callback.
+	Number string
+	// Email is where voicemails and notifications are sent.
+	Email string `dynamodbav:",omitempty"`
+	// Greeting replaces the deployment's spoken greeting.
+	Greeting string `dynamodbav:",omitempty"`
+	// Language is a BCP-47 code used for the greeting and transcription.
+	Language string `dynamodbav:",omitempty"`
+	// AlternativeLanguages are other BCP-47 codes callers may speak, which
+	// transcription picks between.
+	AlternativeLanguages []string `dynamodbav:",omitempty"`
+	// SkipMissedCalls stops notifications for calls without a message.
+	SkipMissedCalls bool `dynamodbav:",omitempty"`
+	// SkipAttachment sends the transcript without the recording attached.
+	SkipAttachment bool `dynamodbav:",omitempty"`
+	// DeleteRecordings removes recordings from the carrier once they are
+	// archived in the recordings bucket.
+	DeleteRecordings bool `dynamodbav:",omitempty"`
+	// OptionEmail sends calls where the caller chose a menu option, such
+	// as "urgent", to a comma separated list instead of Email.
+	OptionEmail map[string]string `dynamodbav:",omitempty"`
+	// HangUps is HangUpsDigest to list hang-ups in a daily digest, or
+	// HangUpsIgnore to drop them. They are never sent as voicemails.
+	HangUps string `dynamodbav:",omitempty"`
+}
+
+// Recipients returns the addresses a call with the menu option should be
+// sent to.
+func (mailbox Mailbox) Recipients(option string) []string {
+	list := mailbox.Email
+	if email, ok := mailbox.OptionEmail[option]; ok && option != "" && email != "" {
+		list = email
+	}
+
+	var recipients []string
+	for _, email := range strings.Split(list, ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			recipients = append(recipients, email)
+		}
+	}
+
+	return recipients
+}
+
+// ParseOptionEmail reads OptionEmail from "option=email,email;option=email".
+func ParseOptionEmail(value string) map[string]string {
+	optionEmail := map[string]string{}
+	for _, route := range strings.Split(value, ";") {
+		parts := strings.SplitN(route, "=", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) != "" {
+			optionEmail[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+
+	return optionEmail
+}
+
+// ParseLanguages reads AlternativeLanguages from "cy-GB,pl-PL".
+func ParseLanguages(value string) []string {
+	var languages []string
+	for _, language := range strings.Split(value, ",") {
+		if language = strings.TrimSpace(language); language != "" {
+			languages = append(languages, language)
+		}
+	}
+
+	return languages
+}
+
+// Store looks mailboxes up in DynamoDB.
+type Store struct {
+	dynamodb  dynamodbiface.DynamoDBAPI
+	tableName string
+	defaults  Mailbox
+}
+
+// NewStore returns a store for tableName. Settings a mailbox leaves empty,
+// and numbers without a mailbox, get the values in defaults.
+func NewStore(dynamodb dynamodbiface.DynamoDBAPI, tableName string, defaults Mailbox) *Store {
+	return &Store{
+		dynamodb:  dynamodb,
+		tableName: tableName,
+		defaults:  defaults,
+	}
+}
+
+// Get returns the mailbox for number.
+func (store *Store) Get(ctx context.Context, number string) (Mailbox, error) {
+	mailbox := Mailbox{}
+
+	if number != "" {
+		result, err := store.dynamodb.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+			TableName: aws.String(store.tableName),
+			Key: map[string]*dynamodb.AttributeValue{
+				KeyAttribute: {
+					S: aws.String(number),
+				},
+			},
+		})
+		if err != nil {
+			return mailbox, err
+		}
+
+		err = dynamodbattribute.UnmarshalMap(result.Item, &mailbox)
+		if err != nil {
+			return mailbox, err
+		}
+	}
+
+	mailbox.Number = number
+	if mailbox.Email == "" {
+		mailbox.Email = store.defaults.Email
+	}
+	if mailbox.Greeting == "" {
+		mailbox.Greeting = store.defaults.Greeting
+	}
+	if mailbox.Language == "" {
+		mailbox.Language = store.defaults.Language
+	}
+	if mailbox.AlternativeLanguages == nil {
+		mailbox.AlternativeLanguages = store.defaults.AlternativeLanguages
+	}
+	if mailbox.HangUps == "" {
+		mailbox.HangUps = store.defaults.HangUps
+	}
+	for option, email := range store.defaults.OptionEmail {
+		if _, ok := mailbox.OptionEmail[option]; !ok {
+			if mailbox.OptionEmail == nil {
+				mailbox.OptionEmail = map[string]string{}
+			}
+			mailbox.OptionEmail[option] = email
+		}
+	}
+
+	return mailbox, nil
+}
@@ -0,0 +1,88 @@
+package mailbox
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+)
+
+type mock struct {
+	dynamodbiface.DynamoDBAPI
+
+	mailboxes map[string]Mailbox
+}
+
+func (mock mock) GetItemWithContext(ctx aws.Context, in *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	mailbox, ok := mock.mailboxes[*in.Key[KeyAttribute].S]
+	if !ok {
+		return &dynamodb.GetItemOutput{}, nil
+	}
+
+	item, err := dynamodbattribute.MarshalMap(mailbox)
+
+	return &dynamodb.GetItemOutput{Item: item}, err
+}
+
+func TestGet(t *testing.T) {
+	store := NewStore(mock{
+		mailboxes: map[string]Mailbox{
+			"+447700900001": {
+				Number:               "+447700900001",
+				Email:                "alice@example.com",
+				Language:             "cy-GB",
+				AlternativeLanguages: []string{"en-GB"},
+				SkipMissedCalls:      true,
+				HangUps:              HangUpsIgnore,
+			},
+		},
+	}, "mailboxes", Mailbox{
+		Email:                "team@example.com",
+		Language:             "en-US",
+		AlternativeLanguages: ParseLanguages("es-US, pl-PL"),
+		HangUps:              HangUpsDigest,
+	})
+
+	t.Run("Configured mailbox", func(t *testing.T) {
+		mailbox, err := store.Get(aws.BackgroundContext(), "+447700900001")
+
+		assert.NoError(t, err)
+		assert.Equal(t, Mailbox{
+			Number:               "+447700900001",
+			Email:                "alice@example.com",
+			Language:             "cy-GB",
+			AlternativeLanguages: []string{"en-GB"},
+			SkipMissedCalls:      true,
+			HangUps:              HangUpsIgnore,
+		}, mailbox)
+	})
+
+	t.Run("Unknown number gets defaults", func(t *testing.T) {
+		mailbox, err := store.Get(aws.BackgroundContext(), "+447700900002")
+
+		assert.NoError(t, err)
+		assert.Equal(t, Mailbox{
+			Number:               "+447700900002",
+			Email:                "team@example.com",
+			Language:             "en-US",
+			AlternativeLanguages: []string{"es-US", "pl-PL"},
+			HangUps:              HangUpsDigest,
+		}, mailbox)
+	})
+}
+
+func TestRecipients(t *testing.T) {
+	mailbox := Mailbox{
+		Email:       "team@example.com",
+		OptionEmail: ParseOptionEmail("urgent=oncall@example.com, boss@example.com; billing=accounts@example.com"),
+	}
+
+	assert.Equal(t, []string{"oncall@example.com", "boss@example.com"}, mailbox.Recipients("urgent"))
+	assert.Equal(t, []string{"accounts@example.com"}, mailbox.Recipients("billing"))
+	assert.Equal(t, []string{"team@example.com"}, mailbox.Recipients("sales"))
+	assert.Equal(t, []string{"team@example.com"}, mailbox.Recipients(""))
+}
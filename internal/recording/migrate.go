@@ -0,0 +1,207 @@
+package recording
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+
+	"answering-machine/internal/telephony"
+)
+
+// Migration copies recordings archived at their bare SID to Key, and
+// points the webhook data and transcription items at the copies.
+type Migration struct {
+	s3                 s3iface.S3API
+	dynamodb           dynamodbiface.DynamoDBAPI
+	bucket             string
+	webhookTable       string
+	transcriptionTable string
+
+	// KMSKeyID encrypts the copies, which otherwise get the bucket's
+	// default encryption.
+	KMSKeyID string
+	// DeleteOriginals removes each flat object once it has been copied and
+	// the items updated.
+	DeleteOriginals bool
+	// DryRun logs what would be copied without changing anything.
+	DryRun bool
+}
+
+// NewMigration returns a migration of the recordings in bucket.
+func NewMigration(s3 s3iface.S3API, dynamodb dynamodbiface.DynamoDBAPI, bucket, webhookTable, transcriptionTable string) *Migration {
+	return &Migration{
+		s3:                 s3,
+		dynamodb:           dynamodb,
+		bucket:             bucket,
+		webhookTable:       webhookTable,
+		transcriptionTable: transcriptionTable,
+	}
+}
+
+// Run migrates every flat object at the top of the bucket, returning how
+// many were migrated. Objects without a webhook data item, which aren't
+// recordings, are left alone. It can be run again after a failure.
+func (migration *Migration) Run(ctx context.Context) (int, error) {
+	var keys []string
+	err := migration.s3.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(migration.bucket),
+		Delimiter: aws.String("/"),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			keys = append(keys, aws.StringValue(object.Key))
+		}
+
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	for _, key := range keys {
+		ok, err := migration.migrate(ctx, key)
+		if err != nil {
+			return migrated, err
+		}
+		if ok {
+			migrated++
+		}
+	}
+
+	return migrated, nil
+}
+
+func (migration *Migration) migrate(ctx context.Context, key string) (bool, error) {
+	object, err := migration.s3.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(migration.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(migration.bucket),
+		CopySource: aws.String(migration.bucket + "/" + url.PathEscape(key)),
+	}
+
+	// Recordings from before metadata get it from the webhook data item.
+	metadata, ok := ParseMetadata(object.Metadata)
+	if !ok {
+		callback, err := migration.callback(ctx, key)
+		if err != nil {
+			return false, err
+		}
+		if callback.RecordingSid == "" {
+			log.Printf("skipping %s, it isn't a recording", key)
+
+			return false, nil
+		}
+
+		metadata = NewMetadata(callback)
+		input.MetadataDirective = aws.String(s3.MetadataDirectiveReplace)
+		input.ContentType = aws.String(ContentType)
+		input.Metadata = metadata.Object()
+		input.TaggingDirective = aws.String(s3.TaggingDirectiveReplace)
+		input.Tagging = aws.String(metadata.Tagging())
+	}
+
+	newKey := Key(metadata.Mailbox, aws.TimeValue(object.LastModified), SidFromKey(key))
+	input.Key = aws.String(newKey)
+	if migration.KMSKeyID != "" {
+		input.ServerSideEncryption = aws.String(s3.ServerSideEncryptionAwsKms)
+		input.SSEKMSKeyId = aws.String(migration.KMSKeyID)
+	}
+
+	log.Printf("copying %s to %s", key, newKey)
+	if migration.DryRun {
+		return true, nil
+	}
+
+	_, err = migration.s3.CopyObjectWithContext(ctx, input)
+	if err != nil {
+		return false, err
+	}
+
+	for _, table := range []string{migration.webhookTable, migration.transcriptionTable} {
+		err = migration.setKey(ctx, table, SidFromKey(key), newKey)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if migration.DeleteOriginals {
+		_, err = migration.s3.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(migration.bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// callback reads the webhook data item of the recording at key.
+func (migration *Migration) callback(ctx context.Context, key string) (telephony.RecordingEvent, error) {
+	callback := telephony.RecordingEvent{}
+
+	if strings.ContainsAny(key, "/.") {
+		return callback, nil
+	}
+
+	result, err := migration.dynamodb.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(migration.webhookTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"RecordingSid": {
+				S: aws.String(key),
+			},
+		},
+	})
+	if err != nil {
+		return callback, err
+	}
+
+	err = dynamodbattribute.UnmarshalMap(result.Item, &callback)
+
+	return callback, err
+}
+
+// setKey stores the new key on the recording's item in table, if it has
+// one there.
+func (migration *Migration) setKey(ctx context.Context, table, recordingSID, key string) error {
+	if table == "" {
+		return nil
+	}
+
+	_, err := migration.dynamodb.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"RecordingSid": {
+				S: aws.String(recordingSID),
+			},
+		},
+		ConditionExpression: aws.String("attribute_exists(RecordingSid)"),
+		UpdateExpression:    aws.String("SET RecordingKey = :key"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":key": {
+				S: aws.String(key),
+			},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil
+	}
+
+	return err
+}
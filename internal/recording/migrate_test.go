@@ -0,0 +1,135 @@
+package recording
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/stretchr/testify/assert"
+)
+
+var archived = time.Date(2026, 10, 19, 10, 15, 0, 0, time.UTC)
+
+type mockS3API struct {
+	s3iface.S3API
+
+	objects map[string]map[string]*string
+	copies  []*s3.CopyObjectInput
+	deletes []string
+}
+
+func (mock *mockS3API) ListObjectsV2PagesWithContext(ctx aws.Context, in *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool, opts ...request.Option) error {
+	page := &s3.ListObjectsV2Output{}
+	for key := range mock.objects {
+		page.Contents = append(page.Contents, &s3.Object{Key: aws.String(key)})
+	}
+	fn(page, true)
+
+	return nil
+}
+
+func (mock *mockS3API) HeadObjectWithContext(ctx aws.Context, in *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	return &s3.HeadObjectOutput{
+		LastModified: aws.Time(archived),
+		Metadata:     mock.objects[*in.Key],
+	}, nil
+}
+
+func (mock *mockS3API) CopyObjectWithContext(ctx aws.Context, in *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error) {
+	mock.copies = append(mock.copies, in)
+
+	return &s3.CopyObjectOutput{}, nil
+}
+
+func (mock *mockS3API) DeleteObjectWithContext(ctx aws.Context, in *s3.DeleteObjectInput, opts ...request.Option) (*s3.DeleteObjectOutput, error) {
+	mock.deletes = append(mock.deletes, *in.Key)
+
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+type mockDynamoDBAPI struct {
+	dynamodbiface.DynamoDBAPI
+
+	items   map[string]map[string]*dynamodb.AttributeValue
+	updates []*dynamodb.UpdateItemInput
+}
+
+func (mock *mockDynamoDBAPI) GetItemWithContext(ctx aws.Context, in *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: mock.items[*in.Key["RecordingSid"].S]}, nil
+}
+
+func (mock *mockDynamoDBAPI) UpdateItemWithContext(ctx aws.Context, in *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	if *in.TableName == "transcriptions" {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "no transcription", nil)
+	}
+	mock.updates = append(mock.updates, in)
+
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func TestMigration(t *testing.T) {
+	newMocks := func() (*mockS3API, *mockDynamoDBAPI) {
+		return &mockS3API{
+			objects: map[string]map[string]*string{
+				// Archived with metadata.
+				"RE1": {"Call-Sid": aws.String("CA1"), "Mailbox": aws.String("+441632960000")},
+				// Archived before metadata.
+				"RE2": {},
+				// Not a recording.
+				"notes.txt": {},
+			},
+		}, &mockDynamoDBAPI{
+			items: map[string]map[string]*dynamodb.AttributeValue{
+				"RE2": {
+					"RecordingSid": {S: aws.String("RE2")},
+					"CallSid":      {S: aws.String("CA2")},
+					"To":           {S: aws.String("+441632960001")},
+				},
+			},
+		}
+	}
+
+	t.Run("Copies flat objects", func(t *testing.T) {
+		s3API, dynamodbAPI := newMocks()
+		migration := NewMigration(s3API, dynamodbAPI, "recordings", "webhook", "transcriptions")
+		migration.KMSKeyID = "key"
+		migration.DeleteOriginals = true
+
+		migrated, err := migration.Run(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, migrated)
+		copies := map[string]*s3.CopyObjectInput{}
+		for _, copy := range s3API.copies {
+			copies[*copy.CopySource] = copy
+			assert.Equal(t, "key", aws.StringValue(copy.SSEKMSKeyId))
+		}
+		assert.Equal(t, "mailbox/+441632960000/2026/10/19/RE1.mp3", aws.StringValue(copies["recordings/RE1"].Key))
+		assert.Nil(t, copies["recordings/RE1"].MetadataDirective)
+		assert.Equal(t, "mailbox/+441632960001/2026/10/19/RE2.mp3", aws.StringValue(copies["recordings/RE2"].Key))
+		assert.Equal(t, s3.MetadataDirectiveReplace, aws.StringValue(copies["recordings/RE2"].MetadataDirective))
+		assert.Equal(t, "CA2", aws.StringValue(copies["recordings/RE2"].Metadata["call-sid"]))
+		assert.Len(t, dynamodbAPI.updates, 2)
+		assert.ElementsMatch(t, []string{"RE1", "RE2"}, s3API.deletes)
+	})
+
+	t.Run("Dry run", func(t *testing.T) {
+		s3API, dynamodbAPI := newMocks()
+		migration := NewMigration(s3API, dynamodbAPI, "recordings", "webhook", "transcriptions")
+		migration.DryRun = true
+
+		migrated, err := migration.Run(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, migrated)
+		assert.Empty(t, s3API.copies)
+		assert.Empty(t, dynamodbAPI.updates)
+	})
+}
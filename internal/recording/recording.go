@@ -0,0 +1,196 @@
+// Package recording describes the objects recordings are archived as, with
+// the call they came from stored as metadata so the email and transcription
+// stages can read it straight from the object.
+package recording
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+
+	"answering-machine/internal/telephony"
+)
+
+// ContentType is the type recordings are archived with, every carrier
+// serves MP3.
+const ContentType = "audio/mpeg"
+
+// KeyPrefix is where recordings are archived in the bucket.
+const KeyPrefix = "mailbox/"
+
+// Key is where a recording is archived, partitioned by mailbox and the UTC
+// day it was made: mailbox/<to>/yyyy/mm/dd/<RecordingSid>.mp3.
+func Key(mailbox string, recorded time.Time, recordingSid string) string {
+	if mailbox == "" {
+		mailbox = "unknown"
+	}
+
+	return KeyPrefix + mailbox + "/" + recorded.UTC().Format("2006/01/02") + "/" + recordingSid + ".mp3"
+}
+
+// SidFromKey returns the RecordingSid a key was built from, including the
+// bare SIDs recordings were archived as before Key.
+func SidFromKey(key string) string {
+	name := path.Base(key)
+
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// Metadata is the call context stored with a recording. S3 returns the
+// keys in canonical header form, so they are matched ignoring case.
+type Metadata struct {
+	RecordingSid string
+	CallSid      string
+	Caller       string
+	// Mailbox is the number that was dialled.
+	Mailbox string
+	// Option is the menu option the caller chose, if any.
+	Option   string
+	Duration int
+	// SHA256 is the hex digest of the recording as it was downloaded from
+	// the carrier, so readers can Verify their copy.
+	SHA256 string
+	// Empty is set on recordings too short or quiet to hold a message,
+	// which aren't transcribed.
+	Empty bool
+	// Codec, SampleRate and Channels are read from the audio's headers.
+	Codec      string
+	SampleRate int
+	Channels   int
+}
+
+// NewMetadata describes the recording of event, without its SHA256.
+func NewMetadata(event telephony.RecordingEvent) Metadata {
+	return Metadata{
+		RecordingSid: event.RecordingSid,
+		CallSid:      event.CallSid,
+		Caller:       event.CallerNumber(),
+		Mailbox:      event.To,
+		Option:       event.Option,
+		Duration:     event.RecordingDuration,
+	}
+}
+
+// Sum returns the hex SHA-256 of content.
+func Sum(content []byte) string {
+	sum := sha256.Sum256(content)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Object returns the S3 metadata of the recording, leaving out anything
+// unknown.
+func (metadata Metadata) Object() map[string]*string {
+	object := map[string]*string{}
+	for key, value := range metadata.values() {
+		object[key] = aws.String(value)
+	}
+
+	return object
+}
+
+// untagged are the metadata keys left out of the tags, which S3 limits
+// to ten.
+var untagged = map[string]bool{
+	"sha256":      true,
+	"codec":       true,
+	"sample-rate": true,
+	"channels":    true,
+}
+
+// Tagging returns the S3 tags of the recording, for lifecycle rules and
+// cost reports that can't see metadata.
+func (metadata Metadata) Tagging() string {
+	tags := url.Values{}
+	for key, value := range metadata.values() {
+		if !untagged[key] {
+			tags.Set(key, value)
+		}
+	}
+
+	return tags.Encode()
+}
+
+func (metadata Metadata) values() map[string]string {
+	values := map[string]string{}
+	for key, value := range map[string]string{
+		"recording-sid": metadata.RecordingSid,
+		"call-sid":      metadata.CallSid,
+		"caller":        metadata.Caller,
+		"mailbox":       metadata.Mailbox,
+		"option":        metadata.Option,
+		"sha256":        metadata.SHA256,
+		"codec":         metadata.Codec,
+	} {
+		if value != "" {
+			values[key] = value
+		}
+	}
+	if metadata.Duration > 0 {
+		values["duration"] = strconv.Itoa(metadata.Duration)
+	}
+	if metadata.Empty {
+		values["empty"] = "true"
+	}
+	if metadata.SampleRate > 0 {
+		values["sample-rate"] = strconv.Itoa(metadata.SampleRate)
+	}
+	if metadata.Channels > 0 {
+		values["channels"] = strconv.Itoa(metadata.Channels)
+	}
+
+	return values
+}
+
+// ParseMetadata reads the metadata of an object. ok is false for objects
+// archived without it.
+func ParseMetadata(object map[string]*string) (metadata Metadata, ok bool) {
+	for key, value := range object {
+		switch strings.ToLower(key) {
+		case "recording-sid":
+			metadata.RecordingSid = aws.StringValue(value)
+		case "call-sid":
+			metadata.CallSid = aws.StringValue(value)
+		case "caller":
+			metadata.Caller = aws.StringValue(value)
+		case "mailbox":
+			metadata.Mailbox = aws.StringValue(value)
+		case "option":
+			metadata.Option = aws.StringValue(value)
+		case "duration":
+			metadata.Duration, _ = strconv.Atoi(aws.StringValue(value))
+		case "sha256":
+			metadata.SHA256 = aws.StringValue(value)
+		case "empty":
+			metadata.Empty = aws.StringValue(value) == "true"
+		case "codec":
+			metadata.Codec = aws.StringValue(value)
+		case "sample-rate":
+			metadata.SampleRate, _ = strconv.Atoi(aws.StringValue(value))
+		case "channels":
+			metadata.Channels, _ = strconv.Atoi(aws.StringValue(value))
+		}
+	}
+
+	return metadata, metadata.CallSid != ""
+}
+
+// Verify checks content against the SHA-256 it was archived with.
+func (metadata Metadata) Verify(content []byte) error {
+	if metadata.SHA256 == "" {
+		return nil
+	}
+
+	if sum := Sum(content); sum != metadata.SHA256 {
+		return fmt.Errorf("recording %s has SHA-256 %s, expected %s", metadata.RecordingSid, sum, metadata.SHA256)
+	}
+
+	return nil
+}
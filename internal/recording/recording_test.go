@@ -0,0 +1,77 @@
+package recording
+
+import (
+	"net/textproto"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+
+	"answering-machine/internal/telephony"
+)
+
+func TestKey(t *testing.T) {
+	archived := time.Date(2026, 10, 19, 23, 30, 0, 0, time.FixedZone("UTC-1", -3600))
+
+	key := Key("+441632960000", archived, "RE123")
+
+	assert.Equal(t, "mailbox/+441632960000/2026/10/20/RE123.mp3", key)
+	assert.Equal(t, "RE123", SidFromKey(key))
+	assert.Equal(t, "RE123", SidFromKey("RE123"))
+	assert.Equal(t, "mailbox/unknown/2026/10/20/RE123.mp3", Key("", archived, "RE123"))
+}
+
+func TestMetadata(t *testing.T) {
+	content := []byte("audio")
+
+	metadata := NewMetadata(telephony.RecordingEvent{
+		RecordingSid:      "RE123",
+		CallSid:           "CA123",
+		From:              "+447700900123",
+		To:                "+441632960000",
+		RecordingDuration: 14,
+	})
+	metadata.SHA256 = Sum(content)
+	metadata.Empty = true
+	metadata.Codec = "mp3"
+	metadata.SampleRate = 22050
+	metadata.Channels = 1
+
+	t.Run("Round trip", func(t *testing.T) {
+		// S3 returns keys as canonical headers.
+		object := map[string]*string{}
+		for key, value := range metadata.Object() {
+			object[textproto.CanonicalMIMEHeaderKey(key)] = value
+		}
+
+		parsed, ok := ParseMetadata(object)
+
+		assert.True(t, ok)
+		assert.Equal(t, metadata, parsed)
+		assert.Equal(t, "+447700900123", parsed.Caller)
+		assert.NoError(t, parsed.Verify(content))
+		assert.Error(t, parsed.Verify([]byte("<html>")))
+	})
+
+	t.Run("Tagging", func(t *testing.T) {
+		tags, err := url.ParseQuery(metadata.Tagging())
+
+		assert.NoError(t, err)
+		assert.Equal(t, url.Values{
+			"recording-sid": {"RE123"},
+			"call-sid":      {"CA123"},
+			"caller":        {"+447700900123"},
+			"mailbox":       {"+441632960000"},
+			"duration":      {"14"},
+			"empty":         {"true"},
+		}, tags)
+	})
+
+	t.Run("Objects without metadata", func(t *testing.T) {
+		_, ok := ParseMetadata(map[string]*string{"Other": aws.String("value")})
+
+		assert.False(t, ok)
+	})
+}
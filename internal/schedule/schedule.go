@@ -0,0 +1,213 @@
+// Package schedule decides whether a call arrives during opening hours,
+// after hours or on a holiday.
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Slot is the part of the schedule a moment falls in.
+type Slot string
+
+// Slots a call can arrive in.
+const (
+	SlotOpen    Slot = "open"
+	SlotClosed  Slot = "closed"
+	SlotHoliday Slot = "holiday"
+)
+
+// Clock tells the time, so schedule decisions can be tested at fixed
+// moments.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the real time.
+type SystemClock struct{}
+
+// Now returns the current time.
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FixedClock is always the same moment.
+type FixedClock time.Time
+
+// Now returns the fixed moment.
+func (clock FixedClock) Now() time.Time {
+	return time.Time(clock)
+}
+
+// Schedule is a set of weekly opening hours in a timezone plus holidays.
+// An empty schedule is always open.
+type Schedule struct {
+	location *time.Location
+	hours    map[time.Weekday][]span
+	holidays map[string]bool
+}
+
+// span is an opening period in minutes since midnight, end exclusive.
+type span struct {
+	start, end int
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Parse builds a schedule from configuration.
+//
+// timezone is an IANA name such as "Europe/London", UTC if empty.
+// hours is a ";" separated list of days and times, for example
+// "mon-fri 09:00-17:30; sat 10:00-12:00". Periods can't cross midnight.
+// holidays is a "," separated list of dates like "2026-12-25", on which the
+// schedule is closed all day.
+func Parse(timezone, hours, holidays string) (*Schedule, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule := &Schedule{
+		location: location,
+		hours:    make(map[time.Weekday][]span),
+		holidays: make(map[string]bool),
+	}
+
+	for _, entry := range strings.Split(hours, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		err := schedule.parseHours(entry)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, date := range strings.Split(holidays, ",") {
+		date = strings.TrimSpace(date)
+		if date == "" {
+			continue
+		}
+
+		_, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid holiday %q: %w", date, err)
+		}
+		schedule.holidays[date] = true
+	}
+
+	return schedule, nil
+}
+
+func (schedule *Schedule) parseHours(entry string) error {
+	fields := strings.Fields(entry)
+	if len(fields) != 2 {
+		return fmt.Errorf("invalid opening hours %q, expected something like \"mon-fri 09:00-17:30\"", entry)
+	}
+
+	days, err := parseDays(fields[0])
+	if err != nil {
+		return err
+	}
+
+	times := strings.Split(fields[1], "-")
+	if len(times) != 2 {
+		return fmt.Errorf("invalid opening hours %q, expected a range like 09:00-17:30", fields[1])
+	}
+
+	start, err := parseMinutes(times[0])
+	if err != nil {
+		return err
+	}
+
+	end, err := parseMinutes(times[1])
+	if err != nil {
+		return err
+	}
+
+	if end <= start {
+		return fmt.Errorf("invalid opening hours %q, periods can't end before they start or cross midnight", fields[1])
+	}
+
+	for _, day := range days {
+		schedule.hours[day] = append(schedule.hours[day], span{start: start, end: end})
+	}
+
+	return nil
+}
+
+func parseDays(s string) ([]time.Weekday, error) {
+	bounds := strings.Split(strings.ToLower(s), "-")
+	if len(bounds) > 2 {
+		return nil, fmt.Errorf("invalid days %q", s)
+	}
+
+	first, ok := weekdays[bounds[0]]
+	if !ok {
+		return nil, fmt.Errorf("invalid day %q", bounds[0])
+	}
+
+	last := first
+	if len(bounds) == 2 {
+		last, ok = weekdays[bounds[1]]
+		if !ok {
+			return nil, fmt.Errorf("invalid day %q", bounds[1])
+		}
+	}
+
+	// Ranges can wrap the week, "fri-mon" is four days.
+	days := []time.Weekday{first}
+	for day := first; day != last; {
+		day = (day + 1) % 7
+		days = append(days, day)
+	}
+
+	return days, nil
+}
+
+func parseMinutes(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Slot returns the slot t falls in, in the schedule's timezone.
+func (schedule *Schedule) Slot(t time.Time) Slot {
+	if schedule == nil || (len(schedule.hours) == 0 && len(schedule.holidays) == 0) {
+		return SlotOpen
+	}
+
+	local := t.In(schedule.location)
+
+	if schedule.holidays[local.Format("2006-01-02")] {
+		return SlotHoliday
+	}
+
+	// Only holidays configured, every other day is open.
+	if len(schedule.hours) == 0 {
+		return SlotOpen
+	}
+
+	minutes := local.Hour()*60 + local.Minute()
+	for _, span := range schedule.hours[local.Weekday()] {
+		if minutes >= span.start && minutes < span.end {
+			return SlotOpen
+		}
+	}
+
+	return SlotClosed
+}
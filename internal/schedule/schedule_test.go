@@ -0,0 +1,67 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlot(t *testing.T) {
+	schedule, err := Parse("Europe/London", "mon-fri 09:00-17:30; sat 10:00-12:00", "2026-12-25")
+	assert.NoError(t, err)
+
+	london, err := time.LoadLocation("Europe/London")
+	assert.NoError(t, err)
+
+	cases := []struct {
+		name  string
+		clock Clock
+		slot  Slot
+	}{
+		{"Weekday morning", FixedClock(time.Date(2026, 10, 19, 9, 0, 0, 0, london)), SlotOpen},
+		{"Weekday before opening", FixedClock(time.Date(2026, 10, 19, 8, 59, 0, 0, london)), SlotClosed},
+		{"Weekday closing time", FixedClock(time.Date(2026, 10, 19, 17, 30, 0, 0, london)), SlotClosed},
+		{"Saturday morning", FixedClock(time.Date(2026, 10, 24, 11, 0, 0, 0, london)), SlotOpen},
+		{"Saturday afternoon", FixedClock(time.Date(2026, 10, 24, 13, 0, 0, 0, london)), SlotClosed},
+		{"Sunday", FixedClock(time.Date(2026, 10, 25, 11, 0, 0, 0, london)), SlotClosed},
+		{"Holiday", FixedClock(time.Date(2026, 12, 25, 11, 0, 0, 0, london)), SlotHoliday},
+		// 08:30 UTC is 09:30 in London during British Summer Time.
+		{"Timezone", FixedClock(time.Date(2026, 7, 1, 8, 30, 0, 0, time.UTC)), SlotOpen},
+		{"Timezone in winter", FixedClock(time.Date(2026, 1, 7, 8, 30, 0, 0, time.UTC)), SlotClosed},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.slot, schedule.Slot(c.clock.Now()))
+		})
+	}
+}
+
+func TestEmptySchedule(t *testing.T) {
+	schedule, err := Parse("", "", "")
+	assert.NoError(t, err)
+
+	assert.Equal(t, SlotOpen, schedule.Slot(time.Date(2026, 10, 25, 3, 0, 0, 0, time.UTC)))
+}
+
+func TestParseDays(t *testing.T) {
+	days, err := parseDays("fri-mon")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Weekday{time.Friday, time.Saturday, time.Sunday, time.Monday}, days)
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse("Nowhere/Special", "", "")
+	assert.Error(t, err)
+
+	_, err = Parse("", "weekdays 09:00-17:00", "")
+	assert.Error(t, err)
+
+	_, err = Parse("", "mon 17:00-09:00", "")
+	assert.Error(t, err)
+
+	_, err = Parse("", "", "25/12/2026")
+	assert.Error(t, err)
+}
@@ -0,0 +1,165 @@
+// Package sms stores inbound text messages and their media.
+package sms
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+
+	"answering-machine/internal/callers"
+	"answering-machine/internal/carrier"
+	"answering-machine/internal/telephony"
+)
+
+// Deps handles incoming message webhooks on the sms route.
+type Deps struct {
+	dynamodb   dynamodbiface.DynamoDBAPI
+	s3uploader s3manageriface.UploaderAPI
+	provider   telephony.MessageProvider
+	tableName  string
+	bucketName string
+	blocklist  callerList
+}
+
+type callerList interface {
+	Contains(ctx context.Context, number string) (bool, error)
+}
+
+// extensions names saved media after the types MMS commonly carries.
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"video/mp4":  ".mp4",
+	"audio/mpeg": ".mp3",
+	"audio/amr":  ".amr",
+	"text/vcard": ".vcf",
+	"text/plain": ".txt",
+}
+
+// Handler authenticates and stores a message, saving any media first.
+func (deps *Deps) Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	message, err := deps.provider.ParseMessage(request)
+	if err == telephony.ErrUnauthorized {
+		log.Printf("rejecting unauthenticated %s request", deps.provider.Name())
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: 403,
+		}, nil
+	}
+	if validationErr, ok := err.(*telephony.ValidationError); ok {
+		log.Printf("rejecting message: %s", validationErr)
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: 400,
+			Body:       validationErr.Error(),
+		}, nil
+	}
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 400,
+		}, err
+	}
+
+	blocked, err := deps.blocklist.Contains(ctx, message.From)
+	if err != nil {
+		log.Printf("checking blocklist for %s: %s", message.From, err)
+	}
+	if blocked {
+		log.Printf("dropping message %s from blocked sender %s", message.MessageSid, message.From)
+
+		return deps.provider.Render()
+	}
+
+	for i := range message.Media {
+		err = deps.saveMedia(ctx, message, i, &message.Media[i])
+		if err != nil {
+			return events.APIGatewayProxyResponse{
+				StatusCode: 500,
+			}, err
+		}
+	}
+
+	attributeValues, err := dynamodbattribute.MarshalMap(message)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 400,
+		}, err
+	}
+
+	// Twilio retries webhooks, only the first write may send the email.
+	_, err = deps.dynamodb.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		Item:                attributeValues,
+		TableName:           aws.String(deps.tableName),
+		ConditionExpression: aws.String("attribute_not_exists(MessageSid)"),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		log.Printf("ignoring duplicate message %s", message.MessageSid)
+	} else if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 500,
+		}, err
+	}
+
+	// An empty response sends no reply.
+	return deps.provider.Render()
+}
+
+// saveMedia copies an attachment from the provider into the bucket, under
+// the message so retried webhooks overwrite rather than duplicate it.
+func (deps *Deps) saveMedia(ctx context.Context, message telephony.Message, i int, media *telephony.Media) error {
+	body, err := deps.provider.FetchMedia(ctx, message, *media)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	media.Key = fmt.Sprintf("%s/attachment-%d%s", message.MessageSid, i+1, extensions[media.ContentType])
+
+	input := &s3manager.UploadInput{
+		Bucket: aws.String(deps.bucketName),
+		Key:    aws.String(media.Key),
+		Body:   body,
+	}
+	if media.ContentType != "" {
+		input.ContentType = aws.String(media.ContentType)
+	}
+
+	_, err = deps.s3uploader.UploadWithContext(ctx, input)
+
+	return err
+}
+
+// NewFromEnv configures the handler from the Lambda environment, storing
+// messages with dynamodb and media with s3uploader. The provider must be
+// able to receive messages.
+func NewFromEnv(dynamodb dynamodbiface.DynamoDBAPI, s3uploader s3manageriface.UploaderAPI, httpClient telephony.HTTPClient) (*Deps, error) {
+	provider, err := carrier.FromEnv(httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	messageProvider, ok := provider.(telephony.MessageProvider)
+	if !ok {
+		return nil, fmt.Errorf("%s can't receive messages", provider.Name())
+	}
+
+	return &Deps{
+		dynamodb:   dynamodb,
+		s3uploader: s3uploader,
+		provider:   messageProvider,
+		tableName:  os.Getenv("MESSAGES_TABLE"),
+		bucketName: os.Getenv("RECORDING_BUCKET_NAME"),
+		blocklist:  callers.NewList(dynamodb, os.Getenv("BLOCKLIST_TABLE")),
+	}, nil
+}
@@ -0,0 +1,211 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+
+	"answering-machine/internal/telephony"
+	"answering-machine/internal/twilio"
+)
+
+type mockDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+
+	mockErr error
+	items   *[]telephony.Message
+}
+
+func (mock mockDynamoDB) PutItemWithContext(ctx aws.Context, in *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	message := telephony.Message{}
+	err := dynamodbattribute.UnmarshalMap(in.Item, &message)
+	if err != nil {
+		return nil, err
+	}
+	*mock.items = append(*mock.items, message)
+
+	return &dynamodb.PutItemOutput{}, mock.mockErr
+}
+
+type mockUploader struct {
+	s3manageriface.UploaderAPI
+
+	uploads map[string]string
+}
+
+func (mock mockUploader) UploadWithContext(ctx aws.Context, in *s3manager.UploadInput, opts ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
+	body, err := ioutil.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	mock.uploads[*in.Key] = aws.StringValue(in.ContentType) + ":" + string(body)
+
+	return &s3manager.UploadOutput{}, nil
+}
+
+// mockHTTPClient serves media of contentType, as long as the request has
+// the account's credentials.
+type mockHTTPClient struct {
+	contentType string
+}
+
+func (mock mockHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	if username, password, _ := req.BasicAuth(); username != "AC123" || password != "secret" {
+		return &http.Response{
+			StatusCode: 401,
+			Body:       ioutil.NopCloser(bytes.NewBufferString("Unauthorized")),
+		}, nil
+	}
+
+	return &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": {mock.contentType}},
+		Body:       ioutil.NopCloser(bytes.NewBufferString(req.URL.Path)),
+	}, nil
+}
+
+type mockCallerList map[string]bool
+
+func (mock mockCallerList) Contains(ctx context.Context, number string) (bool, error) {
+	return mock[number], nil
+}
+
+func TestLambdaHandler(t *testing.T) {
+	authToken := "secret"
+
+	params := url.Values{
+		"AccountSid":        {"AC123"},
+		"MessageSid":        {"MM123"},
+		"From":              {"+447700900123"},
+		"To":                {"+441632960000"},
+		"Body":              {"Call me back"},
+		"NumMedia":          {"1"},
+		"MediaUrl0":         {"https://api.twilio.com/Media/ME1"},
+		"MediaContentType0": {"image/jpeg"},
+	}
+
+	newRequest := func(params url.Values) events.APIGatewayProxyRequest {
+		return events.APIGatewayProxyRequest{
+			Path: "/sms",
+			Headers: map[string]string{
+				"Host":                 "example.com",
+				twilio.SignatureHeader: twilio.Signature(authToken, "https://example.com/live/sms", params),
+			},
+			RequestContext: events.APIGatewayProxyRequestContext{
+				Stage: "live",
+			},
+			Body: params.Encode(),
+		}
+	}
+
+	newDeps := func(mockErr error) (Deps, *[]telephony.Message, map[string]string) {
+		items := &[]telephony.Message{}
+		uploads := map[string]string{}
+
+		return Deps{
+			dynamodb:   mockDynamoDB{mockErr: mockErr, items: items},
+			s3uploader: mockUploader{uploads: uploads},
+			provider:   twilio.NewProvider("", authToken, mockHTTPClient{contentType: "image/jpeg"}),
+			tableName:  "messages",
+			bucketName: "recordings",
+			blocklist:  mockCallerList{},
+		}, items, uploads
+	}
+
+	t.Run("Stores message and media", func(t *testing.T) {
+		deps, items, uploads := newDeps(nil)
+
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequest(params))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Contains(t, resp.Body, "<Response></Response>")
+		assert.Equal(t, map[string]string{"MM123/attachment-1.jpg": "image/jpeg:/Media/ME1"}, uploads)
+		assert.Equal(t, []telephony.Message{
+			{
+				Provider:   "twilio",
+				AccountSid: "AC123",
+				MessageSid: "MM123",
+				From:       "+447700900123",
+				To:         "+441632960000",
+				Body:       "Call me back",
+				NumMedia:   1,
+				Media: []telephony.Media{
+					{Key: "MM123/attachment-1.jpg", ContentType: "image/jpeg"},
+				},
+			},
+		}, *items)
+	})
+
+	t.Run("Media of another type", func(t *testing.T) {
+		deps, items, uploads := newDeps(nil)
+		deps.provider = twilio.NewProvider("", authToken, mockHTTPClient{contentType: "text/html"})
+
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequest(params))
+
+		assert.True(t, errors.Is(err, telephony.ErrUnexpectedMedia))
+		assert.Equal(t, 500, resp.StatusCode)
+		assert.Empty(t, *items)
+		assert.Empty(t, uploads)
+	})
+
+	t.Run("Duplicate", func(t *testing.T) {
+		deps, _, _ := newDeps(awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "exists", nil))
+
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequest(params))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+	})
+
+	t.Run("Blocked Sender", func(t *testing.T) {
+		deps, items, uploads := newDeps(nil)
+		deps.blocklist = mockCallerList{"+447700900123": true}
+
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequest(params))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Empty(t, *items)
+		assert.Empty(t, uploads)
+	})
+
+	t.Run("Missing Fields", func(t *testing.T) {
+		deps, items, _ := newDeps(nil)
+
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequest(url.Values{"Body": {"hello"}}))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 400, resp.StatusCode)
+		assert.Equal(t, "missing required fields: MessageSid, From", resp.Body)
+		assert.Empty(t, *items)
+	})
+
+	t.Run("Invalid Signature", func(t *testing.T) {
+		deps, items, _ := newDeps(nil)
+		request := newRequest(params)
+		request.Headers[twilio.SignatureHeader] = "bogus"
+
+		resp, err := deps.Handler(aws.BackgroundContext(), request)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 403, resp.StatusCode)
+		assert.Empty(t, *items)
+	})
+}
@@ -0,0 +1,88 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Processor runs the records of a batch through a bounded pool of workers.
+type Processor struct {
+	// Workers is how many records are processed at once, at least one.
+	Workers int
+	// EventNames are the events processed, INSERT if empty. Other records,
+	// such as REMOVE, are skipped.
+	EventNames []string
+}
+
+// Process calls handle for each record, returning an error if any of them
+// failed so that Lambda retries the batch. The event source mappings can't
+// be told to retry only the failed records, pulumi-aws v2 has no
+// FunctionResponseTypes, so records that succeeded are handled again and
+// handle must be safe to repeat.
+func (processor Processor) Process(ctx context.Context, event events.DynamoDBEvent, handle func(context.Context, events.DynamoDBEventRecord) error) error {
+	errs := make([]error, len(event.Records))
+	indexes := make(chan int)
+	workers := processor.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				errs[index] = handle(ctx, event.Records[index])
+			}
+		}()
+	}
+
+	for index, record := range event.Records {
+		if processor.processes(record.EventName) {
+			indexes <- index
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	failed := 0
+	var first error
+	for index, err := range errs {
+		if err == nil {
+			continue
+		}
+
+		record := event.Records[index]
+		log.Printf("processing %s record %s: %s", record.EventName, record.Change.SequenceNumber, err)
+
+		failed++
+		if first == nil {
+			first = err
+		}
+	}
+
+	if first != nil {
+		return fmt.Errorf("%d of %d records failed, the first with: %w", failed, len(event.Records), first)
+	}
+
+	return nil
+}
+
+func (processor Processor) processes(eventName string) bool {
+	if len(processor.EventNames) == 0 {
+		return eventName == "INSERT"
+	}
+
+	for _, name := range processor.EventNames {
+		if name == eventName {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,61 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessor(t *testing.T) {
+	event := events.DynamoDBEvent{}
+	for _, record := range []struct{ name, sequence string }{
+		{"INSERT", "1"},
+		{"INSERT", "2"},
+		{"MODIFY", "3"},
+		{"REMOVE", "4"},
+		{"INSERT", "5"},
+	} {
+		event.Records = append(event.Records, events.DynamoDBEventRecord{
+			EventName: record.name,
+			Change:    events.DynamoDBStreamRecord{SequenceNumber: record.sequence},
+		})
+	}
+
+	failing := map[string]bool{"2": true, "5": true}
+	var mu sync.Mutex
+	var processed []string
+	handle := func(ctx context.Context, record events.DynamoDBEventRecord) error {
+		mu.Lock()
+		processed = append(processed, record.Change.SequenceNumber)
+		mu.Unlock()
+
+		if failing[record.Change.SequenceNumber] {
+			return errors.New("failed")
+		}
+
+		return nil
+	}
+
+	t.Run("Fails the batch if any record failed", func(t *testing.T) {
+		processed = nil
+
+		err := Processor{Workers: 3}.Process(context.Background(), event, handle)
+
+		assert.EqualError(t, err, "2 of 5 records failed, the first with: failed")
+		assert.ElementsMatch(t, []string{"1", "2", "5"}, processed)
+	})
+
+	t.Run("Processes the listed events", func(t *testing.T) {
+		processed = nil
+		failing = map[string]bool{}
+
+		err := Processor{EventNames: []string{"INSERT", "MODIFY"}}.Process(context.Background(), event, handle)
+
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"1", "2", "3", "5"}, processed)
+	})
+}
@@ -0,0 +1,50 @@
+// Package stream helps the handlers that are triggered by DynamoDB streams.
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// UnmarshalImage decodes a stream record image into out using the same
+// rules as dynamodbattribute.UnmarshalMap.
+func UnmarshalImage(image map[string]events.DynamoDBAttributeValue, out interface{}) error {
+	item, err := Item(image)
+	if err != nil {
+		return err
+	}
+
+	return dynamodbattribute.UnmarshalMap(item, out)
+}
+
+// Item converts a stream record image, or its keys, to the attribute
+// values the DynamoDB API takes.
+func Item(image map[string]events.DynamoDBAttributeValue) (map[string]*dynamodb.AttributeValue, error) {
+	// Both types speak DynamoDB JSON, round trip through it rather than
+	// converting every attribute type by hand.
+	b, err := json.Marshal(image)
+	if err != nil {
+		return nil, err
+	}
+
+	item := make(map[string]*dynamodb.AttributeValue)
+	err = json.Unmarshal(b, &item)
+
+	return item, err
+}
+
+// TableName is the name of the table a record was streamed from, read
+// from its stream ARN, "arn:aws:dynamodb:…:table/<name>/stream/<label>".
+func TableName(record events.DynamoDBEventRecord) (string, error) {
+	parts := strings.Split(record.EventSourceArn, "/")
+	if len(parts) < 4 || !strings.HasSuffix(parts[0], ":table") || parts[2] != "stream" {
+		return "", fmt.Errorf("stream: not a table stream ARN %q", record.EventSourceArn)
+	}
+
+	return parts[1], nil
+}
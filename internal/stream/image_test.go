@@ -0,0 +1,39 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnmarshalImage(t *testing.T) {
+	var out struct {
+		RecordingSid      string
+		RecordingDuration int
+		Missing           string
+	}
+
+	err := UnmarshalImage(map[string]events.DynamoDBAttributeValue{
+		"RecordingSid":      events.NewStringAttribute("RE123"),
+		"RecordingDuration": events.NewNumberAttribute("42"),
+	}, &out)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "RE123", out.RecordingSid)
+	assert.Equal(t, 42, out.RecordingDuration)
+	assert.Equal(t, "", out.Missing)
+}
+
+func TestTableName(t *testing.T) {
+	name, err := TableName(events.DynamoDBEventRecord{
+		EventSourceArn: "arn:aws:dynamodb:eu-west-2:123456789012:table/answering-machine-messages-1a2b3c/stream/2026-10-18T08:00:00.000",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "answering-machine-messages-1a2b3c", name)
+
+	_, err = TableName(events.DynamoDBEventRecord{})
+
+	assert.EqualError(t, err, `stream: not a table stream ARN ""`)
+}
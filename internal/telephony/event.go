@@ -0,0 +1,167 @@
+// Package telephony is the provider-neutral side of the call flow: the
+// recording events the pipeline stores, and the call-control actions the
+// voice handler responds with. Each carrier implements Provider.
+package telephony
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrUnauthorized is returned when a request can't be shown to come from
+// the provider.
+var ErrUnauthorized = errors.New("telephony: request failed authentication")
+
+// Recording statuses, using Twilio's names. Providers that only report
+// finished recordings always use RecordingStatusCompleted.
+const (
+	RecordingStatusInProgress = "in-progress"
+	RecordingStatusCompleted  = "completed"
+	RecordingStatusAbsent     = "absent"
+	RecordingStatusFailed     = "failed"
+)
+
+// RecordingEvent is a recording callback from any provider, and the item
+// stored in the webhook data table. Twilio was the first provider, so field
+// names follow its parameter names and double as the DynamoDB attributes.
+// Fields tagged param:"-" are the pipeline's own and are never read from a
+// callback.
+// https://www.twilio.com/docs/voice/twiml/record#attributes-recording-status-callback-parameters
+type RecordingEvent struct {
+	Provider          string `dynamodbav:",omitempty" param:"-"`
+	AccountSid        string `dynamodbav:",omitempty"`
+	CallSid           string `dynamodbav:",omitempty"`
+	RecordingSid      string
+	RecordingUrl      string `dynamodbav:",omitempty"`
+	RecordingStatus   string `dynamodbav:",omitempty"`
+	RecordingDuration int    `dynamodbav:",omitempty"`
+	// RecordingStartTime is when the recording started in RFC 3339, if the
+	// provider said.
+	RecordingStartTime string `dynamodbav:",omitempty"`
+
+	// Option is the menu option the caller chose before recording, passed
+	// on by the voice handler.
+	Option string `dynamodbav:",omitempty"`
+
+	// RecordingKey is where the recording was archived in the recordings
+	// bucket, set once it has been.
+	RecordingKey string `dynamodbav:",omitempty" param:"-"`
+	// RecordingEmpty is set once the recording has been archived if it was
+	// too short or quiet to hold a message, a hang-up.
+	RecordingEmpty bool `dynamodbav:",omitempty" param:"-"`
+	// DigestPending is when an empty recording started, in Unix seconds,
+	// until it has been listed in a digest. Only those items are in the
+	// DigestPending index.
+	DigestPending int64 `dynamodbav:",omitempty" param:"-"`
+
+	From   string `dynamodbav:",omitempty"`
+	To     string `dynamodbav:",omitempty"`
+	Caller string `dynamodbav:",omitempty"`
+
+	FromCity      string `dynamodbav:",omitempty"`
+	FromState     string `dynamodbav:",omitempty"`
+	FromZip       string `dynamodbav:",omitempty"`
+	FromCountry   string `dynamodbav:",omitempty"`
+	ToCity        string `dynamodbav:",omitempty"`
+	ToState       string `dynamodbav:",omitempty"`
+	ToZip         string `dynamodbav:",omitempty"`
+	ToCountry     string `dynamodbav:",omitempty"`
+	CallerCity    string `dynamodbav:",omitempty"`
+	CallerState   string `dynamodbav:",omitempty"`
+	CallerZip     string `dynamodbav:",omitempty"`
+	CallerCountry string `dynamodbav:",omitempty"`
+}
+
+// Completed reports whether the event is for a finished recording that
+// can be downloaded. Callbacks without a status predate status reporting
+// and are always for completed recordings.
+func (event RecordingEvent) Completed() bool {
+	return event.RecordingStatus == "" || event.RecordingStatus == RecordingStatusCompleted
+}
+
+// Final reports whether the provider will send no further events for the
+// recording.
+func (event RecordingEvent) Final() bool {
+	return event.RecordingStatus != RecordingStatusInProgress
+}
+
+// StartTime is when the recording started, if the provider said.
+func (event RecordingEvent) StartTime() (time.Time, bool) {
+	started, err := time.Parse(time.RFC3339, event.RecordingStartTime)
+
+	return started, err == nil
+}
+
+// CallerNumber is the number that called, Caller if the voice handler
+// passed it on and From otherwise.
+func (event RecordingEvent) CallerNumber() string {
+	if event.Caller != "" {
+		return event.Caller
+	}
+
+	return event.From
+}
+
+// Validate checks the fields every recording event must have. Only
+// completed recordings are guaranteed a RecordingUrl.
+func (event RecordingEvent) Validate() error {
+	required := []struct{ name, value string }{
+		{"CallSid", event.CallSid},
+		{"RecordingSid", event.RecordingSid},
+	}
+	if event.Completed() {
+		required = append(required, struct{ name, value string }{"RecordingUrl", event.RecordingUrl})
+	}
+
+	var missing []string
+	for _, field := range required {
+		if field.value == "" {
+			missing = append(missing, field.name)
+		}
+	}
+
+	switch event.RecordingStatus {
+	case "", RecordingStatusInProgress, RecordingStatusCompleted, RecordingStatusAbsent, RecordingStatusFailed:
+	default:
+		return &ValidationError{Missing: missing, Invalid: []string{"RecordingStatus"}}
+	}
+
+	if len(missing) > 0 {
+		return &ValidationError{Missing: missing}
+	}
+
+	return nil
+}
+
+// ValidationError lists the fields an event was missing or that could not
+// be parsed.
+type ValidationError struct {
+	Missing []string
+	Invalid []string
+}
+
+func (err *ValidationError) Error() string {
+	var problems []string
+	if len(err.Missing) > 0 {
+		problems = append(problems, "missing required fields: "+strings.Join(err.Missing, ", "))
+	}
+	if len(err.Invalid) > 0 {
+		problems = append(problems, "invalid fields: "+strings.Join(err.Invalid, ", "))
+	}
+
+	return strings.Join(problems, "; ")
+}
+
+// CallEvent is a request from the provider asking what an inbound call
+// should do next.
+type CallEvent struct {
+	CallSid string
+	From    string
+	To      string
+	// Answered is set on the request that follows a Dial action when the
+	// dialled number picked up.
+	Answered bool
+	// Digits are the keys pressed during a Gather action.
+	Digits string
+}
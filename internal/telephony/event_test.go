@@ -0,0 +1,41 @@
+package telephony
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordingEventValidate(t *testing.T) {
+	t.Run("Absent recordings have no URL", func(t *testing.T) {
+		event := RecordingEvent{
+			CallSid:         "CA123",
+			RecordingSid:    "RE123",
+			RecordingStatus: RecordingStatusAbsent,
+		}
+
+		assert.NoError(t, event.Validate())
+		assert.False(t, event.Completed())
+		assert.True(t, event.Final())
+	})
+
+	t.Run("Completed recordings need a URL", func(t *testing.T) {
+		event := RecordingEvent{
+			CallSid:         "CA123",
+			RecordingSid:    "RE123",
+			RecordingStatus: RecordingStatusCompleted,
+		}
+
+		assert.EqualError(t, event.Validate(), "missing required fields: RecordingUrl")
+	})
+
+	t.Run("Unknown status", func(t *testing.T) {
+		event := RecordingEvent{
+			CallSid:         "CA123",
+			RecordingSid:    "RE123",
+			RecordingStatus: "paused",
+		}
+
+		assert.EqualError(t, event.Validate(), "invalid fields: RecordingStatus")
+	})
+}
@@ -0,0 +1,52 @@
+package telephony
+
+// Message is an inbound SMS or MMS, and the item stored in the messages
+// table. Field names follow Twilio's parameters like RecordingEvent, and
+// fields tagged param:"-" aren't read from them.
+// https://www.twilio.com/docs/messaging/guides/webhook-request
+type Message struct {
+	Provider   string `dynamodbav:",omitempty" param:"-"`
+	AccountSid string `dynamodbav:",omitempty"`
+	MessageSid string
+	From       string `dynamodbav:",omitempty"`
+	To         string `dynamodbav:",omitempty"`
+	Body       string `dynamodbav:",omitempty"`
+	NumMedia   int    `dynamodbav:",omitempty"`
+
+	FromCity    string `dynamodbav:",omitempty"`
+	FromState   string `dynamodbav:",omitempty"`
+	FromZip     string `dynamodbav:",omitempty"`
+	FromCountry string `dynamodbav:",omitempty"`
+	ToCity      string `dynamodbav:",omitempty"`
+	ToState     string `dynamodbav:",omitempty"`
+	ToZip       string `dynamodbav:",omitempty"`
+	ToCountry   string `dynamodbav:",omitempty"`
+
+	// Media is where each MMS attachment was saved, in the order the
+	// provider listed them.
+	Media []Media `dynamodbav:",omitempty" param:"-"`
+}
+
+// Media is an MMS attachment saved to the recordings bucket.
+type Media struct {
+	URL         string `dynamodbav:"-"`
+	Key         string
+	ContentType string
+}
+
+// Validate checks the fields every message must have.
+func (message Message) Validate() error {
+	var missing []string
+	if message.MessageSid == "" {
+		missing = append(missing, "MessageSid")
+	}
+	if message.From == "" {
+		missing = append(missing, "From")
+	}
+
+	if len(missing) > 0 {
+		return &ValidationError{Missing: missing}
+	}
+
+	return nil
+}
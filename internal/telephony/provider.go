@@ -0,0 +1,114 @@
+package telephony
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Provider adapts a carrier's webhooks and APIs to the pipeline.
+type Provider interface {
+	// Name identifies the provider on stored events.
+	Name() string
+
+	// ParseRecordingEvent authenticates and parses a recording callback.
+	// It returns ErrUnauthorized or a *ValidationError for bad requests.
+	ParseRecordingEvent(request events.APIGatewayProxyRequest) (RecordingEvent, error)
+
+	// ParseCallEvent authenticates and parses a call-control request.
+	ParseCallEvent(request events.APIGatewayProxyRequest) (CallEvent, error)
+
+	// FetchRecording downloads the audio of a completed recording, failing
+	// with a StatusError or ErrNotAudio rather than returning anything else.
+	FetchRecording(ctx context.Context, event RecordingEvent) (io.ReadCloser, error)
+
+	// Render turns call-control actions into the provider's response.
+	Render(actions ...Action) (events.APIGatewayProxyResponse, error)
+}
+
+// RecordingDeleter is implemented by providers that can delete a
+// recording once it has been archived.
+type RecordingDeleter interface {
+	DeleteRecording(ctx context.Context, event RecordingEvent) error
+}
+
+// MessageProvider is implemented by providers that can receive text
+// messages on the sms route.
+type MessageProvider interface {
+	Provider
+
+	// ParseMessage authenticates and parses an inbound message webhook.
+	// It returns ErrUnauthorized or a *ValidationError for bad requests.
+	ParseMessage(request events.APIGatewayProxyRequest) (Message, error)
+
+	// FetchMedia downloads an attachment of message, failing with a
+	// StatusError or ErrUnexpectedMedia rather than returning anything
+	// else.
+	FetchMedia(ctx context.Context, message Message, media Media) (io.ReadCloser, error)
+}
+
+// HTTPClient is the part of http.Client providers use.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Action is one step of a call-control response.
+type Action interface {
+	action()
+}
+
+// Say reads Text out with text-to-speech.
+type Say struct {
+	Text     string
+	Voice    string
+	Language string
+}
+
+// Play plays the audio file at URL.
+type Play struct {
+	URL string
+}
+
+// Record records a message. The provider posts the recording to
+// CallbackURL, and requests ActionURL for the next step once it ends.
+type Record struct {
+	MaxLength   int
+	FinishOnKey string
+	ActionURL   string
+	CallbackURL string
+}
+
+// Dial connects the caller to Number, requesting ActionURL once the
+// dialled call ends or isn't answered within Timeout seconds.
+type Dial struct {
+	Number    string
+	Timeout   int
+	ActionURL string
+}
+
+// Gather plays the Prompt actions while waiting for up to NumDigits key presses, then
+// requests ActionURL with the digits. If the caller presses nothing within
+// Timeout seconds, TwiML providers carry on with the next action and
+// Vonage requests ActionURL without digits.
+type Gather struct {
+	Prompt    []Action
+	NumDigits int
+	Timeout   int
+	ActionURL string
+}
+
+// Reject refuses the call as if the line were busy.
+type Reject struct{}
+
+// Hangup ends the call.
+type Hangup struct{}
+
+func (Say) action()    {}
+func (Play) action()   {}
+func (Record) action() {}
+func (Dial) action()   {}
+func (Gather) action() {}
+func (Reject) action() {}
+func (Hangup) action() {}
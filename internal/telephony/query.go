@@ -0,0 +1,109 @@
+package telephony
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// QuerySignatureParam is the query parameter SignQuery adds.
+const QuerySignatureParam = "sig"
+
+// SignQuery signs the query of a callback URL handed to a provider whose
+// webhook signatures only cover the body. Record callbacks have no caller
+// or mailbox in the body, so those travel in the query and VerifyQuery
+// checks nobody changed them.
+func SignQuery(rawURL, secret string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	query.Del(QuerySignatureParam)
+	if len(query) == 0 {
+		return rawURL, nil
+	}
+
+	query.Set(QuerySignatureParam, QuerySignature(query, secret))
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// VerifyQuery checks the signature SignQuery added to query, which must
+// have one unless it is empty.
+func VerifyQuery(query url.Values, secret string) error {
+	signature := query.Get(QuerySignatureParam)
+	signed := url.Values{}
+	for k, v := range query {
+		if k != QuerySignatureParam {
+			signed[k] = v
+		}
+	}
+	if len(signed) == 0 {
+		return nil
+	}
+
+	if secret == "" || !hmac.Equal([]byte(signature), []byte(QuerySignature(signed, secret))) {
+		return errors.New("telephony: invalid query signature")
+	}
+
+	return nil
+}
+
+// QuerySignature is the hex HMAC-SHA256 of the encoded query.
+func QuerySignature(query url.Values, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(query.Encode()))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignActions signs the query of each URL in actions that the provider
+// will call back.
+func SignActions(actions []Action, secret string) ([]Action, error) {
+	signed := make([]Action, 0, len(actions))
+	var err error
+	for _, action := range actions {
+		switch a := action.(type) {
+		case Record:
+			if a.CallbackURL, err = SignQuery(a.CallbackURL, secret); err != nil {
+				return nil, err
+			}
+			action = a
+		case Dial:
+			if a.ActionURL, err = SignQuery(a.ActionURL, secret); err != nil {
+				return nil, err
+			}
+			action = a
+		case Gather:
+			if a.ActionURL, err = SignQuery(a.ActionURL, secret); err != nil {
+				return nil, err
+			}
+			action = a
+		}
+		signed = append(signed, action)
+	}
+
+	return signed, nil
+}
+
+// Query returns the request's query parameters, all of them when API
+// Gateway passed multiple values.
+func Query(request events.APIGatewayProxyRequest) url.Values {
+	if len(request.MultiValueQueryStringParameters) > 0 {
+		return url.Values(request.MultiValueQueryStringParameters)
+	}
+
+	params := url.Values{}
+	for k, v := range request.QueryStringParameters {
+		params.Set(k, v)
+	}
+
+	return params
+}
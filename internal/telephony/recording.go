@@ -0,0 +1,117 @@
+package telephony
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ErrNotAudio is returned for a recording download that isn't audio, such
+// as an HTML error page served with a 200.
+var ErrNotAudio = errors.New("recording is not audio")
+
+// StatusError is a recording request that got a non-2xx response.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+}
+
+func (err *StatusError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", err.Method, err.URL, err.StatusCode, http.StatusText(err.StatusCode))
+}
+
+// Temporary reports whether the download may succeed later: the recording
+// isn't ready yet, or the carrier is rate limiting or failing.
+func (err *StatusError) Temporary() bool {
+	return err.StatusCode == http.StatusNotFound ||
+		err.StatusCode == http.StatusTooManyRequests ||
+		err.StatusCode >= 500
+}
+
+// ErrUnexpectedMedia is returned for a media download that isn't the type
+// the message said it was.
+var ErrUnexpectedMedia = errors.New("media is not the expected type")
+
+// RecordingBody returns the body of a recording download. The body is
+// closed, and an error returned, when the status isn't 2xx or the content
+// isn't audio.
+func RecordingBody(resp *http.Response) (io.ReadCloser, error) {
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if strings.HasPrefix(mediaType, "audio/") {
+		return resp.Body, nil
+	}
+	if mediaType != "" && mediaType != "application/octet-stream" {
+		resp.Body.Close()
+
+		return nil, fmt.Errorf("%w: %s", ErrNotAudio, mediaType)
+	}
+
+	// Without a useful Content-Type, look at the content itself.
+	reader := bufio.NewReaderSize(resp.Body, 512)
+	head, _ := reader.Peek(512)
+	if !isAudio(head) {
+		resp.Body.Close()
+
+		return nil, fmt.Errorf("%w: %s", ErrNotAudio, http.DetectContentType(head))
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{reader, resp.Body}, nil
+}
+
+// MediaBody returns the body of an MMS attachment download. The body is
+// closed, and an error returned, when the status isn't 2xx or the
+// Content-Type isn't contentType.
+func MediaBody(resp *http.Response, contentType string) (io.ReadCloser, error) {
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	expected, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "" || !strings.EqualFold(mediaType, expected) {
+		resp.Body.Close()
+
+		return nil, fmt.Errorf("%w: %s, not %s", ErrUnexpectedMedia, mediaType, contentType)
+	}
+
+	return resp.Body, nil
+}
+
+// checkStatus closes the body of a non-2xx response and returns a
+// StatusError for it.
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+
+	resp.Body.Close()
+
+	err := &StatusError{Method: http.MethodGet, StatusCode: resp.StatusCode}
+	if resp.Request != nil {
+		err.URL = resp.Request.URL.String()
+	}
+
+	return err
+}
+
+// isAudio sniffs the start of a file, recognising MP3 frames without an
+// ID3 tag as well as the formats http.DetectContentType knows.
+func isAudio(head []byte) bool {
+	if strings.HasPrefix(http.DetectContentType(head), "audio/") {
+		return true
+	}
+
+	return len(head) >= 2 && head[0] == 0xff && head[1]&0xe0 == 0xe0
+}
@@ -0,0 +1,102 @@
+package telephony
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newResponse(statusCode int, contentType string, body []byte) *http.Response {
+	resp := &http.Response{
+		StatusCode: statusCode,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+	}
+	if contentType != "" {
+		resp.Header.Set("Content-Type", contentType)
+	}
+
+	return resp
+}
+
+func TestRecordingBody(t *testing.T) {
+	mp3 := []byte{0xff, 0xfb, 0x90, 0x64, 0x00}
+
+	t.Run("Audio", func(t *testing.T) {
+		body, err := RecordingBody(newResponse(200, "audio/mpeg", []byte("audio")))
+		assert.NoError(t, err)
+
+		content, err := ioutil.ReadAll(body)
+		assert.NoError(t, err)
+		assert.Equal(t, "audio", string(content))
+	})
+
+	t.Run("Sniffs untyped audio", func(t *testing.T) {
+		body, err := RecordingBody(newResponse(200, "application/octet-stream", mp3))
+		assert.NoError(t, err)
+
+		content, err := ioutil.ReadAll(body)
+		assert.NoError(t, err)
+		assert.Equal(t, mp3, content)
+
+		_, err = RecordingBody(newResponse(200, "", []byte("ID3\x04\x00rest of the file")))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Error page", func(t *testing.T) {
+		_, err := RecordingBody(newResponse(200, "text/html; charset=utf-8", []byte("<html>Sign in</html>")))
+		assert.True(t, errors.Is(err, ErrNotAudio))
+
+		_, err = RecordingBody(newResponse(200, "", []byte("<html>Sign in</html>")))
+		assert.True(t, errors.Is(err, ErrNotAudio))
+	})
+
+	t.Run("Status", func(t *testing.T) {
+		cases := map[int]bool{
+			401: false,
+			403: false,
+			404: true,
+			429: true,
+			503: true,
+		}
+
+		for statusCode, temporary := range cases {
+			_, err := RecordingBody(newResponse(statusCode, "audio/mpeg", mp3))
+
+			var statusErr *StatusError
+			assert.True(t, errors.As(err, &statusErr))
+			assert.Equal(t, temporary, statusErr.Temporary(), statusCode)
+		}
+	})
+}
+
+func TestMediaBody(t *testing.T) {
+	t.Run("Expected type", func(t *testing.T) {
+		body, err := MediaBody(newResponse(200, "image/jpeg", []byte("photo")), "image/jpeg")
+		assert.NoError(t, err)
+
+		content, err := ioutil.ReadAll(body)
+		assert.NoError(t, err)
+		assert.Equal(t, "photo", string(content))
+	})
+
+	t.Run("Other type", func(t *testing.T) {
+		_, err := MediaBody(newResponse(200, "text/html; charset=utf-8", []byte("<html>Sign in</html>")), "image/jpeg")
+		assert.True(t, errors.Is(err, ErrUnexpectedMedia))
+
+		_, err = MediaBody(newResponse(200, "", []byte("photo")), "image/jpeg")
+		assert.True(t, errors.Is(err, ErrUnexpectedMedia))
+	})
+
+	t.Run("Status", func(t *testing.T) {
+		_, err := MediaBody(newResponse(401, "image/jpeg", []byte("photo")), "image/jpeg")
+
+		var statusErr *StatusError
+		assert.True(t, errors.As(err, &statusErr))
+		assert.Equal(t, 401, statusErr.StatusCode)
+	})
+}
@@ -0,0 +1,105 @@
+package telephony
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Header does a case-insensitive header lookup, API Gateway passes headers
+// through with whatever case the client used.
+func Header(request events.APIGatewayProxyRequest, name string) string {
+	if v, ok := request.Headers[name]; ok {
+		return v
+	}
+	for k, v := range request.Headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// RawQueryHeader carries the query string exactly as the provider sent it.
+// API Gateway's proxy events only have the parsed parameters, so adapters
+// that do have the raw query, like the local server, pass it in this
+// header.
+const RawQueryHeader = "X-Raw-Query"
+
+// RequestURL rebuilds the URL the provider requested, which is what most
+// of them sign. The query is the raw one from RawQueryHeader when that
+// holds the request's parameters, so the URL matches byte for byte;
+// otherwise it is re-encoded from the parameters, sorted by name.
+func RequestURL(request events.APIGatewayProxyRequest) string {
+	scheme := Header(request, "X-Forwarded-Proto")
+	if scheme == "" {
+		scheme = "https"
+	}
+
+	host := Header(request, "Host")
+	if host == "" {
+		host = request.RequestContext.DomainName
+	}
+
+	path := request.Path
+	if stage := request.RequestContext.Stage; stage != "" && !strings.HasPrefix(path, "/"+stage+"/") {
+		path = "/" + stage + path
+	}
+
+	u := url.URL{
+		Scheme: scheme,
+		Host:   host,
+		Path:   path,
+	}
+	if raw := Header(request, RawQueryHeader); raw != "" && rawQueryMatches(request, raw) {
+		u.RawQuery = raw
+	} else if len(request.MultiValueQueryStringParameters) > 0 {
+		u.RawQuery = url.Values(request.MultiValueQueryStringParameters).Encode()
+	} else if len(request.QueryStringParameters) > 0 {
+		query := url.Values{}
+		for k, v := range request.QueryStringParameters {
+			query.Set(k, v)
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	return u.String()
+}
+
+// rawQueryMatches reports whether raw parses to the request's parameters.
+// Clients can send RawQueryHeader too, and the URL mustn't be signed over
+// a query other than the one the handlers read.
+func rawQueryMatches(request events.APIGatewayProxyRequest, raw string) bool {
+	parsed, err := url.ParseQuery(raw)
+	if err != nil {
+		return false
+	}
+
+	params := request.MultiValueQueryStringParameters
+	if len(params) == 0 {
+		params = map[string][]string{}
+		for k, v := range request.QueryStringParameters {
+			params[k] = []string{v}
+		}
+	}
+
+	return reflect.DeepEqual(url.Values(params), parsed)
+}
+
+// RouteURL returns the URL of another route on the same API as request, so
+// call-control responses can point the provider at sibling handlers without
+// hardcoding the gateway.
+func RouteURL(request events.APIGatewayProxyRequest, route string, query url.Values) string {
+	u, _ := url.Parse(RequestURL(request))
+
+	u.Path = u.Path[:strings.LastIndex(u.Path, "/")+1] + route
+	u.RawQuery = ""
+	if len(query) > 0 {
+		u.RawQuery = query.Encode()
+	}
+
+	return u.String()
+}
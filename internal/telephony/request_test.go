@@ -0,0 +1,66 @@
+package telephony
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestURL(t *testing.T) {
+	t.Run("Adds stage to path", func(t *testing.T) {
+		request := events.APIGatewayProxyRequest{
+			Path:    "/webhook",
+			Headers: map[string]string{"host": "abc.execute-api.eu-west-2.amazonaws.com"},
+			RequestContext: events.APIGatewayProxyRequestContext{
+				Stage: "live",
+			},
+		}
+
+		assert.Equal(t, "https://abc.execute-api.eu-west-2.amazonaws.com/live/webhook", RequestURL(request))
+	})
+
+	t.Run("Keeps query string", func(t *testing.T) {
+		request := events.APIGatewayProxyRequest{
+			Path:                            "/webhook",
+			Headers:                         map[string]string{"Host": "example.com", "X-Forwarded-Proto": "https"},
+			MultiValueQueryStringParameters: map[string][]string{"b": {"2"}, "a": {"1"}},
+		}
+
+		assert.Equal(t, "https://example.com/webhook?a=1&b=2", RequestURL(request))
+	})
+
+	t.Run("Uses raw query string", func(t *testing.T) {
+		request := events.APIGatewayProxyRequest{
+			Path:                            "/webhook",
+			Headers:                         map[string]string{"Host": "example.com", "X-Raw-Query": "b=2&a=%31&c"},
+			MultiValueQueryStringParameters: map[string][]string{"b": {"2"}, "a": {"1"}, "c": {""}},
+		}
+
+		assert.Equal(t, "https://example.com/webhook?b=2&a=%31&c", RequestURL(request))
+	})
+
+	t.Run("Ignores raw query string of other parameters", func(t *testing.T) {
+		request := events.APIGatewayProxyRequest{
+			Path:                            "/webhook",
+			Headers:                         map[string]string{"Host": "example.com", "X-Raw-Query": "Caller=%2B441632960000"},
+			MultiValueQueryStringParameters: map[string][]string{"Caller": {"+441632960999"}},
+		}
+
+		assert.Equal(t, "https://example.com/webhook?Caller=%2B441632960999", RequestURL(request))
+	})
+}
+
+func TestRouteURL(t *testing.T) {
+	request := events.APIGatewayProxyRequest{
+		Path:                  "/voice",
+		Headers:               map[string]string{"Host": "example.com"},
+		QueryStringParameters: map[string]string{"Step": "dialled"},
+		RequestContext: events.APIGatewayProxyRequestContext{
+			Stage: "live",
+		},
+	}
+
+	assert.Equal(t, "https://example.com/live/webhook?To=%2B441632960000", RouteURL(request, "webhook", url.Values{"To": {"+441632960000"}}))
+}
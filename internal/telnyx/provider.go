@@ -0,0 +1,190 @@
+// Package telnyx adapts Telnyx TeXML to the telephony pipeline. TeXML
+// posts Twilio's parameters and takes TwiML, but webhooks are signed with
+// Ed25519 rather than an HMAC, over the body alone. The query of each
+// callback URL is signed with a secret of our own instead.
+package telnyx
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"answering-machine/internal/telephony"
+	"answering-machine/internal/twilio"
+)
+
+// Name identifies Telnyx on stored events and in TELEPHONY_PROVIDER.
+const Name = "telnyx"
+
+// Signature headers, the signature covers "<timestamp>|<body>".
+// https://developers.telnyx.com/docs/messaging/messages/receiving-webhooks#webhook-signing
+const (
+	SignatureHeader = "Telnyx-Signature-Ed25519"
+	TimestampHeader = "Telnyx-Timestamp"
+)
+
+// tolerance is how old a signed webhook may be, so captured requests can't
+// be replayed.
+const tolerance = 5 * time.Minute
+
+var errInvalidPublicKey = errors.New("telnyx: public key is not Ed25519")
+
+// Provider is the Telnyx telephony.Provider.
+type Provider struct {
+	publicKey   ed25519.PublicKey
+	apiKey      string
+	querySecret string
+	httpClient  telephony.HTTPClient
+	now         func() time.Time
+}
+
+// NewProvider returns a Provider that verifies webhooks with the account's
+// public key, and the query of its callback URLs with querySecret. apiKey
+// is sent when downloading recordings if set.
+func NewProvider(publicKey ed25519.PublicKey, apiKey, querySecret string, httpClient telephony.HTTPClient) *Provider {
+	return &Provider{
+		publicKey:   publicKey,
+		apiKey:      apiKey,
+		querySecret: querySecret,
+		httpClient:  httpClient,
+		now:         time.Now,
+	}
+}
+
+// ParsePublicKey decodes the base64 public key shown in the portal.
+func ParsePublicKey(encoded string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, errInvalidPublicKey
+	}
+
+	return ed25519.PublicKey(key), nil
+}
+
+// Name returns "telnyx".
+func (provider *Provider) Name() string {
+	return Name
+}
+
+// ParseRecordingEvent verifies and parses a TeXML recording status
+// callback. The caller, mailbox and menu option come from the signed query
+// the voice handler set.
+func (provider *Provider) ParseRecordingEvent(request events.APIGatewayProxyRequest) (telephony.RecordingEvent, error) {
+	params, err := provider.params(request)
+	if err != nil {
+		return telephony.RecordingEvent{}, err
+	}
+
+	event, err := twilio.DecodeRecordingEvent(twilio.CallbackParams(request, params))
+	event.Provider = Name
+
+	return event, err
+}
+
+// ParseCallEvent verifies and parses a TeXML voice request.
+func (provider *Provider) ParseCallEvent(request events.APIGatewayProxyRequest) (telephony.CallEvent, error) {
+	params, err := provider.params(request)
+	if err != nil {
+		return telephony.CallEvent{}, err
+	}
+
+	return twilio.DecodeCallEvent(params), nil
+}
+
+func (provider *Provider) params(request events.APIGatewayProxyRequest) (url.Values, error) {
+	body := request.Body
+	if request.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return nil, &telephony.ValidationError{Invalid: []string{"Body"}}
+		}
+		body = string(decoded)
+	}
+
+	if !provider.verify(request, body) {
+		return nil, telephony.ErrUnauthorized
+	}
+
+	if err := telephony.VerifyQuery(telephony.Query(request), provider.querySecret); err != nil {
+		return nil, telephony.ErrUnauthorized
+	}
+
+	params, err := url.ParseQuery(body)
+	if err != nil {
+		return nil, &telephony.ValidationError{Invalid: []string{"Body"}}
+	}
+
+	return params, nil
+}
+
+func (provider *Provider) verify(request events.APIGatewayProxyRequest, body string) bool {
+	if len(provider.publicKey) != ed25519.PublicKeySize {
+		return false
+	}
+
+	timestamp := telephony.Header(request, TimestampHeader)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := provider.now().Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return false
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(telephony.Header(request, SignatureHeader))
+	if err != nil {
+		return false
+	}
+
+	return ed25519.Verify(provider.publicKey, []byte(timestamp+"|"+body), signature)
+}
+
+// FetchRecording downloads a recording, TeXML's RecordingUrl is the file
+// itself.
+func (provider *Provider) FetchRecording(ctx context.Context, event telephony.RecordingEvent) (io.ReadCloser, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, event.RecordingUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	if provider.apiKey != "" {
+		request.Header.Set("Authorization", "Bearer "+provider.apiKey)
+	}
+
+	resp, err := provider.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return telephony.RecordingBody(resp)
+}
+
+// Render responds with the TeXML for actions, which is TwiML, signing the
+// query of each URL Telnyx will call back.
+func (provider *Provider) Render(actions ...telephony.Action) (events.APIGatewayProxyResponse, error) {
+	actions, err := telephony.SignActions(actions, provider.querySecret)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 500,
+		}, err
+	}
+
+	response, err := twilio.NewResponse(actions...)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 500,
+		}, err
+	}
+
+	return response.APIGatewayProxyResponse()
+}
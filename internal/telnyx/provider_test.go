@@ -0,0 +1,176 @@
+package telnyx
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+
+	"answering-machine/internal/telephony"
+)
+
+var now = time.Date(2026, 10, 19, 10, 15, 16, 0, time.UTC)
+
+// newFixtureRequest signs fixture with key, as Telnyx would, and query with
+// the test provider's secret, as Render would.
+func newFixtureRequest(t *testing.T, key ed25519.PrivateKey, fixture string, signedAt time.Time, query url.Values) events.APIGatewayProxyRequest {
+	body, err := ioutil.ReadFile("testdata/" + fixture)
+	assert.NoError(t, err)
+
+	signed, err := telephony.SignQuery("https://example.com/webhook?"+query.Encode(), "secret")
+	assert.NoError(t, err)
+	u, err := url.Parse(signed)
+	assert.NoError(t, err)
+	// API Gateway passes the query both ways.
+	single := map[string]string{}
+	for k := range u.Query() {
+		single[k] = u.Query().Get(k)
+	}
+
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	signature := ed25519.Sign(key, []byte(timestamp+"|"+string(body)))
+
+	return events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"telnyx-signature-ed25519": base64.StdEncoding.EncodeToString(signature),
+			"telnyx-timestamp":         timestamp,
+		},
+		QueryStringParameters:           single,
+		MultiValueQueryStringParameters: u.Query(),
+		Body:                            string(body),
+	}
+}
+
+func newTestProvider(t *testing.T, httpClient telephony.HTTPClient) (*Provider, ed25519.PrivateKey) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	key, err := ParsePublicKey(base64.StdEncoding.EncodeToString(public))
+	assert.NoError(t, err)
+
+	provider := NewProvider(key, "KEY123", "secret", httpClient)
+	provider.now = func() time.Time { return now }
+
+	return provider, private
+}
+
+func TestProviderParseRecordingEvent(t *testing.T) {
+	provider, key := newTestProvider(t, nil)
+
+	t.Run("Completed recording", func(t *testing.T) {
+		event, err := provider.ParseRecordingEvent(newFixtureRequest(t, key, "recording-completed.form", now, url.Values{
+			"Caller": {"+447700900123"},
+			"To":     {"+441632960000"},
+		}))
+
+		assert.NoError(t, err)
+		assert.Equal(t, telephony.RecordingEvent{
+			Provider:          "telnyx",
+			AccountSid:        "0ccc7b54-4df3-4bca-a65a-3da1ecc777f0",
+			CallSid:           "v3:uGKQmi-pTxRmm2EYpe2ZuTeY5aMwQg8VaN_8Kd9vfO0gFrSbGkWMvg",
+			RecordingSid:      "6f3a2e1c-8a41-4a2b-b6e9-8ca4e0c1d2f3",
+			RecordingUrl:      "https://api.telnyx.com/v2/recordings/6f3a2e1c-8a41-4a2b-b6e9-8ca4e0c1d2f3/download.mp3",
+			RecordingStatus:   "completed",
+			RecordingDuration: 14,
+			Caller:            "+447700900123",
+			To:                "+441632960000",
+		}, event)
+	})
+
+	t.Run("Tampered query", func(t *testing.T) {
+		request := newFixtureRequest(t, key, "recording-completed.form", now, url.Values{"Caller": {"+447700900123"}})
+		request.QueryStringParameters["Caller"] = "+447700900999"
+		request.MultiValueQueryStringParameters["Caller"] = []string{"+447700900999"}
+
+		_, err := provider.ParseRecordingEvent(request)
+
+		assert.Equal(t, telephony.ErrUnauthorized, err)
+	})
+
+	t.Run("Unsigned query", func(t *testing.T) {
+		request := newFixtureRequest(t, key, "recording-completed.form", now, nil)
+		request.QueryStringParameters = map[string]string{"Option": "urgent"}
+
+		_, err := provider.ParseRecordingEvent(request)
+
+		assert.Equal(t, telephony.ErrUnauthorized, err)
+	})
+
+	t.Run("Signed by another key", func(t *testing.T) {
+		_, other := newTestProvider(t, nil)
+
+		_, err := provider.ParseRecordingEvent(newFixtureRequest(t, other, "recording-completed.form", now, nil))
+
+		assert.Equal(t, telephony.ErrUnauthorized, err)
+	})
+
+	t.Run("Replayed", func(t *testing.T) {
+		_, err := provider.ParseRecordingEvent(newFixtureRequest(t, key, "recording-completed.form", now.Add(-time.Hour), nil))
+
+		assert.Equal(t, telephony.ErrUnauthorized, err)
+	})
+}
+
+func TestProviderParseCallEvent(t *testing.T) {
+	provider, key := newTestProvider(t, nil)
+
+	event, err := provider.ParseCallEvent(newFixtureRequest(t, key, "voice.form", now, nil))
+
+	assert.NoError(t, err)
+	assert.Equal(t, telephony.CallEvent{
+		CallSid: "v3:uGKQmi-pTxRmm2EYpe2ZuTeY5aMwQg8VaN_8Kd9vfO0gFrSbGkWMvg",
+		From:    "+447700900123",
+		To:      "+441632960000",
+	}, event)
+}
+
+type mockHTTPClient struct {
+	t *testing.T
+}
+
+func (mock mockHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	assert.Equal(mock.t, "https://api.telnyx.com/v2/recordings/abc/download.mp3", req.URL.String())
+	assert.Equal(mock.t, "Bearer KEY123", req.Header.Get("Authorization"))
+
+	return &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": {"audio/mpeg"}},
+		Body:       ioutil.NopCloser(bytes.NewBufferString("audio")),
+	}, nil
+}
+
+func TestProviderFetchRecording(t *testing.T) {
+	provider, _ := newTestProvider(t, mockHTTPClient{t: t})
+
+	body, err := provider.FetchRecording(context.Background(), telephony.RecordingEvent{
+		RecordingUrl: "https://api.telnyx.com/v2/recordings/abc/download.mp3",
+	})
+	assert.NoError(t, err)
+
+	audio, err := ioutil.ReadAll(body)
+	assert.NoError(t, err)
+	assert.Equal(t, "audio", string(audio))
+}
+
+func TestProviderRender(t *testing.T) {
+	provider, _ := newTestProvider(t, nil)
+
+	response, err := provider.Render(telephony.Record{
+		CallbackURL: "https://example.com/webhook?Caller=%2B447700900123",
+		MaxLength:   120,
+	})
+
+	assert.NoError(t, err)
+	assert.Contains(t, response.Body, `recordingStatusCallback="https://example.com/webhook?Caller=%2B447700900123&amp;sig=`+
+		telephony.QuerySignature(url.Values{"Caller": {"+447700900123"}}, "secret")+`"`)
+}
@@ -0,0 +1,50 @@
+// Package transcriber picks the speech backend a deployment uses, so the
+// transcription handler doesn't depend on any one of them.
+package transcriber
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+
+	speech "cloud.google.com/go/speech/apiv1p1beta1"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/service/transcribeservice"
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"google.golang.org/api/option"
+
+	"answering-machine/internal/awstranscribe"
+	"answering-machine/internal/googlespeech"
+	"answering-machine/internal/transcription"
+)
+
+// FromEnv returns the backend named by TRANSCRIBER, Google if it isn't
+// set. Google reads its service account key from GOOGLE_AUTH_JSON_B64,
+// Amazon Transcribe uses the AWS session.
+func FromEnv(ctx context.Context, sess client.ConfigProvider, httpClient *http.Client) (transcription.Transcriber, error) {
+	switch name := os.Getenv("TRANSCRIBER"); name {
+	case "", googlespeech.Name:
+		credentials, err := base64.StdEncoding.DecodeString(os.Getenv("GOOGLE_AUTH_JSON_B64"))
+		if err != nil {
+			return nil, fmt.Errorf("GOOGLE_AUTH_JSON_B64: %w", err)
+		}
+
+		client, err := speech.NewClient(ctx, option.WithCredentialsJSON(credentials))
+		if err != nil {
+			return nil, err
+		}
+
+		return googlespeech.NewTranscriber(client), nil
+	case awstranscribe.Name:
+		client := transcribeservice.New(sess)
+		xray.AWS(client.Client)
+
+		return awstranscribe.NewTranscriber(client, httpClient), nil
+	case transcription.FakeName:
+		return transcription.Fake{}, nil
+	default:
+		return nil, fmt.Errorf("unknown TRANSCRIBER %q", name)
+	}
+}
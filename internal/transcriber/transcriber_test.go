@@ -0,0 +1,57 @@
+package transcriber
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/stretchr/testify/assert"
+
+	"answering-machine/internal/awstranscribe"
+	"answering-machine/internal/transcription"
+)
+
+func TestFromEnv(t *testing.T) {
+	defer os.Unsetenv("TRANSCRIBER")
+
+	sess := session.Must(session.NewSession(&aws.Config{Region: aws.String("eu-west-2")}))
+
+	t.Run("Amazon Transcribe", func(t *testing.T) {
+		os.Setenv("TRANSCRIBER", "aws")
+
+		transcriber, err := FromEnv(context.Background(), sess, http.DefaultClient)
+
+		assert.NoError(t, err)
+		assert.IsType(t, &awstranscribe.Transcriber{}, transcriber)
+	})
+
+	t.Run("Fake", func(t *testing.T) {
+		os.Setenv("TRANSCRIBER", "fake")
+
+		transcriber, err := FromEnv(context.Background(), sess, http.DefaultClient)
+
+		assert.NoError(t, err)
+		assert.Equal(t, transcription.Fake{}, transcriber)
+	})
+
+	t.Run("Google needs credentials", func(t *testing.T) {
+		os.Unsetenv("TRANSCRIBER")
+		os.Setenv("GOOGLE_AUTH_JSON_B64", "not base64")
+		defer os.Unsetenv("GOOGLE_AUTH_JSON_B64")
+
+		_, err := FromEnv(context.Background(), sess, http.DefaultClient)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("Unknown backend", func(t *testing.T) {
+		os.Setenv("TRANSCRIBER", "stenographer")
+
+		_, err := FromEnv(context.Background(), sess, http.DefaultClient)
+
+		assert.EqualError(t, err, `unknown TRANSCRIBER "stenographer"`)
+	})
+}
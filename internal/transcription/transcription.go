@@ -0,0 +1,116 @@
+// Package transcription is the vendor-neutral side of speech to text: the
+// recordings the transcription handler asks for and the transcripts it
+// stores. Each speech backend implements Transcriber.
+package transcription
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"answering-machine/internal/audio"
+)
+
+// Transcriber turns a recording into text.
+type Transcriber interface {
+	Transcribe(ctx context.Context, request Request) (Transcript, error)
+}
+
+// Request is an archived recording to transcribe. Backends that read
+// recordings from S3 themselves use Bucket and Key, the others Audio.
+type Request struct {
+	Bucket string
+	Key    string
+	Audio  []byte
+	// Language is a BCP-47 code such as en-GB.
+	Language string
+	// AlternativeLanguages may be spoken instead of Language, for backends
+	// that detect it.
+	AlternativeLanguages []string
+	// Duration is how long the recording lasts, if known.
+	Duration time.Duration
+	// Format is the recording's encoding, if it could be probed.
+	Format audio.Format
+	// Operation resumes waiting for a transcription an earlier request
+	// started, from its PendingError.
+	Operation string
+}
+
+// PendingError is returned when the context is done before a started
+// transcription finishes. Passing Operation in a later Request picks up
+// where it left off.
+type PendingError struct {
+	Operation string
+}
+
+func (err *PendingError) Error() string {
+	return fmt.Sprintf("transcription %s is still running", err.Operation)
+}
+
+// Transcript is the text of a recording, empty if no speech was
+// recognised.
+type Transcript struct {
+	Text string
+	// Segments are the consecutive utterances Text is made of.
+	Segments []Segment
+	// Language is the BCP-47 code of the language recognised.
+	Language string
+	// Backend names the Transcriber that produced it, so backends can be
+	// compared.
+	Backend string
+}
+
+// Segment is one utterance of a recording.
+type Segment struct {
+	Text string
+	// Confidence is from 0 to 1, or 0 if the backend didn't give one.
+	Confidence float64
+	Words      []Word
+}
+
+// Word is a recognised word and when it was said, in seconds from the
+// start of the recording.
+type Word struct {
+	Word  string
+	Start float64
+	End   float64
+}
+
+// NewTranscript joins segments in language into a Transcript from
+// backend.
+func NewTranscript(backend, language string, segments []Segment) Transcript {
+	parts := make([]string, 0, len(segments))
+	for i := range segments {
+		segments[i].Text = strings.TrimSpace(segments[i].Text)
+		parts = append(parts, segments[i].Text)
+	}
+
+	return Transcript{
+		Text:     strings.Join(parts, " "),
+		Segments: segments,
+		Language: language,
+		Backend:  backend,
+	}
+}
+
+// FakeName is the name Fake reports as its Backend.
+const FakeName = "fake"
+
+// Fake is a deterministic Transcriber for tests and local runs. It returns
+// the text in Transcripts for the request's Key, or one naming the
+// recording.
+type Fake struct {
+	Transcripts map[string]string
+}
+
+// Transcribe returns the fake transcript of request.
+func (fake Fake) Transcribe(ctx context.Context, request Request) (Transcript, error) {
+	text, ok := fake.Transcripts[request.Key]
+	if !ok {
+		text = fmt.Sprintf("Transcript of %s in %s.", path.Base(request.Key), request.Language)
+	}
+
+	return NewTranscript(FakeName, request.Language, []Segment{{Text: text, Confidence: 1}}), nil
+}
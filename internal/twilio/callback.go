@@ -0,0 +1,115 @@
+package twilio
+
+import (
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"answering-machine/internal/telephony"
+)
+
+// RecordingStatusCallbackEvents are the statuses the voice handler asks
+// Twilio to report.
+var RecordingStatusCallbackEvents = []string{
+	telephony.RecordingStatusInProgress,
+	telephony.RecordingStatusCompleted,
+	telephony.RecordingStatusAbsent,
+}
+
+// CallbackParams adds the query string of a callback to its form
+// parameters, without overriding anything the body sets. The voice handler
+// passes call details on the query string.
+func CallbackParams(request events.APIGatewayProxyRequest, params url.Values) url.Values {
+	for k, v := range request.QueryStringParameters {
+		if _, ok := params[k]; !ok {
+			params.Set(k, v)
+		}
+	}
+
+	return params
+}
+
+// DecodeRecordingEvent reads and validates the parameters of a recording
+// status callback. TeXML providers post the same parameters.
+func DecodeRecordingEvent(params url.Values) (telephony.RecordingEvent, error) {
+	event := telephony.RecordingEvent{}
+
+	invalid := decodeParams(params, &event)
+	event.Provider = ""
+
+	// Twilio gives RecordingStartTime as in an email. A time that can't be
+	// read is dropped rather than failing the callback, it only dates the
+	// archived recording.
+	if started, err := time.Parse(time.RFC1123Z, event.RecordingStartTime); err == nil {
+		event.RecordingStartTime = started.UTC().Format(time.RFC3339)
+	} else {
+		event.RecordingStartTime = ""
+	}
+
+	err := event.Validate()
+	if len(invalid) > 0 {
+		validationErr, ok := err.(*telephony.ValidationError)
+		if !ok {
+			validationErr = &telephony.ValidationError{}
+		}
+		validationErr.Invalid = append(invalid, validationErr.Invalid...)
+		err = validationErr
+	}
+
+	return event, err
+}
+
+// DecodeCallEvent reads the parameters of a voice request.
+func DecodeCallEvent(params url.Values) telephony.CallEvent {
+	return telephony.CallEvent{
+		CallSid:  params.Get("CallSid"),
+		From:     params.Get("From"),
+		To:       params.Get("To"),
+		Answered: params.Get("DialCallStatus") == "completed",
+		Digits:   params.Get("Digits"),
+	}
+}
+
+// decodeParams copies params into the same named fields of out, returning
+// the names of any that could not be converted. Fields tagged param:"-"
+// are set by the pipeline, never from a request.
+func decodeParams(params url.Values, out interface{}) []string {
+	var invalid []string
+
+	v := reflect.ValueOf(out).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		fieldType := v.Type().Field(i)
+		if fieldType.Tag.Get("param") == "-" {
+			continue
+		}
+
+		name := fieldType.Name
+		value := params.Get(name)
+		if value == "" {
+			continue
+		}
+
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.Slice:
+			// Lists like Media come from numbered parameters, the caller
+			// reads those itself.
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				invalid = append(invalid, name)
+				continue
+			}
+			field.SetInt(int64(n))
+		default:
+			invalid = append(invalid, name)
+		}
+	}
+
+	return invalid
+}
@@ -0,0 +1,116 @@
+package twilio
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+
+	"answering-machine/internal/telephony"
+)
+
+func TestDecodeRecordingEvent(t *testing.T) {
+	t.Run("Parses body and query", func(t *testing.T) {
+		body := url.Values{
+			"AccountSid":        {"AC123"},
+			"CallSid":           {"CA123"},
+			"RecordingSid":      {"RE123"},
+			"RecordingUrl":      {"https://api.twilio.com/recording/RE123"},
+			"RecordingStatus":   {"completed"},
+			"RecordingDuration": {"14"},
+			// RFC 1123 with a numeric zone, stored as RFC 3339 in UTC.
+			"RecordingStartTime": {"Mon, 19 Oct 2026 11:15:02 +0100"},
+		}
+		params := CallbackParams(events.APIGatewayProxyRequest{
+			QueryStringParameters: map[string]string{
+				"Caller":       "+447700900123",
+				"RecordingSid": "ignored",
+			},
+		}, body)
+
+		event, err := DecodeRecordingEvent(params)
+
+		assert.NoError(t, err)
+		assert.Equal(t, telephony.RecordingEvent{
+			AccountSid:         "AC123",
+			CallSid:            "CA123",
+			RecordingSid:       "RE123",
+			RecordingUrl:       "https://api.twilio.com/recording/RE123",
+			RecordingStatus:    "completed",
+			RecordingDuration:  14,
+			RecordingStartTime: "2026-10-19T10:15:02Z",
+			Caller:             "+447700900123",
+		}, event)
+	})
+
+	t.Run("Leaves the provider to the caller", func(t *testing.T) {
+		event, err := DecodeRecordingEvent(url.Values{
+			"Provider":        {"vonage"},
+			"CallSid":         {"CA123"},
+			"RecordingSid":    {"RE123"},
+			"RecordingStatus": {"absent"},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "", event.Provider)
+	})
+
+	t.Run("Ignores the pipeline's own fields", func(t *testing.T) {
+		params := CallbackParams(events.APIGatewayProxyRequest{
+			QueryStringParameters: map[string]string{
+				"RecordingEmpty": "1",
+				"RecordingKey":   "2026/10/19/RE456.wav",
+			},
+		}, url.Values{
+			"CallSid":         {"CA123"},
+			"RecordingSid":    {"RE123"},
+			"RecordingStatus": {"absent"},
+		})
+
+		event, err := DecodeRecordingEvent(params)
+
+		assert.NoError(t, err)
+		assert.False(t, event.RecordingEmpty)
+		assert.Equal(t, "", event.RecordingKey)
+	})
+
+	t.Run("Names missing and invalid fields", func(t *testing.T) {
+		_, err := DecodeRecordingEvent(url.Values{
+			"RecordingSid":      {"RE123"},
+			"RecordingDuration": {"long"},
+		})
+
+		assert.EqualError(t, err, "missing required fields: CallSid, RecordingUrl; invalid fields: RecordingDuration")
+	})
+}
+
+func TestDecodeCallEvent(t *testing.T) {
+	event := DecodeCallEvent(url.Values{
+		"CallSid":        {"CA123"},
+		"From":           {"+447700900123"},
+		"To":             {"+441632960000"},
+		"DialCallStatus": {"completed"},
+		"Digits":         {"1"},
+	})
+
+	assert.Equal(t, telephony.CallEvent{
+		CallSid:  "CA123",
+		From:     "+447700900123",
+		To:       "+441632960000",
+		Answered: true,
+		Digits:   "1",
+	}, event)
+}
+
+func TestDecodeParams(t *testing.T) {
+	var out struct {
+		Name     string
+		Answered bool
+	}
+
+	invalid := decodeParams(url.Values{"Name": {"test"}, "Answered": {"true"}}, &out)
+
+	assert.Equal(t, []string{"Answered"}, invalid)
+	assert.Equal(t, "test", out.Name)
+}
@@ -0,0 +1,45 @@
+package twilio
+
+import (
+	"net/url"
+	"strconv"
+
+	"answering-machine/internal/telephony"
+)
+
+// DecodeMessage reads and validates the parameters of an incoming message
+// webhook. Media is listed with its Twilio URL, Key is left for the caller
+// to set once it has been saved.
+func DecodeMessage(params url.Values) (telephony.Message, error) {
+	message := telephony.Message{}
+
+	invalid := decodeParams(params, &message)
+	message.Provider = Name
+	message.Media = nil
+
+	for i := 0; i < message.NumMedia; i++ {
+		n := strconv.Itoa(i)
+		mediaURL := params.Get("MediaUrl" + n)
+		if mediaURL == "" {
+			invalid = append(invalid, "MediaUrl"+n)
+			continue
+		}
+
+		message.Media = append(message.Media, telephony.Media{
+			URL:         mediaURL,
+			ContentType: params.Get("MediaContentType" + n),
+		})
+	}
+
+	err := message.Validate()
+	if len(invalid) > 0 {
+		validationErr, ok := err.(*telephony.ValidationError)
+		if !ok {
+			validationErr = &telephony.ValidationError{}
+		}
+		validationErr.Invalid = append(invalid, validationErr.Invalid...)
+		err = validationErr
+	}
+
+	return message, err
+}
@@ -0,0 +1,57 @@
+package twilio
+
+import (
+	"io/ioutil"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"answering-machine/internal/telephony"
+)
+
+func TestDecodeMessage(t *testing.T) {
+	t.Run("MMS with media", func(t *testing.T) {
+		body, err := ioutil.ReadFile("testdata/message-mms.form")
+		assert.NoError(t, err)
+		params, err := url.ParseQuery(string(body))
+		assert.NoError(t, err)
+
+		message, err := DecodeMessage(params)
+
+		assert.NoError(t, err)
+		assert.Equal(t, telephony.Message{
+			Provider:    "twilio",
+			AccountSid:  "AC25aa00521bfac6d667f13fec086072df",
+			MessageSid:  "MM0f9d3d4c1a2b3c4d5e6f708192a3b4c5",
+			From:        "+447700900123",
+			To:          "+441632960000",
+			Body:        "Here's the photo of the meter 📷",
+			NumMedia:    2,
+			FromCountry: "GB",
+			ToCountry:   "GB",
+			Media: []telephony.Media{
+				{
+					URL:         "https://api.twilio.com/2010-04-01/Accounts/AC25aa00521bfac6d667f13fec086072df/Messages/MM0f9d3d4c1a2b3c4d5e6f708192a3b4c5/Media/ME1a2b3c4d5e6f708192a3b4c5d6e7f80",
+					ContentType: "image/jpeg",
+				},
+				{
+					URL:         "https://api.twilio.com/2010-04-01/Accounts/AC25aa00521bfac6d667f13fec086072df/Messages/MM0f9d3d4c1a2b3c4d5e6f708192a3b4c5/Media/ME2b3c4d5e6f708192a3b4c5d6e7f8091",
+					ContentType: "text/vcard",
+				},
+			},
+		}, message)
+	})
+
+	t.Run("Names missing and invalid fields", func(t *testing.T) {
+		params := url.Values{
+			"MessageSid": {"MM123"},
+			"NumMedia":   {"1"},
+			"Media":      {"ignored"},
+		}
+
+		_, err := DecodeMessage(params)
+
+		assert.EqualError(t, err, "missing required fields: From; invalid fields: MediaUrl0")
+	})
+}
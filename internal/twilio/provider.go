@@ -0,0 +1,241 @@
+package twilio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"answering-machine/internal/telephony"
+)
+
+// Name identifies Twilio on stored events and in TELEPHONY_PROVIDER.
+const Name = "twilio"
+
+// Provider is the Twilio telephony.Provider. Requests are authenticated
+// with X-Twilio-Signature and calls are controlled with TwiML.
+type Provider struct {
+	accountSid string
+	authToken  string
+	httpClient telephony.HTTPClient
+}
+
+// NewProvider returns a Provider that validates requests with authToken,
+// and downloads recordings with HTTP basic auth as accountSid. Without an
+// accountSid the account of each recording is used.
+func NewProvider(accountSid, authToken string, httpClient telephony.HTTPClient) *Provider {
+	return &Provider{
+		accountSid: accountSid,
+		authToken:  authToken,
+		httpClient: httpClient,
+	}
+}
+
+// Name returns "twilio".
+func (provider *Provider) Name() string {
+	return Name
+}
+
+// ParseRecordingEvent validates and parses a recording status callback.
+func (provider *Provider) ParseRecordingEvent(request events.APIGatewayProxyRequest) (telephony.RecordingEvent, error) {
+	params, err := provider.params(request)
+	if err != nil {
+		return telephony.RecordingEvent{}, err
+	}
+
+	event, err := DecodeRecordingEvent(CallbackParams(request, params))
+	event.Provider = Name
+
+	return event, err
+}
+
+// ParseCallEvent validates and parses a voice request.
+func (provider *Provider) ParseCallEvent(request events.APIGatewayProxyRequest) (telephony.CallEvent, error) {
+	params, err := provider.params(request)
+	if err != nil {
+		return telephony.CallEvent{}, err
+	}
+
+	return DecodeCallEvent(params), nil
+}
+
+func (provider *Provider) params(request events.APIGatewayProxyRequest) (url.Values, error) {
+	params, err := FormParams(request)
+	if err != nil {
+		return nil, &telephony.ValidationError{Invalid: []string{"Body"}}
+	}
+
+	if !ValidateRequest(provider.authToken, request, params) {
+		return nil, telephony.ErrUnauthorized
+	}
+
+	return params, nil
+}
+
+// FetchRecording downloads the MP3 of a recording, Twilio serves each
+// format by adding its extension to RecordingUrl. Credentials are sent in
+// case the account enforces HTTP basic auth on media.
+func (provider *Provider) FetchRecording(ctx context.Context, event telephony.RecordingEvent) (io.ReadCloser, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, event.RecordingUrl+".mp3", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	provider.authenticate(request, event.AccountSid)
+
+	resp, err := provider.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return telephony.RecordingBody(resp)
+}
+
+// DeleteRecording removes a recording from Twilio through the REST API,
+// RecordingUrl being the recording's resource. A recording that has
+// already gone isn't an error.
+func (provider *Provider) DeleteRecording(ctx context.Context, event telephony.RecordingEvent) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodDelete, event.RecordingUrl, nil)
+	if err != nil {
+		return err
+	}
+
+	provider.authenticate(request, event.AccountSid)
+
+	resp, err := provider.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || (resp.StatusCode >= 200 && resp.StatusCode <= 299) {
+		return nil
+	}
+
+	return &telephony.StatusError{Method: http.MethodDelete, URL: event.RecordingUrl, StatusCode: resp.StatusCode}
+}
+
+// ParseMessage validates and parses an incoming message webhook.
+func (provider *Provider) ParseMessage(request events.APIGatewayProxyRequest) (telephony.Message, error) {
+	params, err := provider.params(request)
+	if err != nil {
+		return telephony.Message{}, err
+	}
+
+	return DecodeMessage(params)
+}
+
+// FetchMedia downloads an MMS attachment, with the same credentials as
+// recordings as accounts can enforce HTTP basic auth on all media.
+func (provider *Provider) FetchMedia(ctx context.Context, message telephony.Message, media telephony.Media) (io.ReadCloser, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, media.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	provider.authenticate(request, message.AccountSid)
+
+	resp, err := provider.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return telephony.MediaBody(resp, media.ContentType)
+}
+
+// authenticate adds HTTP basic auth to a REST API or media request, as
+// the configured account or else accountSid, the account of the
+// recording or message.
+func (provider *Provider) authenticate(request *http.Request, accountSid string) {
+	if provider.accountSid != "" {
+		accountSid = provider.accountSid
+	}
+	if accountSid != "" && provider.authToken != "" {
+		request.SetBasicAuth(accountSid, provider.authToken)
+	}
+}
+
+// Render responds with the TwiML for actions.
+func (provider *Provider) Render(actions ...telephony.Action) (events.APIGatewayProxyResponse, error) {
+	response, err := NewResponse(actions...)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 500,
+		}, err
+	}
+
+	return response.APIGatewayProxyResponse()
+}
+
+// NewResponse builds the TwiML document for actions.
+func NewResponse(actions ...telephony.Action) (Response, error) {
+	response := Response{}
+
+	for _, action := range actions {
+		v, err := verb(action)
+		if err != nil {
+			return Response{}, err
+		}
+
+		response.Verbs = append(response.Verbs, v)
+	}
+
+	return response, nil
+}
+
+func verb(action telephony.Action) (interface{}, error) {
+	switch action := action.(type) {
+	case telephony.Say:
+		return Say{
+			Voice:    action.Voice,
+			Language: action.Language,
+			Text:     action.Text,
+		}, nil
+	case telephony.Play:
+		return Play{URL: action.URL}, nil
+	case telephony.Record:
+		return Record{
+			Action:                        action.ActionURL,
+			Method:                        http.MethodPost,
+			MaxLength:                     action.MaxLength,
+			FinishOnKey:                   action.FinishOnKey,
+			PlayBeep:                      true,
+			RecordingStatusCallback:       action.CallbackURL,
+			RecordingStatusCallbackMethod: http.MethodPost,
+			RecordingStatusCallbackEvent:  strings.Join(RecordingStatusCallbackEvents, " "),
+		}, nil
+	case telephony.Dial:
+		return Dial{
+			Action:  action.ActionURL,
+			Method:  http.MethodPost,
+			Timeout: action.Timeout,
+			Number:  action.Number,
+		}, nil
+	case telephony.Gather:
+		gather := Gather{
+			Action:    action.ActionURL,
+			Method:    http.MethodPost,
+			NumDigits: action.NumDigits,
+			Timeout:   action.Timeout,
+		}
+		for _, prompt := range action.Prompt {
+			v, err := verb(prompt)
+			if err != nil {
+				return nil, err
+			}
+			gather.Verbs = append(gather.Verbs, v)
+		}
+
+		return gather, nil
+	case telephony.Reject:
+		return Reject{Reason: "busy"}, nil
+	case telephony.Hangup:
+		return Hangup{}, nil
+	default:
+		return nil, fmt.Errorf("twilio: unsupported action %T", action)
+	}
+}
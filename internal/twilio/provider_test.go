@@ -0,0 +1,269 @@
+package twilio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+
+	"answering-machine/internal/telephony"
+)
+
+type mockHTTPClient struct {
+	t           *testing.T
+	expectedURL string
+	statusCode  int
+}
+
+func (mock mockHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	assert.Equal(mock.t, mock.expectedURL, req.URL.String())
+
+	username, password, ok := req.BasicAuth()
+	assert.True(mock.t, ok)
+	assert.Equal(mock.t, "AC123", username)
+	assert.Equal(mock.t, "secret", password)
+
+	if mock.statusCode != 0 {
+		return &http.Response{
+			StatusCode: mock.statusCode,
+			Header:     http.Header{"Content-Type": {"text/html"}},
+			Body:       ioutil.NopCloser(bytes.NewBufferString("<html>Not Found</html>")),
+			Request:    req,
+		}, nil
+	}
+
+	return &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": {"audio/mpeg"}},
+		Body:       ioutil.NopCloser(bytes.NewBufferString("audio")),
+	}, nil
+}
+
+func newFixtureRequest(t *testing.T, authToken, fixture string, query map[string]string) events.APIGatewayProxyRequest {
+	body, err := ioutil.ReadFile("testdata/" + fixture)
+	assert.NoError(t, err)
+
+	params, err := url.ParseQuery(string(body))
+	assert.NoError(t, err)
+
+	request := events.APIGatewayProxyRequest{
+		Path:                  "/webhook",
+		Headers:               map[string]string{"Host": "example.com"},
+		QueryStringParameters: query,
+		Body:                  string(body),
+	}
+	request.Headers[SignatureHeader] = Signature(authToken, telephony.RequestURL(request), params)
+
+	return request
+}
+
+func TestProviderParseRecordingEvent(t *testing.T) {
+	provider := NewProvider("", "secret", nil)
+
+	t.Run("Completed recording", func(t *testing.T) {
+		request := newFixtureRequest(t, "secret", "recording-completed.form", map[string]string{
+			"Caller":       "+447700900123",
+			"To":           "+441632960000",
+			"Option":       "urgent",
+			"RecordingSid": "ignored",
+		})
+
+		event, err := provider.ParseRecordingEvent(request)
+
+		assert.NoError(t, err)
+		assert.Equal(t, telephony.RecordingEvent{
+			Provider:           "twilio",
+			AccountSid:         "AC25aa00521bfac6d667f13fec086072df",
+			CallSid:            "CA8dfedb55c129dd4d6bd1f59af9d11080",
+			RecordingSid:       "RE6b2b7b4f4d0cf2fe0f8d6b3a3b8f0c2a",
+			RecordingUrl:       "https://api.twilio.com/2010-04-01/Accounts/AC25aa00521bfac6d667f13fec086072df/Recordings/RE6b2b7b4f4d0cf2fe0f8d6b3a3b8f0c2a",
+			RecordingStatus:    "completed",
+			RecordingDuration:  14,
+			RecordingStartTime: "2026-10-19T10:15:02Z",
+			Option:             "urgent",
+			Caller:             "+447700900123",
+			To:                 "+441632960000",
+		}, event)
+	})
+
+	t.Run("Absent recording", func(t *testing.T) {
+		request := newFixtureRequest(t, "secret", "recording-absent.form", nil)
+
+		event, err := provider.ParseRecordingEvent(request)
+
+		assert.NoError(t, err)
+		assert.False(t, event.Completed())
+		assert.True(t, event.Final())
+	})
+
+	t.Run("Invalid signature", func(t *testing.T) {
+		request := newFixtureRequest(t, "other", "recording-completed.form", nil)
+
+		_, err := provider.ParseRecordingEvent(request)
+
+		assert.Equal(t, telephony.ErrUnauthorized, err)
+	})
+
+	t.Run("Names missing and invalid fields", func(t *testing.T) {
+		params := url.Values{
+			"RecordingSid":      {"RE123"},
+			"RecordingDuration": {"long"},
+		}
+
+		_, err := DecodeRecordingEvent(params)
+
+		assert.EqualError(t, err, "missing required fields: CallSid, RecordingUrl; invalid fields: RecordingDuration")
+	})
+}
+
+func TestProviderParseCallEvent(t *testing.T) {
+	provider := NewProvider("", "secret", nil)
+
+	event, err := provider.ParseCallEvent(newFixtureRequest(t, "secret", "voice.form", nil))
+
+	assert.NoError(t, err)
+	assert.Equal(t, telephony.CallEvent{
+		CallSid: "CA8dfedb55c129dd4d6bd1f59af9d11080",
+		From:    "+447700900123",
+		To:      "+441632960000",
+	}, event)
+}
+
+func TestProviderFetchRecording(t *testing.T) {
+	t.Run("Configured account", func(t *testing.T) {
+		provider := NewProvider("AC123", "secret", mockHTTPClient{
+			t:           t,
+			expectedURL: "https://api.twilio.com/recording/RE123.mp3",
+		})
+
+		body, err := provider.FetchRecording(context.Background(), telephony.RecordingEvent{
+			AccountSid:   "AC456",
+			RecordingUrl: "https://api.twilio.com/recording/RE123",
+		})
+		assert.NoError(t, err)
+
+		audio, err := ioutil.ReadAll(body)
+		assert.NoError(t, err)
+		assert.Equal(t, "audio", string(audio))
+	})
+
+	t.Run("Recording's account", func(t *testing.T) {
+		provider := NewProvider("", "secret", mockHTTPClient{
+			t:           t,
+			expectedURL: "https://api.twilio.com/recording/RE123.mp3",
+		})
+
+		_, err := provider.FetchRecording(context.Background(), telephony.RecordingEvent{
+			AccountSid:   "AC123",
+			RecordingUrl: "https://api.twilio.com/recording/RE123",
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Not ready", func(t *testing.T) {
+		provider := NewProvider("AC123", "secret", mockHTTPClient{
+			t:           t,
+			expectedURL: "https://api.twilio.com/recording/RE123.mp3",
+			statusCode:  404,
+		})
+
+		_, err := provider.FetchRecording(context.Background(), telephony.RecordingEvent{
+			RecordingUrl: "https://api.twilio.com/recording/RE123",
+		})
+
+		var statusErr *telephony.StatusError
+		assert.True(t, errors.As(err, &statusErr))
+		assert.Equal(t, 404, statusErr.StatusCode)
+		assert.True(t, statusErr.Temporary())
+	})
+}
+
+func TestProviderFetchMedia(t *testing.T) {
+	provider := NewProvider("", "secret", mockHTTPClient{
+		t:           t,
+		expectedURL: "https://api.twilio.com/Media/ME1",
+	})
+	message := telephony.Message{AccountSid: "AC123"}
+
+	t.Run("Expected type", func(t *testing.T) {
+		body, err := provider.FetchMedia(context.Background(), message, telephony.Media{
+			URL:         "https://api.twilio.com/Media/ME1",
+			ContentType: "audio/mpeg",
+		})
+		assert.NoError(t, err)
+
+		content, err := ioutil.ReadAll(body)
+		assert.NoError(t, err)
+		assert.Equal(t, "audio", string(content))
+	})
+
+	t.Run("Other type", func(t *testing.T) {
+		_, err := provider.FetchMedia(context.Background(), message, telephony.Media{
+			URL:         "https://api.twilio.com/Media/ME1",
+			ContentType: "image/jpeg",
+		})
+
+		assert.True(t, errors.Is(err, telephony.ErrUnexpectedMedia))
+	})
+}
+
+func TestProviderDeleteRecording(t *testing.T) {
+	event := telephony.RecordingEvent{
+		RecordingUrl: "https://api.twilio.com/2010-04-01/Accounts/AC123/Recordings/RE123",
+	}
+
+	for statusCode, ok := range map[int]bool{204: true, 404: true, 500: false} {
+		provider := NewProvider("AC123", "secret", mockHTTPClient{
+			t:           t,
+			expectedURL: event.RecordingUrl,
+			statusCode:  statusCode,
+		})
+
+		err := provider.DeleteRecording(context.Background(), event)
+		assert.Equal(t, ok, err == nil, statusCode)
+	}
+}
+
+func TestProviderRender(t *testing.T) {
+	response, err := NewProvider("", "secret", nil).Render(
+		telephony.Say{Text: "Leave a message", Language: "en-GB"},
+		telephony.Record{
+			MaxLength:   120,
+			FinishOnKey: "#",
+			ActionURL:   "https://example.com/voice?Step=recorded",
+			CallbackURL: "https://example.com/webhook",
+		},
+		telephony.Hangup{},
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "text/xml", response.Headers["Content-Type"])
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<Response><Say language="en-GB">Leave a message</Say>`+
+		`<Record action="https://example.com/voice?Step=recorded" method="POST" maxLength="120" finishOnKey="#" playBeep="true" recordingStatusCallback="https://example.com/webhook" recordingStatusCallbackMethod="POST" recordingStatusCallbackEvent="in-progress completed absent"></Record>`+
+		`<Hangup></Hangup></Response>`, response.Body)
+}
+
+func TestNewResponseGather(t *testing.T) {
+	response, err := NewResponse(telephony.Gather{
+		Prompt: []telephony.Action{
+			telephony.Play{URL: "https://example.com/greeting.mp3"},
+			telephony.Say{Text: "Press 1 if this is urgent"},
+		},
+		NumDigits: 1,
+		Timeout:   5,
+		ActionURL: "https://example.com/voice?Step=menu",
+	})
+	assert.NoError(t, err)
+
+	body, err := response.Marshal()
+	assert.NoError(t, err)
+	assert.Contains(t, body, `<Gather action="https://example.com/voice?Step=menu" method="POST" numDigits="1" timeout="5">`+
+		`<Play>https://example.com/greeting.mp3</Play><Say>Press 1 if this is urgent</Say></Gather>`)
+}
@@ -0,0 +1,22 @@
+package twilio
+
+import (
+	"encoding/base64"
+	"net/url"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// FormParams parses the form encoded body of a request.
+func FormParams(request events.APIGatewayProxyRequest) (url.Values, error) {
+	body := request.Body
+	if request.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return nil, err
+		}
+		body = string(decoded)
+	}
+
+	return url.ParseQuery(body)
+}
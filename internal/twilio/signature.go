@@ -0,0 +1,64 @@
+// Package twilio holds the parts of the Twilio integration that are shared
+// between the Lambda handlers.
+package twilio
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"answering-machine/internal/telephony"
+)
+
+// SignatureHeader is the header Twilio signs every webhook request with.
+const SignatureHeader = "X-Twilio-Signature"
+
+// Signature computes the X-Twilio-Signature Twilio sends for a POST to
+// requestURL with the given form parameters.
+// https://www.twilio.com/docs/usage/security#validating-requests
+func Signature(authToken, requestURL string, params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(requestURL)
+	for _, k := range keys {
+		values := append([]string(nil), params[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			b.WriteString(k)
+			b.WriteString(v)
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(b.String()))
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateSignature reports whether signature matches the one Twilio would
+// have sent. An empty auth token never validates.
+func ValidateSignature(authToken, signature, requestURL string, params url.Values) bool {
+	if authToken == "" || signature == "" {
+		return false
+	}
+
+	expected := Signature(authToken, requestURL, params)
+
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// ValidateRequest checks the X-Twilio-Signature of an API Gateway request
+// against its form parameters.
+func ValidateRequest(authToken string, request events.APIGatewayProxyRequest, params url.Values) bool {
+	return ValidateSignature(authToken, telephony.Header(request, SignatureHeader), telephony.RequestURL(request), params)
+}
@@ -0,0 +1,42 @@
+package twilio
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignature(t *testing.T) {
+	// Example from https://www.twilio.com/docs/usage/security
+	authToken := "12345"
+	requestURL := "https://mycompany.com/myapp.php?foo=1&bar=2"
+	params := url.Values{
+		"CallSid": {"CA1234567890ABCDE"},
+		"Caller":  {"+12349013030"},
+		"Digits":  {"1234"},
+		"From":    {"+12349013030"},
+		"To":      {"+18005551212"},
+	}
+
+	t.Run("Matches Twilio example", func(t *testing.T) {
+		assert.Equal(t, "0/KCTR6DLpKmkAf8muzZqo1nDgQ=", Signature(authToken, requestURL, params))
+	})
+
+	t.Run("Validates", func(t *testing.T) {
+		assert.True(t, ValidateSignature(authToken, "0/KCTR6DLpKmkAf8muzZqo1nDgQ=", requestURL, params))
+	})
+
+	t.Run("Rejects tampered params", func(t *testing.T) {
+		tampered := url.Values{"Digits": {"9999"}}
+		assert.False(t, ValidateSignature(authToken, "0/KCTR6DLpKmkAf8muzZqo1nDgQ=", requestURL, tampered))
+	})
+
+	t.Run("Rejects missing signature", func(t *testing.T) {
+		assert.False(t, ValidateSignature(authToken, "", requestURL, params))
+	})
+
+	t.Run("Rejects empty auth token", func(t *testing.T) {
+		assert.False(t, ValidateSignature("", Signature("", requestURL, params), requestURL, params))
+	})
+}
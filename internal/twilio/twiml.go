@@ -0,0 +1,104 @@
+package twilio
+
+import (
+	"encoding/xml"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Response is a TwiML document, each verb is rendered in order.
+// https://www.twilio.com/docs/voice/twiml
+type Response struct {
+	XMLName xml.Name `xml:"Response"`
+	Verbs   []interface{}
+}
+
+// Say reads Text out with text-to-speech.
+type Say struct {
+	XMLName  xml.Name `xml:"Say"`
+	Voice    string   `xml:"voice,attr,omitempty"`
+	Language string   `xml:"language,attr,omitempty"`
+	Text     string   `xml:",chardata"`
+}
+
+// Play plays the audio file at URL.
+type Play struct {
+	XMLName xml.Name `xml:"Play"`
+	URL     string   `xml:",chardata"`
+}
+
+// Record records the caller and posts the result to RecordingStatusCallback.
+type Record struct {
+	XMLName                       xml.Name `xml:"Record"`
+	Action                        string   `xml:"action,attr,omitempty"`
+	Method                        string   `xml:"method,attr,omitempty"`
+	MaxLength                     int      `xml:"maxLength,attr,omitempty"`
+	FinishOnKey                   string   `xml:"finishOnKey,attr,omitempty"`
+	PlayBeep                      bool     `xml:"playBeep,attr"`
+	RecordingStatusCallback       string   `xml:"recordingStatusCallback,attr,omitempty"`
+	RecordingStatusCallbackMethod string   `xml:"recordingStatusCallbackMethod,attr,omitempty"`
+	RecordingStatusCallbackEvent  string   `xml:"recordingStatusCallbackEvent,attr,omitempty"`
+}
+
+// Dial connects the caller to Number, then requests Action with the
+// DialCallStatus once the dialled call ends or isn't answered in Timeout
+// seconds.
+type Dial struct {
+	XMLName xml.Name `xml:"Dial"`
+	Action  string   `xml:"action,attr,omitempty"`
+	Method  string   `xml:"method,attr,omitempty"`
+	Timeout int      `xml:"timeout,attr,omitempty"`
+	Number  string   `xml:",chardata"`
+}
+
+// Gather plays its nested Say or Play verbs while collecting up to
+// NumDigits key presses, then requests Action with Digits. Without input
+// the document carries on after it.
+type Gather struct {
+	XMLName   xml.Name `xml:"Gather"`
+	Action    string   `xml:"action,attr,omitempty"`
+	Method    string   `xml:"method,attr,omitempty"`
+	NumDigits int      `xml:"numDigits,attr,omitempty"`
+	Timeout   int      `xml:"timeout,attr,omitempty"`
+	Verbs     []interface{}
+}
+
+// Reject refuses the call without answering it, Reason is "busy" or
+// "rejected".
+type Reject struct {
+	XMLName xml.Name `xml:"Reject"`
+	Reason  string   `xml:"reason,attr,omitempty"`
+}
+
+// Hangup ends the call.
+type Hangup struct {
+	XMLName xml.Name `xml:"Hangup"`
+}
+
+// Marshal renders the document with its XML declaration.
+func (r Response) Marshal() (string, error) {
+	body, err := xml.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+
+	return xml.Header + string(body), nil
+}
+
+// APIGatewayProxyResponse renders the document as a Lambda proxy response.
+func (r Response) APIGatewayProxyResponse() (events.APIGatewayProxyResponse, error) {
+	body, err := r.Marshal()
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 500,
+		}, err
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: 200,
+		Headers: map[string]string{
+			"Content-Type": "text/xml",
+		},
+		Body: body,
+	}, nil
+}
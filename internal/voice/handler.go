@@ -0,0 +1,413 @@
+// Package voice answers inbound calls, forwarding them or taking a
+// message.
+package voice
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+
+	"answering-machine/internal/callers"
+	"answering-machine/internal/carrier"
+	"answering-machine/internal/greetings"
+	"answering-machine/internal/mailbox"
+	"answering-machine/internal/schedule"
+	"answering-machine/internal/telephony"
+)
+
+// Steps are passed back on the action URLs of verbs that end the
+// document, so the handler knows where in the call it is.
+const (
+	// stepRecorded follows <Record>, there is nothing left but to hang up.
+	stepRecorded = "recorded"
+	// stepDialled follows <Dial>, unanswered calls go to voicemail.
+	stepDialled = "dialled"
+	// stepMenu follows <Gather> when the caller pressed a key.
+	stepMenu = "menu"
+)
+
+// Deps handles call-control requests on the voice route.
+type Deps struct {
+	provider   telephony.Provider
+	greetings  map[schedule.Slot]greeting
+	recordings recordingStore
+	forwarding forwarding
+	menu       menu
+	schedule   *schedule.Schedule
+	clock      schedule.Clock
+	blocklist  callerList
+	allowlist  callerList
+	mailboxes  mailboxStore
+}
+
+type mailboxStore interface {
+	Get(ctx context.Context, number string) (mailbox.Mailbox, error)
+}
+
+// recordingStore presigns uploaded greetings, it is nil when there is no
+// greetings bucket.
+type recordingStore interface {
+	URL(ctx context.Context, number string, slots ...schedule.Slot) (string, error)
+}
+
+type forwarding struct {
+	number  string
+	timeout int
+	// whenOpen forwards every caller during opening hours, not just those
+	// on the allowlist.
+	whenOpen bool
+}
+
+// menu lets callers tag their message by pressing a key before the beep.
+// It is off without options.
+type menu struct {
+	prompt  string
+	timeout int
+	// options maps each key to the option stored with the recording.
+	options map[string]string
+}
+
+type callerList interface {
+	Contains(ctx context.Context, number string) (bool, error)
+}
+
+type greeting struct {
+	text        string
+	url         string
+	voice       string
+	language    string
+	maxLength   int
+	finishOnKey string
+}
+
+// Handler responds to a call with the actions for its next step.
+func (deps *Deps) Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	event, err := deps.provider.ParseCallEvent(request)
+	if err == telephony.ErrUnauthorized {
+		log.Printf("rejecting unauthenticated %s request", deps.provider.Name())
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: 403,
+		}, nil
+	}
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 400,
+		}, err
+	}
+
+	slot := deps.schedule.Slot(deps.clock.Now())
+
+	switch request.QueryStringParameters["Step"] {
+	case stepRecorded:
+		return deps.provider.Render(telephony.Hangup{})
+	case stepDialled:
+		if event.Answered {
+			return deps.provider.Render(telephony.Hangup{})
+		}
+
+		return deps.provider.Render(deps.voicemail(request, event, deps.greeting(ctx, slot, event.To))...)
+	case stepMenu:
+		greeting := deps.greeting(ctx, slot, event.To)
+
+		return deps.provider.Render(deps.record(request, event, greeting, deps.menu.options[event.Digits]), telephony.Hangup{})
+	}
+
+	caller := event.From
+
+	// Fail open, a blocklist outage shouldn't stop anyone leaving a message.
+	blocked, err := deps.blocklist.Contains(ctx, caller)
+	if err != nil {
+		log.Printf("checking blocklist for %s: %s", caller, err)
+	}
+	if blocked {
+		log.Printf("rejecting blocked caller %s", caller)
+
+		return deps.provider.Render(telephony.Reject{})
+	}
+
+	if deps.forwarding.number != "" {
+		if slot == schedule.SlotOpen && deps.forwarding.whenOpen {
+			return deps.provider.Render(deps.dial(request))
+		}
+
+		allowed, err := deps.allowlist.Contains(ctx, caller)
+		if err != nil {
+			log.Printf("checking allowlist for %s: %s", caller, err)
+		}
+		if allowed {
+			return deps.provider.Render(deps.dial(request))
+		}
+	}
+
+	return deps.provider.Render(deps.voicemail(request, event, deps.greeting(ctx, slot, event.To))...)
+}
+
+// greeting picks the greeting for the schedule slot, with the spoken
+// greeting and language of the dialled number's mailbox. A recording
+// uploaded for the number, or for every number, is played instead.
+func (deps *Deps) greeting(ctx context.Context, slot schedule.Slot, to string) greeting {
+	greeting, ok := deps.greetings[slot]
+	if !ok {
+		greeting = deps.greetings[schedule.SlotOpen]
+	}
+
+	mailbox, err := deps.mailboxes.Get(ctx, to)
+	if err != nil {
+		log.Printf("getting mailbox for %s: %s", to, err)
+	}
+
+	if slot == schedule.SlotOpen && mailbox.Greeting != "" {
+		greeting = greeting.override(mailbox.Greeting, "")
+	}
+	if mailbox.Language != "" {
+		greeting.language = mailbox.Language
+	}
+
+	numbers := []string{to}
+	if slot != schedule.SlotOpen || mailbox.Greeting == "" {
+		numbers = append(numbers, greetings.DefaultMailbox)
+	}
+	if url, ok := deps.recording(ctx, slot, numbers...); ok {
+		greeting.url = url
+	}
+
+	return greeting
+}
+
+// recording presigns the first recorded greeting for the slot uploaded for
+// numbers. Holidays fall back to the closed recording, as they do for the
+// spoken greeting. Without one the spoken greeting is used.
+func (deps *Deps) recording(ctx context.Context, slot schedule.Slot, numbers ...string) (string, bool) {
+	if deps.recordings == nil {
+		return "", false
+	}
+
+	slots := []schedule.Slot{slot}
+	if slot == schedule.SlotHoliday {
+		slots = append(slots, schedule.SlotClosed)
+	}
+
+	for _, number := range numbers {
+		url, err := deps.recordings.URL(ctx, number, slots...)
+		if err == nil {
+			return url, true
+		}
+		if err != greetings.ErrNotFound {
+			log.Printf("presigning greeting for %s: %s", number, err)
+
+			return "", false
+		}
+	}
+
+	return "", false
+}
+
+// dial rings the forwarding number, coming back to voicemail if nobody
+// answers.
+func (deps *Deps) dial(request events.APIGatewayProxyRequest) telephony.Action {
+	return telephony.Dial{
+		Number:    deps.forwarding.number,
+		Timeout:   deps.forwarding.timeout,
+		ActionURL: telephony.RouteURL(request, "voice", url.Values{"Step": {stepDialled}}),
+	}
+}
+
+// voicemail plays the greeting and records a message. With a menu the
+// greeting is played while waiting for a key, callers who don't press one
+// are recorded without an option.
+func (deps *Deps) voicemail(request events.APIGatewayProxyRequest, event telephony.CallEvent, greeting greeting) []telephony.Action {
+	var actions []telephony.Action
+
+	if len(deps.menu.options) > 0 {
+		actions = append(actions, telephony.Gather{
+			Prompt: []telephony.Action{
+				greeting.action(),
+				telephony.Say{
+					Voice:    greeting.voice,
+					Language: greeting.language,
+					Text:     deps.menu.prompt,
+				},
+			},
+			NumDigits: 1,
+			Timeout:   deps.menu.timeout,
+			ActionURL: telephony.RouteURL(request, "voice", url.Values{"Step": {stepMenu}}),
+		})
+	} else {
+		actions = append(actions, greeting.action())
+	}
+
+	return append(actions, deps.record(request, event, greeting, ""), telephony.Hangup{})
+}
+
+// record takes the message. The recording callback carries the caller
+// details, because not every provider posts them with the recording, and
+// the menu option.
+func (deps *Deps) record(request events.APIGatewayProxyRequest, event telephony.CallEvent, greeting greeting, option string) telephony.Action {
+	callback := url.Values{}
+	for k, v := range map[string]string{"Caller": event.From, "From": event.From, "To": event.To, "Option": option} {
+		if v != "" {
+			callback.Set(k, v)
+		}
+	}
+
+	return telephony.Record{
+		MaxLength:   greeting.maxLength,
+		FinishOnKey: greeting.finishOnKey,
+		ActionURL:   telephony.RouteURL(request, "voice", url.Values{"Step": {stepRecorded}}),
+		CallbackURL: telephony.RouteURL(request, "webhook", callback),
+	}
+}
+
+func (greeting greeting) action() telephony.Action {
+	if greeting.url != "" {
+		return telephony.Play{URL: greeting.url}
+	}
+
+	return telephony.Say{
+		Voice:    greeting.voice,
+		Language: greeting.language,
+		Text:     greeting.text,
+	}
+}
+
+// newGreetings reads the greeting for each schedule slot. Closed and
+// holiday greetings only override the text or recording, and holidays fall
+// back to the closed greeting.
+func newGreetings() map[schedule.Slot]greeting {
+	open := greeting{
+		text:        os.Getenv("VOICE_GREETING"),
+		url:         os.Getenv("VOICE_GREETING_URL"),
+		voice:       os.Getenv("VOICE_NAME"),
+		language:    os.Getenv("VOICE_LANGUAGE"),
+		maxLength:   120,
+		finishOnKey: os.Getenv("VOICE_FINISH_ON_KEY"),
+	}
+
+	if open.text == "" {
+		open.text = "Please leave a message after the beep."
+	}
+
+	if maxLength, err := strconv.Atoi(os.Getenv("VOICE_MAX_LENGTH")); err == nil {
+		open.maxLength = maxLength
+	}
+
+	if open.finishOnKey == "" {
+		open.finishOnKey = "#"
+	}
+
+	closed := open.override(os.Getenv("VOICE_GREETING_CLOSED"), os.Getenv("VOICE_GREETING_URL_CLOSED"))
+	holiday := closed.override(os.Getenv("VOICE_GREETING_HOLIDAY"), os.Getenv("VOICE_GREETING_URL_HOLIDAY"))
+
+	return map[schedule.Slot]greeting{
+		schedule.SlotOpen:    open,
+		schedule.SlotClosed:  closed,
+		schedule.SlotHoliday: holiday,
+	}
+}
+
+func (greeting greeting) override(text, url string) greeting {
+	if text != "" || url != "" {
+		greeting.text = text
+		greeting.url = url
+	}
+
+	return greeting
+}
+
+// newMenu reads the menu, IVR_OPTIONS is like "1=urgent,2=billing".
+func newMenu() menu {
+	menu := menu{
+		prompt:  os.Getenv("IVR_PROMPT"),
+		timeout: 5,
+		options: map[string]string{},
+	}
+
+	for _, option := range strings.Split(os.Getenv("IVR_OPTIONS"), ",") {
+		parts := strings.SplitN(option, "=", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) != "" && strings.TrimSpace(parts[1]) != "" {
+			menu.options[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+
+	if timeout, err := strconv.Atoi(os.Getenv("IVR_TIMEOUT")); err == nil {
+		menu.timeout = timeout
+	}
+
+	if menu.prompt == "" && len(menu.options) > 0 {
+		keys := make([]string, 0, len(menu.options))
+		for key := range menu.options {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		choices := make([]string, len(keys))
+		for i, key := range keys {
+			choices[i] = fmt.Sprintf("press %s for %s", key, menu.options[key])
+		}
+		menu.prompt = "Please " + strings.Join(choices, ", ") + ", otherwise stay on the line."
+	}
+
+	return menu
+}
+
+func newForwarding() forwarding {
+	forwarding := forwarding{
+		number:   os.Getenv("FORWARDING_NUMBER"),
+		timeout:  20,
+		whenOpen: os.Getenv("FORWARD_WHEN_OPEN") == "true",
+	}
+
+	if timeout, err := strconv.Atoi(os.Getenv("FORWARDING_TIMEOUT")); err == nil {
+		forwarding.timeout = timeout
+	}
+
+	return forwarding
+}
+
+// NewFromEnv configures the handler from the Lambda environment, looking
+// callers and mailboxes up with dynamodb. Recorded greetings are read from
+// GREETINGS_BUCKET with s3, which may be nil to only use spoken greetings.
+func NewFromEnv(dynamodb dynamodbiface.DynamoDBAPI, s3 s3iface.S3API, httpClient telephony.HTTPClient) (*Deps, error) {
+	openingHours, err := schedule.Parse(
+		os.Getenv("SCHEDULE_TIMEZONE"),
+		os.Getenv("SCHEDULE_HOURS"),
+		os.Getenv("SCHEDULE_HOLIDAYS"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := carrier.FromEnv(httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	var recordings recordingStore
+	if bucket := os.Getenv("GREETINGS_BUCKET"); bucket != "" && s3 != nil {
+		recordings = greetings.NewStore(s3, bucket, 10*time.Minute)
+	}
+
+	return &Deps{
+		provider:   provider,
+		greetings:  newGreetings(),
+		recordings: recordings,
+		forwarding: newForwarding(),
+		menu:       newMenu(),
+		schedule:   openingHours,
+		clock:      schedule.SystemClock{},
+		blocklist:  callers.NewList(dynamodb, os.Getenv("BLOCKLIST_TABLE")),
+		allowlist:  callers.NewList(dynamodb, os.Getenv("ALLOWLIST_TABLE")),
+		mailboxes:  mailbox.NewStore(dynamodb, os.Getenv("MAILBOX_TABLE"), mailbox.Mailbox{}),
+	}, nil
+}
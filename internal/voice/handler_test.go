@@ -0,0 +1,357 @@
+package voice
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+
+	"answering-machine/internal/greetings"
+	"answering-machine/internal/mailbox"
+	"answering-machine/internal/schedule"
+	"answering-machine/internal/twilio"
+)
+
+type mockCallerList map[string]bool
+
+func (mock mockCallerList) Contains(ctx context.Context, number string) (bool, error) {
+	return mock[number], nil
+}
+
+type mockMailboxStore map[string]mailbox.Mailbox
+
+func (mock mockMailboxStore) Get(ctx context.Context, number string) (mailbox.Mailbox, error) {
+	return mock[number], nil
+}
+
+// mockRecordingStore is keyed on number then slot.
+type mockRecordingStore map[string]map[schedule.Slot]string
+
+func (mock mockRecordingStore) URL(ctx context.Context, number string, slots ...schedule.Slot) (string, error) {
+	for _, slot := range slots {
+		if url, ok := mock[number][slot]; ok {
+			return url, nil
+		}
+	}
+
+	return "", greetings.ErrNotFound
+}
+
+type failingRecordingStore struct{}
+
+func (failingRecordingStore) URL(ctx context.Context, number string, slots ...schedule.Slot) (string, error) {
+	return "", errors.New("no credentials")
+}
+
+func TestLambdaHandler(t *testing.T) {
+	authToken := "secret"
+
+	params := url.Values{
+		"CallSid": {"CA123"},
+		"Caller":  {"+447700900123"},
+		"From":    {"+447700900123"},
+		"To":      {"+447700900999"},
+	}
+
+	newRequestWithParams := func(query map[string]string, requestURL string, params url.Values) events.APIGatewayProxyRequest {
+		return events.APIGatewayProxyRequest{
+			Path: "/voice",
+			Headers: map[string]string{
+				"Host":                 "example.com",
+				twilio.SignatureHeader: twilio.Signature(authToken, requestURL, params),
+			},
+			QueryStringParameters: query,
+			RequestContext: events.APIGatewayProxyRequestContext{
+				Stage: "live",
+			},
+			Body: params.Encode(),
+		}
+	}
+
+	newRequest := func(query map[string]string, requestURL string) events.APIGatewayProxyRequest {
+		return newRequestWithParams(query, requestURL, params)
+	}
+
+	openingHours, err := schedule.Parse("Europe/London", "mon-fri 09:00-17:00", "2026-12-25")
+	assert.NoError(t, err)
+
+	london, err := time.LoadLocation("Europe/London")
+	assert.NoError(t, err)
+
+	openClock := schedule.FixedClock(time.Date(2026, 10, 19, 10, 0, 0, 0, london))
+	closedClock := schedule.FixedClock(time.Date(2026, 10, 19, 20, 0, 0, 0, london))
+	holidayClock := schedule.FixedClock(time.Date(2026, 12, 25, 10, 0, 0, 0, london))
+
+	open := greeting{
+		text:        "Leave a message",
+		voice:       "alice",
+		language:    "en-GB",
+		maxLength:   60,
+		finishOnKey: "#",
+	}
+
+	deps := Deps{
+		provider: twilio.NewProvider("", authToken, nil),
+		greetings: map[schedule.Slot]greeting{
+			schedule.SlotOpen:    open,
+			schedule.SlotClosed:  open.override("We're closed, leave a message", ""),
+			schedule.SlotHoliday: open.override("Merry Christmas, leave a message", ""),
+		},
+		forwarding: forwarding{
+			number:  "+447700900555",
+			timeout: 15,
+		},
+		schedule:  openingHours,
+		clock:     openClock,
+		blocklist: mockCallerList{},
+		allowlist: mockCallerList{},
+		mailboxes: mockMailboxStore{},
+	}
+
+	t.Run("Greets and records", func(t *testing.T) {
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequest(nil, "https://example.com/live/voice"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, "text/xml", resp.Headers["Content-Type"])
+		assert.Contains(t, resp.Body, `<Say voice="alice" language="en-GB">Leave a message</Say>`)
+		assert.Contains(t, resp.Body, `maxLength="60"`)
+		assert.Contains(t, resp.Body, `finishOnKey="#"`)
+		assert.Contains(t, resp.Body, `recordingStatusCallback="https://example.com/live/webhook?Caller=%2B447700900123&amp;From=%2B447700900123&amp;To=%2B447700900999"`)
+		assert.Contains(t, resp.Body, `action="https://example.com/live/voice?Step=recorded"`)
+	})
+
+	t.Run("Hangs up after recording", func(t *testing.T) {
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequest(
+			map[string]string{"Step": stepRecorded},
+			"https://example.com/live/voice?Step=recorded",
+		))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Contains(t, resp.Body, "<Response><Hangup></Hangup></Response>")
+	})
+
+	t.Run("Plays a recorded greeting", func(t *testing.T) {
+		deps := deps
+		deps.greetings = map[schedule.Slot]greeting{
+			schedule.SlotOpen: open.override("", "https://example.com/greeting.mp3"),
+		}
+
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequest(nil, "https://example.com/live/voice"))
+
+		assert.NoError(t, err)
+		assert.Contains(t, resp.Body, "<Play>https://example.com/greeting.mp3</Play>")
+		assert.NotContains(t, resp.Body, "<Say")
+	})
+
+	t.Run("Plays an uploaded greeting", func(t *testing.T) {
+		deps := deps
+		deps.recordings = mockRecordingStore{
+			"+447700900999": {schedule.SlotOpen: "https://recordings.s3.amazonaws.com/greetings/open.mp3?X-Amz-Signature=abc&X-Amz-Expires=600"},
+			"default":       {schedule.SlotOpen: "https://recordings.s3.amazonaws.com/greetings/default.mp3"},
+		}
+
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequest(nil, "https://example.com/live/voice"))
+
+		assert.NoError(t, err)
+		assert.Contains(t, resp.Body, "<Play>https://recordings.s3.amazonaws.com/greetings/open.mp3?X-Amz-Signature=abc&amp;X-Amz-Expires=600</Play>")
+		assert.NotContains(t, resp.Body, "<Say")
+	})
+
+	t.Run("Holiday falls back to the closed upload and then the default", func(t *testing.T) {
+		deps := deps
+		deps.clock = holidayClock
+		deps.recordings = mockRecordingStore{
+			"default": {schedule.SlotClosed: "https://recordings.s3.amazonaws.com/greetings/closed.mp3"},
+		}
+
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequest(nil, "https://example.com/live/voice"))
+
+		assert.NoError(t, err)
+		assert.Contains(t, resp.Body, "<Play>https://recordings.s3.amazonaws.com/greetings/closed.mp3</Play>")
+	})
+
+	t.Run("Speaks the greeting without an upload", func(t *testing.T) {
+		deps := deps
+		deps.recordings = mockRecordingStore{}
+
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequest(nil, "https://example.com/live/voice"))
+
+		assert.NoError(t, err)
+		assert.Contains(t, resp.Body, `<Say voice="alice" language="en-GB">Leave a message</Say>`)
+
+		deps.recordings = failingRecordingStore{}
+
+		resp, err = deps.Handler(aws.BackgroundContext(), newRequest(nil, "https://example.com/live/voice"))
+
+		assert.NoError(t, err)
+		assert.Contains(t, resp.Body, `<Say voice="alice" language="en-GB">Leave a message</Say>`)
+	})
+
+	t.Run("Rejects blocked callers", func(t *testing.T) {
+		deps := deps
+		deps.blocklist = mockCallerList{"+447700900123": true}
+
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequest(nil, "https://example.com/live/voice"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Contains(t, resp.Body, `<Response><Reject reason="busy"></Reject></Response>`)
+	})
+
+	t.Run("Dials allowlisted callers", func(t *testing.T) {
+		deps := deps
+		deps.allowlist = mockCallerList{"+447700900123": true}
+
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequest(nil, "https://example.com/live/voice"))
+
+		assert.NoError(t, err)
+		assert.Contains(t, resp.Body, `<Dial action="https://example.com/live/voice?Step=dialled" method="POST" timeout="15">+447700900555</Dial>`)
+		assert.NotContains(t, resp.Body, "<Record")
+	})
+
+	t.Run("Falls back to voicemail when nobody answers", func(t *testing.T) {
+		dialParams := url.Values{}
+		for k, v := range params {
+			dialParams[k] = v
+		}
+		dialParams.Set("DialCallStatus", "no-answer")
+
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequestWithParams(
+			map[string]string{"Step": stepDialled},
+			"https://example.com/live/voice?Step=dialled",
+			dialParams,
+		))
+
+		assert.NoError(t, err)
+		assert.Contains(t, resp.Body, "<Say")
+		assert.Contains(t, resp.Body, "<Record")
+	})
+
+	t.Run("Hangs up after an answered call", func(t *testing.T) {
+		dialParams := url.Values{}
+		for k, v := range params {
+			dialParams[k] = v
+		}
+		dialParams.Set("DialCallStatus", "completed")
+
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequestWithParams(
+			map[string]string{"Step": stepDialled},
+			"https://example.com/live/voice?Step=dialled",
+			dialParams,
+		))
+
+		assert.NoError(t, err)
+		assert.Contains(t, resp.Body, "<Response><Hangup></Hangup></Response>")
+	})
+
+	menu := menu{
+		prompt:  "Press 1 if this is urgent",
+		timeout: 5,
+		options: map[string]string{"1": "urgent", "2": "billing"},
+	}
+
+	t.Run("Menu before recording", func(t *testing.T) {
+		deps := deps
+		deps.menu = menu
+
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequest(nil, "https://example.com/live/voice"))
+
+		assert.NoError(t, err)
+		assert.Contains(t, resp.Body, `<Response><Gather action="https://example.com/live/voice?Step=menu" method="POST" numDigits="1" timeout="5">`+
+			`<Say voice="alice" language="en-GB">Leave a message</Say>`+
+			`<Say voice="alice" language="en-GB">Press 1 if this is urgent</Say></Gather><Record`)
+		assert.Contains(t, resp.Body, `recordingStatusCallback="https://example.com/live/webhook?Caller=%2B447700900123&amp;From=%2B447700900123&amp;To=%2B447700900999"`)
+	})
+
+	t.Run("Records with the chosen option", func(t *testing.T) {
+		deps := deps
+		deps.menu = menu
+
+		menuParams := url.Values{}
+		for k, v := range params {
+			menuParams[k] = v
+		}
+		menuParams.Set("Digits", "1")
+
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequestWithParams(
+			map[string]string{"Step": stepMenu},
+			"https://example.com/live/voice?Step=menu",
+			menuParams,
+		))
+
+		assert.NoError(t, err)
+		assert.NotContains(t, resp.Body, "<Gather")
+		assert.NotContains(t, resp.Body, "<Say")
+		assert.Contains(t, resp.Body, `recordingStatusCallback="https://example.com/live/webhook?Caller=%2B447700900123&amp;From=%2B447700900123&amp;Option=urgent&amp;To=%2B447700900999"`)
+	})
+
+	t.Run("Closed greeting after hours", func(t *testing.T) {
+		deps := deps
+		deps.clock = closedClock
+
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequest(nil, "https://example.com/live/voice"))
+
+		assert.NoError(t, err)
+		assert.Contains(t, resp.Body, ">We&#39;re closed, leave a message</Say>")
+	})
+
+	t.Run("Holiday greeting", func(t *testing.T) {
+		deps := deps
+		deps.clock = holidayClock
+
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequest(nil, "https://example.com/live/voice"))
+
+		assert.NoError(t, err)
+		assert.Contains(t, resp.Body, ">Merry Christmas, leave a message</Say>")
+	})
+
+	t.Run("Forwards everyone during opening hours", func(t *testing.T) {
+		deps := deps
+		deps.forwarding.whenOpen = true
+
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequest(nil, "https://example.com/live/voice"))
+
+		assert.NoError(t, err)
+		assert.Contains(t, resp.Body, "<Dial")
+
+		deps.clock = closedClock
+
+		resp, err = deps.Handler(aws.BackgroundContext(), newRequest(nil, "https://example.com/live/voice"))
+
+		assert.NoError(t, err)
+		assert.NotContains(t, resp.Body, "<Dial")
+		assert.Contains(t, resp.Body, "<Record")
+	})
+
+	t.Run("Mailbox greeting and language", func(t *testing.T) {
+		deps := deps
+		deps.mailboxes = mockMailboxStore{
+			"+447700900999": {
+				Greeting: "Helo, gadewch neges",
+				Language: "cy-GB",
+			},
+		}
+
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequest(nil, "https://example.com/live/voice"))
+
+		assert.NoError(t, err)
+		assert.Contains(t, resp.Body, `<Say voice="alice" language="cy-GB">Helo, gadewch neges</Say>`)
+	})
+
+	t.Run("Invalid Signature", func(t *testing.T) {
+		request := newRequest(nil, "https://example.com/live/voice")
+		request.Headers[twilio.SignatureHeader] = "bogus"
+
+		resp, err := deps.Handler(aws.BackgroundContext(), request)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 403, resp.StatusCode)
+	})
+}
@@ -0,0 +1,159 @@
+package vonage
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"time"
+)
+
+var encoding = base64.RawURLEncoding
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+// claims are the JWT claims Vonage uses in either direction, signed
+// webhooks carry PayloadHash and API requests carry ApplicationID.
+type claims struct {
+	ApplicationID string `json:"application_id,omitempty"`
+	IssuedAt      int64  `json:"iat,omitempty"`
+	Expires       int64  `json:"exp,omitempty"`
+	ID            string `json:"jti,omitempty"`
+	PayloadHash   string `json:"payload_hash,omitempty"`
+}
+
+// maxTokenAge is how far a webhook token's iat can be from now, either
+// way, before the token is taken to be a replay.
+const maxTokenAge = 5 * time.Minute
+
+// verifyWebhook checks a signed webhook's HS256 token against the
+// signature secret, its payload_hash against body and that it was issued
+// within maxTokenAge of now.
+// https://developer.vonage.com/en/getting-started/concepts/webhooks#decoding-signed-webhooks
+func verifyWebhook(token, secret string, body []byte, now time.Time) error {
+	if secret == "" {
+		return errors.New("vonage: no signature secret")
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("vonage: malformed token")
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return err
+	}
+	if h.Alg != "HS256" {
+		return errors.New("vonage: unexpected token algorithm " + h.Alg)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	signature, err := encoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, mac.Sum(nil)) {
+		return errors.New("vonage: invalid token signature")
+	}
+
+	var c claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(body)
+	if c.PayloadHash == "" || !strings.EqualFold(c.PayloadHash, hex.EncodeToString(sum[:])) {
+		return errors.New("vonage: payload hash mismatch")
+	}
+
+	issued := time.Unix(c.IssuedAt, 0)
+	if c.IssuedAt == 0 || issued.Before(now.Add(-maxTokenAge)) || issued.After(now.Add(maxTokenAge)) {
+		return errors.New("vonage: token issued at " + issued.UTC().Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// applicationToken signs the RS256 token that authenticates API requests
+// as the application.
+// https://developer.vonage.com/en/getting-started/concepts/authentication#json-web-tokens
+func applicationToken(applicationID string, privateKey *rsa.PrivateKey, now time.Time) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	h, err := encodeSegment(header{Alg: "RS256", Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	c, err := encodeSegment(claims{
+		ApplicationID: applicationID,
+		IssuedAt:      now.Unix(),
+		Expires:       now.Add(15 * time.Minute).Unix(),
+		ID:            hex.EncodeToString(id),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	digest := sha256.Sum256([]byte(h + "." + c))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return h + "." + c + "." + encoding.EncodeToString(signature), nil
+}
+
+// ParsePrivateKey reads the PEM private key Vonage generates for an
+// application, which is PKCS#8.
+func ParsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("vonage: private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("vonage: private key is not RSA")
+	}
+
+	return rsaKey, nil
+}
+
+func encodeSegment(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return encoding.EncodeToString(data), nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := encoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,171 @@
+package vonage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"answering-machine/internal/telephony"
+)
+
+// NCCO is a Vonage call control object, a list of actions run in order.
+// https://developer.vonage.com/en/voice/voice-api/ncco-reference
+type NCCO []interface{}
+
+// Talk reads Text out with text-to-speech.
+type Talk struct {
+	Action   string `json:"action"`
+	Text     string `json:"text"`
+	Language string `json:"language,omitempty"`
+	BargeIn  bool   `json:"bargeIn,omitempty"`
+}
+
+// Stream plays the audio files at StreamURL.
+type Stream struct {
+	Action    string   `json:"action"`
+	StreamURL []string `json:"streamUrl"`
+}
+
+// Record records the caller and posts the result to EventURL. Setting
+// EndOnKey or EndOnSilence makes the NCCO wait for the recording to end.
+type Record struct {
+	Action       string   `json:"action"`
+	Format       string   `json:"format"`
+	EventURL     []string `json:"eventUrl"`
+	EventMethod  string   `json:"eventMethod"`
+	TimeOut      int      `json:"timeOut,omitempty"`
+	EndOnKey     string   `json:"endOnKey,omitempty"`
+	EndOnSilence int      `json:"endOnSilence,omitempty"`
+	BeepStart    bool     `json:"beepStart"`
+}
+
+// Connect rings Endpoint. Synchronous events let EventURL return the NCCO
+// to carry on with if the call isn't answered.
+type Connect struct {
+	Action      string     `json:"action"`
+	Timeout     int        `json:"timeout,omitempty"`
+	EventType   string     `json:"eventType"`
+	EventURL    []string   `json:"eventUrl"`
+	EventMethod string     `json:"eventMethod"`
+	Endpoint    []Endpoint `json:"endpoint"`
+}
+
+// Input collects key presses and posts them to EventURL, whose response
+// replaces the rest of the NCCO.
+type Input struct {
+	Action      string   `json:"action"`
+	Type        []string `json:"type"`
+	DTMF        DTMF     `json:"dtmf"`
+	EventURL    []string `json:"eventUrl"`
+	EventMethod string   `json:"eventMethod"`
+}
+
+// DTMF settings of an Input.
+type DTMF struct {
+	MaxDigits int `json:"maxDigits,omitempty"`
+	TimeOut   int `json:"timeOut,omitempty"`
+}
+
+// Endpoint is a phone number to connect to, without the leading "+".
+type Endpoint struct {
+	Type   string `json:"type"`
+	Number string `json:"number"`
+}
+
+// NewNCCO builds the NCCO for actions. Vonage can't refuse a call before
+// answering it, so Reject and Hangup just end the NCCO.
+func NewNCCO(actions ...telephony.Action) (NCCO, error) {
+	ncco := NCCO{}
+
+	for _, action := range actions {
+		switch action := action.(type) {
+		case telephony.Say:
+			ncco = append(ncco, Talk{
+				Action:   "talk",
+				Text:     action.Text,
+				Language: action.Language,
+			})
+		case telephony.Play:
+			ncco = append(ncco, Stream{
+				Action:    "stream",
+				StreamURL: []string{action.URL},
+			})
+		case telephony.Record:
+			record := Record{
+				Action:      "record",
+				Format:      "mp3",
+				EventURL:    []string{action.CallbackURL},
+				EventMethod: "POST",
+				TimeOut:     action.MaxLength,
+				EndOnKey:    action.FinishOnKey,
+				BeepStart:   true,
+			}
+			if record.EndOnKey == "" {
+				record.EndOnSilence = 3
+			}
+			ncco = append(ncco, record)
+		case telephony.Dial:
+			ncco = append(ncco, Connect{
+				Action:      "connect",
+				Timeout:     action.Timeout,
+				EventType:   "synchronous",
+				EventURL:    []string{action.ActionURL},
+				EventMethod: "POST",
+				Endpoint: []Endpoint{
+					{Type: "phone", Number: strings.TrimPrefix(action.Number, "+")},
+				},
+			})
+		case telephony.Gather:
+			prompt, err := NewNCCO(action.Prompt...)
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range prompt {
+				if talk, ok := p.(Talk); ok {
+					talk.BargeIn = true
+					p = talk
+				}
+				ncco = append(ncco, p)
+			}
+
+			// Nothing after an input runs, its event URL decides what's
+			// next whether or not the caller pressed anything.
+			return append(ncco, Input{
+				Action: "input",
+				Type:   []string{"dtmf"},
+				DTMF: DTMF{
+					MaxDigits: action.NumDigits,
+					TimeOut:   action.Timeout,
+				},
+				EventURL:    []string{action.ActionURL},
+				EventMethod: "POST",
+			}), nil
+		case telephony.Reject, telephony.Hangup:
+			return ncco, nil
+		default:
+			return nil, fmt.Errorf("vonage: unsupported action %T", action)
+		}
+	}
+
+	return ncco, nil
+}
+
+// APIGatewayProxyResponse renders the NCCO as a Lambda proxy response.
+func (ncco NCCO) APIGatewayProxyResponse() (events.APIGatewayProxyResponse, error) {
+	body, err := json.Marshal(ncco)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 500,
+		}, err
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: 200,
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+		Body: string(body),
+	}, nil
+}
@@ -0,0 +1,237 @@
+// Package vonage adapts the Vonage Voice API to the telephony pipeline.
+// Webhooks are JSON, signed with the account signature secret, and calls
+// are controlled with NCCOs. The application's answer URL must be set to
+// POST, Vonage calls it with GET by default.
+package vonage
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"answering-machine/internal/telephony"
+)
+
+// Name identifies Vonage on stored events and in TELEPHONY_PROVIDER.
+const Name = "vonage"
+
+// Provider is the Vonage telephony.Provider.
+type Provider struct {
+	signatureSecret string
+	applicationID   string
+	privateKey      *rsa.PrivateKey
+	httpClient      telephony.HTTPClient
+	now             func() time.Time
+}
+
+// NewProvider returns a Provider that verifies webhooks with
+// signatureSecret and downloads recordings as the application.
+func NewProvider(signatureSecret, applicationID string, privateKey *rsa.PrivateKey, httpClient telephony.HTTPClient) *Provider {
+	return &Provider{
+		signatureSecret: signatureSecret,
+		applicationID:   applicationID,
+		privateKey:      privateKey,
+		httpClient:      httpClient,
+		now:             time.Now,
+	}
+}
+
+// recordingHosts are the hosts Vonage serves recordings from. FetchRecording
+// authenticates as the application, so it won't send the token anywhere
+// else.
+var recordingHosts = map[string]bool{
+	"api.nexmo.com":  true,
+	"api.vonage.com": true,
+}
+
+// recordEvent is the body of a record action's event webhook.
+// https://developer.vonage.com/en/voice/voice-api/webhook-reference#record
+type recordEvent struct {
+	ConversationUUID string    `json:"conversation_uuid"`
+	RecordingUUID    string    `json:"recording_uuid"`
+	RecordingURL     string    `json:"recording_url"`
+	StartTime        time.Time `json:"start_time"`
+	EndTime          time.Time `json:"end_time"`
+}
+
+// callEvent is the body of the answer webhook, and of the connect
+// action's synchronous events.
+type callEvent struct {
+	UUID             string `json:"uuid"`
+	ConversationUUID string `json:"conversation_uuid"`
+	From             string `json:"from"`
+	To               string `json:"to"`
+	Status           string `json:"status"`
+	DTMF             struct {
+		Digits string `json:"digits"`
+	} `json:"dtmf"`
+}
+
+// Name returns "vonage".
+func (provider *Provider) Name() string {
+	return Name
+}
+
+// ParseRecordingEvent verifies and parses a record event. Vonage only
+// reports finished recordings, and doesn't include the call's numbers, so
+// those come from the query string the voice handler set, which Render
+// signed.
+func (provider *Provider) ParseRecordingEvent(request events.APIGatewayProxyRequest) (telephony.RecordingEvent, error) {
+	var body recordEvent
+	if err := provider.decode(request, &body); err != nil {
+		return telephony.RecordingEvent{}, err
+	}
+	params := telephony.Query(request)
+
+	event := telephony.RecordingEvent{
+		Provider:        Name,
+		CallSid:         body.ConversationUUID,
+		RecordingSid:    body.RecordingUUID,
+		RecordingUrl:    body.RecordingURL,
+		RecordingStatus: telephony.RecordingStatusCompleted,
+		From:            normalizeNumber(params.Get("From")),
+		To:              normalizeNumber(params.Get("To")),
+		Caller:          normalizeNumber(params.Get("Caller")),
+		Option:          params.Get("Option"),
+	}
+	if !body.StartTime.IsZero() {
+		event.RecordingStartTime = body.StartTime.UTC().Format(time.RFC3339)
+	}
+	if !body.StartTime.IsZero() && body.EndTime.After(body.StartTime) {
+		event.RecordingDuration = int(body.EndTime.Sub(body.StartTime).Seconds())
+	}
+
+	if err := event.Validate(); err != nil {
+		return event, err
+	}
+	if !isRecordingURL(event.RecordingUrl) {
+		return event, &telephony.ValidationError{Invalid: []string{"RecordingUrl"}}
+	}
+
+	return event, nil
+}
+
+// ParseCallEvent verifies and parses an answer webhook, connect event or
+// input event.
+// Connect events only come back for calls that weren't answered, but the
+// status is checked anyway.
+func (provider *Provider) ParseCallEvent(request events.APIGatewayProxyRequest) (telephony.CallEvent, error) {
+	var body callEvent
+	if err := provider.decode(request, &body); err != nil {
+		return telephony.CallEvent{}, err
+	}
+
+	callSid := body.ConversationUUID
+	if callSid == "" {
+		callSid = body.UUID
+	}
+
+	return telephony.CallEvent{
+		CallSid:  callSid,
+		From:     normalizeNumber(body.From),
+		To:       normalizeNumber(body.To),
+		Answered: body.Status == "answered" || body.Status == "completed",
+		Digits:   body.DTMF.Digits,
+	}, nil
+}
+
+func (provider *Provider) decode(request events.APIGatewayProxyRequest, out interface{}) error {
+	body := []byte(request.Body)
+	if request.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(request.Body)
+		if err != nil {
+			return &telephony.ValidationError{Invalid: []string{"Body"}}
+		}
+		body = decoded
+	}
+
+	token := strings.TrimPrefix(telephony.Header(request, "Authorization"), "Bearer ")
+	if err := verifyWebhook(token, provider.signatureSecret, body, provider.now()); err != nil {
+		return telephony.ErrUnauthorized
+	}
+
+	if err := telephony.VerifyQuery(telephony.Query(request), provider.signatureSecret); err != nil {
+		return telephony.ErrUnauthorized
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return &telephony.ValidationError{Invalid: []string{"Body"}}
+	}
+
+	return nil
+}
+
+// FetchRecording downloads a recording, which needs an application token.
+func (provider *Provider) FetchRecording(ctx context.Context, event telephony.RecordingEvent) (io.ReadCloser, error) {
+	if !isRecordingURL(event.RecordingUrl) {
+		return nil, fmt.Errorf("vonage: recording URL %q isn't on the Vonage API", event.RecordingUrl)
+	}
+
+	token, err := applicationToken(provider.applicationID, provider.privateKey, provider.now())
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, event.RecordingUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := provider.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return telephony.RecordingBody(resp)
+}
+
+// Render responds with the NCCO for actions, signing the query of each URL
+// Vonage will post events to.
+func (provider *Provider) Render(actions ...telephony.Action) (events.APIGatewayProxyResponse, error) {
+	actions, err := telephony.SignActions(actions, provider.signatureSecret)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 500,
+		}, err
+	}
+
+	ncco, err := NewNCCO(actions...)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 500,
+		}, err
+	}
+
+	return ncco.APIGatewayProxyResponse()
+}
+
+// isRecordingURL reports whether rawURL is an HTTPS URL on the Vonage API.
+func isRecordingURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+
+	return err == nil && u.Scheme == "https" && u.User == nil && recordingHosts[u.Host]
+}
+
+// normalizeNumber adds the "+" Vonage leaves off E.164 numbers.
+func normalizeNumber(number string) string {
+	if number == "" || strings.HasPrefix(number, "+") {
+		return number
+	}
+	for _, r := range number {
+		if r < '0' || r > '9' {
+			return number
+		}
+	}
+
+	return "+" + number
+}
@@ -0,0 +1,333 @@
+package vonage
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+
+	"answering-machine/internal/telephony"
+)
+
+// issuedAt is when the fixtures were signed.
+var issuedAt = time.Date(2026, 10, 19, 10, 15, 16, 0, time.UTC)
+
+// signWebhook signs body the way Vonage signs webhooks.
+func signWebhook(t *testing.T, secret string, body []byte) string {
+	sum := sha256.Sum256(body)
+
+	return signClaims(t, secret, claims{IssuedAt: issuedAt.Unix(), PayloadHash: hex.EncodeToString(sum[:])})
+}
+
+func signClaims(t *testing.T, secret string, payload claims) string {
+	h, err := encodeSegment(header{Alg: "HS256", Typ: "JWT"})
+	assert.NoError(t, err)
+	c, err := encodeSegment(payload)
+	assert.NoError(t, err)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(h + "." + c))
+
+	return h + "." + c + "." + encoding.EncodeToString(mac.Sum(nil))
+}
+
+// newFixtureRequest signs fixture and query with secret, as Vonage and
+// Render would.
+func newFixtureRequest(t *testing.T, secret, fixture string, query url.Values) events.APIGatewayProxyRequest {
+	body, err := ioutil.ReadFile("testdata/" + fixture)
+	assert.NoError(t, err)
+
+	signed, err := telephony.SignQuery("https://example.com/webhook?"+query.Encode(), secret)
+	assert.NoError(t, err)
+	u, err := url.Parse(signed)
+	assert.NoError(t, err)
+
+	return events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"Authorization": "Bearer " + signWebhook(t, secret, body),
+		},
+		MultiValueQueryStringParameters: u.Query(),
+		Body:                            string(body),
+	}
+}
+
+func newTestProvider(privateKey *rsa.PrivateKey, httpClient telephony.HTTPClient) *Provider {
+	provider := NewProvider("secret", "app", privateKey, httpClient)
+	provider.now = func() time.Time { return issuedAt.Add(time.Second) }
+
+	return provider
+}
+
+func TestProviderParseRecordingEvent(t *testing.T) {
+	provider := newTestProvider(nil, nil)
+	query := url.Values{
+		"Caller": {"+447700900123"},
+		"From":   {"+447700900123"},
+		"To":     {"441632960000"},
+	}
+
+	t.Run("Record event", func(t *testing.T) {
+		request := newFixtureRequest(t, "secret", "record.json", query)
+
+		event, err := provider.ParseRecordingEvent(request)
+
+		assert.NoError(t, err)
+		assert.Equal(t, telephony.RecordingEvent{
+			Provider:           "vonage",
+			CallSid:            "CON-bbbbbbbb-cccc-dddd-eeee-0123456789ab",
+			RecordingSid:       "ccccc6f0-e7e1-4b8e-8fd5-0123456789ab",
+			RecordingUrl:       "https://api.nexmo.com/v1/files/aaaaaaaa-bbbb-cccc-dddd-0123456789ab",
+			RecordingStatus:    "completed",
+			RecordingDuration:  14,
+			RecordingStartTime: "2026-10-19T10:15:02Z",
+			Caller:             "+447700900123",
+			From:               "+447700900123",
+			To:                 "+441632960000",
+		}, event)
+	})
+
+	t.Run("Invalid signature", func(t *testing.T) {
+		request := newFixtureRequest(t, "other", "record.json", nil)
+
+		_, err := provider.ParseRecordingEvent(request)
+
+		assert.Equal(t, telephony.ErrUnauthorized, err)
+	})
+
+	t.Run("Tampered body", func(t *testing.T) {
+		request := newFixtureRequest(t, "secret", "record.json", nil)
+		request.Body = strings.Replace(request.Body, "ccccc6f0", "ddddd6f0", 1)
+
+		_, err := provider.ParseRecordingEvent(request)
+
+		assert.Equal(t, telephony.ErrUnauthorized, err)
+	})
+
+	t.Run("Missing signature", func(t *testing.T) {
+		request := newFixtureRequest(t, "secret", "record.json", nil)
+		delete(request.Headers, "Authorization")
+
+		_, err := provider.ParseRecordingEvent(request)
+
+		assert.Equal(t, telephony.ErrUnauthorized, err)
+	})
+
+	t.Run("Missing payload hash", func(t *testing.T) {
+		request := newFixtureRequest(t, "secret", "record.json", nil)
+		request.Headers["Authorization"] = "Bearer " + signClaims(t, "secret", claims{IssuedAt: issuedAt.Unix()})
+
+		_, err := provider.ParseRecordingEvent(request)
+
+		assert.Equal(t, telephony.ErrUnauthorized, err)
+	})
+
+	t.Run("Replayed token", func(t *testing.T) {
+		request := newFixtureRequest(t, "secret", "record.json", query)
+		replaying := newTestProvider(nil, nil)
+		replaying.now = func() time.Time { return issuedAt.Add(time.Hour) }
+
+		_, err := replaying.ParseRecordingEvent(request)
+
+		assert.Equal(t, telephony.ErrUnauthorized, err)
+	})
+
+	t.Run("Tampered query", func(t *testing.T) {
+		request := newFixtureRequest(t, "secret", "record.json", query)
+		request.MultiValueQueryStringParameters["To"] = []string{"441632960999"}
+
+		_, err := provider.ParseRecordingEvent(request)
+
+		assert.Equal(t, telephony.ErrUnauthorized, err)
+	})
+
+	t.Run("Unsigned query", func(t *testing.T) {
+		request := newFixtureRequest(t, "secret", "record.json", nil)
+		request.MultiValueQueryStringParameters = nil
+		request.QueryStringParameters = map[string]string{"To": "441632960999"}
+
+		_, err := provider.ParseRecordingEvent(request)
+
+		assert.Equal(t, telephony.ErrUnauthorized, err)
+	})
+
+	t.Run("Recording elsewhere", func(t *testing.T) {
+		body := strings.Replace(recordFixture(t), "https://api.nexmo.com/", "https://attacker.example/", 1)
+		request := newFixtureRequest(t, "secret", "record.json", query)
+		request.Body = body
+		request.Headers["Authorization"] = "Bearer " + signWebhook(t, "secret", []byte(body))
+
+		_, err := provider.ParseRecordingEvent(request)
+
+		assert.Equal(t, &telephony.ValidationError{Invalid: []string{"RecordingUrl"}}, err)
+	})
+}
+
+func recordFixture(t *testing.T) string {
+	body, err := ioutil.ReadFile("testdata/record.json")
+	assert.NoError(t, err)
+
+	return string(body)
+}
+
+func TestProviderParseCallEvent(t *testing.T) {
+	provider := newTestProvider(nil, nil)
+
+	t.Run("Answer webhook", func(t *testing.T) {
+		event, err := provider.ParseCallEvent(newFixtureRequest(t, "secret", "answer.json", nil))
+
+		assert.NoError(t, err)
+		assert.Equal(t, telephony.CallEvent{
+			CallSid: "CON-bbbbbbbb-cccc-dddd-eeee-0123456789ab",
+			From:    "+447700900123",
+			To:      "+441632960000",
+		}, event)
+	})
+
+	t.Run("Input", func(t *testing.T) {
+		event, err := provider.ParseCallEvent(newFixtureRequest(t, "secret", "input.json", nil))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "1", event.Digits)
+	})
+
+	t.Run("Unanswered connect", func(t *testing.T) {
+		event, err := provider.ParseCallEvent(newFixtureRequest(t, "secret", "connect-timeout.json", nil))
+
+		assert.NoError(t, err)
+		assert.False(t, event.Answered)
+	})
+}
+
+type mockHTTPClient struct {
+	t   *testing.T
+	key *rsa.PublicKey
+}
+
+func (mock mockHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	assert.Equal(mock.t, "https://api.nexmo.com/v1/files/aaaa", req.URL.String())
+
+	parts := strings.Split(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "), ".")
+	assert.Len(mock.t, parts, 3)
+
+	var c claims
+	assert.NoError(mock.t, decodeSegment(parts[1], &c))
+	assert.Equal(mock.t, "app", c.ApplicationID)
+
+	signature, err := encoding.DecodeString(parts[2])
+	assert.NoError(mock.t, err)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	assert.NoError(mock.t, rsa.VerifyPKCS1v15(mock.key, crypto.SHA256, digest[:], signature))
+
+	return &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": {"audio/mpeg"}},
+		Body:       ioutil.NopCloser(bytes.NewBufferString("audio")),
+	}, nil
+}
+
+func TestProviderFetchRecording(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+
+	provider := newTestProvider(key, mockHTTPClient{t: t, key: &key.PublicKey})
+
+	t.Run("Downloads with an application token", func(t *testing.T) {
+		body, err := provider.FetchRecording(context.Background(), telephony.RecordingEvent{
+			RecordingUrl: "https://api.nexmo.com/v1/files/aaaa",
+		})
+		assert.NoError(t, err)
+
+		audio, err := ioutil.ReadAll(body)
+		assert.NoError(t, err)
+		assert.Equal(t, "audio", string(audio))
+	})
+
+	t.Run("Only from the Vonage API", func(t *testing.T) {
+		for _, recordingURL := range []string{
+			"https://attacker.example/v1/files/aaaa",
+			"http://api.nexmo.com/v1/files/aaaa",
+			"https://api.nexmo.com.attacker.example/v1/files/aaaa",
+		} {
+			_, err := provider.FetchRecording(context.Background(), telephony.RecordingEvent{
+				RecordingUrl: recordingURL,
+			})
+
+			assert.Error(t, err, recordingURL)
+		}
+	})
+}
+
+func TestProviderRender(t *testing.T) {
+	provider := newTestProvider(nil, nil)
+
+	t.Run("Voicemail", func(t *testing.T) {
+		response, err := provider.Render(
+			telephony.Say{Text: "Leave a message", Language: "en-GB"},
+			telephony.Record{
+				MaxLength:   120,
+				FinishOnKey: "#",
+				ActionURL:   "https://example.com/voice?Step=recorded",
+				CallbackURL: "https://example.com/webhook",
+			},
+			telephony.Hangup{},
+		)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "application/json", response.Headers["Content-Type"])
+		assert.JSONEq(t, `[
+			{"action": "talk", "text": "Leave a message", "language": "en-GB"},
+			{"action": "record", "format": "mp3", "eventUrl": ["https://example.com/webhook"], "eventMethod": "POST", "timeOut": 120, "endOnKey": "#", "beepStart": true}
+		]`, response.Body)
+	})
+
+	t.Run("Dial", func(t *testing.T) {
+		response, err := provider.Render(telephony.Dial{
+			Number:    "+447700900999",
+			Timeout:   20,
+			ActionURL: "https://example.com/voice?Step=dialled",
+		})
+
+		assert.NoError(t, err)
+		assert.JSONEq(t, `[
+			{"action": "connect", "timeout": 20, "eventType": "synchronous", "eventUrl": ["https://example.com/voice?Step=dialled&sig=`+telephony.QuerySignature(url.Values{"Step": {"dialled"}}, "secret")+`"], "eventMethod": "POST", "endpoint": [{"type": "phone", "number": "447700900999"}]}
+		]`, response.Body)
+	})
+
+	t.Run("Gather", func(t *testing.T) {
+		response, err := provider.Render(
+			telephony.Gather{
+				Prompt:    []telephony.Action{telephony.Say{Text: "Press 1 if this is urgent"}},
+				NumDigits: 1,
+				Timeout:   5,
+				ActionURL: "https://example.com/voice?Step=menu",
+			},
+			telephony.Record{CallbackURL: "https://example.com/webhook"},
+		)
+
+		assert.NoError(t, err)
+		assert.JSONEq(t, `[
+			{"action": "talk", "text": "Press 1 if this is urgent", "bargeIn": true},
+			{"action": "input", "type": ["dtmf"], "dtmf": {"maxDigits": 1, "timeOut": 5}, "eventUrl": ["https://example.com/voice?Step=menu&sig=`+telephony.QuerySignature(url.Values{"Step": {"menu"}}, "secret")+`"], "eventMethod": "POST"}
+		]`, response.Body)
+	})
+
+	t.Run("Reject", func(t *testing.T) {
+		response, err := provider.Render(telephony.Reject{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "[]", response.Body)
+	})
+}
@@ -0,0 +1,172 @@
+// Package webhook stores the recording callbacks that start the pipeline.
+package webhook
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"answering-machine/internal/callers"
+	"answering-machine/internal/carrier"
+	"answering-machine/internal/telephony"
+)
+
+// Deps handles recording callbacks on the webhook route.
+type Deps struct {
+	dynamodb        dynamodbiface.DynamoDBAPI
+	tableName       string
+	callStatusTable string
+	provider        telephony.Provider
+	blocklist       callerList
+}
+
+type callerList interface {
+	Contains(ctx context.Context, number string) (bool, error)
+}
+
+// Handler authenticates a recording callback and stores it once it is
+// final.
+func (deps *Deps) Handler(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	callback, err := deps.provider.ParseRecordingEvent(request)
+	if err == telephony.ErrUnauthorized {
+		log.Printf("rejecting unauthenticated %s callback", deps.provider.Name())
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: 403,
+		}, nil
+	}
+	if validationErr, ok := err.(*telephony.ValidationError); ok {
+		log.Printf("rejecting callback: %s", validationErr)
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: 400,
+			Body:       validationErr.Error(),
+		}, nil
+	}
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 400,
+		}, err
+	}
+
+	caller := callback.CallerNumber()
+
+	blocked, err := deps.blocklist.Contains(ctx, caller)
+	if err != nil {
+		log.Printf("checking blocklist for %s: %s", caller, err)
+	}
+	if blocked {
+		log.Printf("dropping callback %s from blocked caller %s", callback.RecordingSid, caller)
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: 200,
+		}, nil
+	}
+
+	err = deps.recordCallStatus(ctx, callback)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 500,
+		}, err
+	}
+
+	// Only final callbacks go on to the pipeline, the download or missed
+	// call notification is decided by their status.
+	if !callback.Final() {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 200,
+		}, nil
+	}
+
+	attributeValues, err := dynamodbattribute.MarshalMap(callback)
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 400,
+		}, err
+	}
+
+	// Providers retry callbacks, only the first write may start the pipeline.
+	_, err = deps.dynamodb.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		Item:                attributeValues,
+		TableName:           aws.String(deps.tableName),
+		ConditionExpression: aws.String("attribute_not_exists(RecordingSid)"),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		log.Printf("ignoring duplicate callback for %s", callback.RecordingSid)
+
+		return events.APIGatewayProxyResponse{
+			StatusCode: 200,
+		}, nil
+	}
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 400,
+		}, err
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: 200,
+	}, nil
+}
+
+// recordCallStatus keeps the latest recording status for each call. Providers
+// don't guarantee callback order, so in-progress never replaces a final
+// status.
+func (deps *Deps) recordCallStatus(ctx context.Context, callback telephony.RecordingEvent) error {
+	status := callback.RecordingStatus
+	if status == "" {
+		status = telephony.RecordingStatusCompleted
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(deps.callStatusTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"CallSid": {
+				S: aws.String(callback.CallSid),
+			},
+		},
+		UpdateExpression: aws.String("SET RecordingSid = :sid, RecordingStatus = :status"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":sid": {
+				S: aws.String(callback.RecordingSid),
+			},
+			":status": {
+				S: aws.String(status),
+			},
+		},
+	}
+	if !callback.Final() {
+		input.ConditionExpression = aws.String("attribute_not_exists(RecordingStatus) OR RecordingStatus = :status")
+	}
+
+	_, err := deps.dynamodb.UpdateItemWithContext(ctx, input)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil
+	}
+
+	return err
+}
+
+// NewFromEnv configures the handler from the Lambda environment, storing
+// callbacks with dynamodb.
+func NewFromEnv(dynamodb dynamodbiface.DynamoDBAPI, httpClient telephony.HTTPClient) (*Deps, error) {
+	provider, err := carrier.FromEnv(httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Deps{
+		dynamodb:        dynamodb,
+		tableName:       os.Getenv("TABLE"),
+		callStatusTable: os.Getenv("CALL_STATUS_TABLE"),
+		provider:        provider,
+		blocklist:       callers.NewList(dynamodb, os.Getenv("BLOCKLIST_TABLE")),
+	}, nil
+}
@@ -0,0 +1,252 @@
+package webhook
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"answering-machine/internal/twilio"
+)
+
+type mock struct {
+	dynamodbiface.DynamoDBAPI
+
+	t          *testing.T
+	expectedIn dynamodb.PutItemInput
+	mockOut    dynamodb.PutItemOutput
+	mockErr    error
+	tableName  string
+	mockHits   *int
+	statuses   *[]string
+}
+
+func (mock mock) PutItemWithContext(ctx aws.Context, in *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	*mock.mockHits++
+	assert.Equal(mock.t, mock.expectedIn, *in, "they should be equal")
+
+	return &mock.mockOut, mock.mockErr
+}
+
+func (mock mock) UpdateItemWithContext(ctx aws.Context, in *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	*mock.statuses = append(*mock.statuses, *in.ExpressionAttributeValues[":status"].S)
+
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+type mockCallerList map[string]bool
+
+func (mock mockCallerList) Contains(ctx context.Context, number string) (bool, error) {
+	return mock[number], nil
+}
+
+func TestLambdaHandler(t *testing.T) {
+	tableName := "test"
+	authToken := "secret"
+	callSID := "CA123"
+	recordingSID := "123ABC"
+	recordingURL := "https://example.com/recording"
+
+	params := url.Values{
+		"CallSid":      {callSID},
+		"RecordingSid": {recordingSID},
+		"RecordingUrl": {recordingURL},
+	}
+
+	newRequest := func(signature string) events.APIGatewayProxyRequest {
+		return events.APIGatewayProxyRequest{
+			Path: "/webhook",
+			Headers: map[string]string{
+				"Host":                 "example.com",
+				twilio.SignatureHeader: signature,
+			},
+			RequestContext: events.APIGatewayProxyRequestContext{
+				Stage: "live",
+			},
+			Body: params.Encode(),
+		}
+	}
+
+	newDeps := func(t *testing.T, hits *int, mockErr error) Deps {
+		return Deps{
+			dynamodb: mock{
+				t:        t,
+				mockHits: hits,
+				mockErr:  mockErr,
+				statuses: &[]string{},
+				expectedIn: dynamodb.PutItemInput{
+					Item: map[string]*dynamodb.AttributeValue{
+						"CallSid": {
+							S: aws.String(callSID),
+						},
+						"RecordingSid": {
+							S: aws.String(recordingSID),
+						},
+						"RecordingUrl": {
+							S: aws.String(recordingURL),
+						},
+						"Provider": {
+							S: aws.String("twilio"),
+						},
+					},
+					TableName:           aws.String(tableName),
+					ConditionExpression: aws.String("attribute_not_exists(RecordingSid)"),
+				},
+			},
+			tableName: tableName,
+			provider:  twilio.NewProvider("", authToken, nil),
+			blocklist: mockCallerList{},
+		}
+	}
+
+	t.Run("Successful Request", func(t *testing.T) {
+		hits := 0
+		deps := newDeps(t, &hits, nil)
+
+		signature := twilio.Signature(authToken, "https://example.com/live/webhook", params)
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequest(signature))
+		if err != nil {
+			t.Error("Everything should OK")
+		}
+
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, 1, hits)
+	})
+
+	t.Run("In Progress", func(t *testing.T) {
+		hits := 0
+		deps := newDeps(t, &hits, nil)
+		statuses := deps.dynamodb.(mock).statuses
+
+		body := url.Values{
+			"CallSid":         {callSID},
+			"RecordingSid":    {recordingSID},
+			"RecordingStatus": {"in-progress"},
+		}
+		request := newRequest(twilio.Signature(authToken, "https://example.com/live/webhook", body))
+		request.Body = body.Encode()
+
+		resp, err := deps.Handler(aws.BackgroundContext(), request)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, 0, hits)
+		assert.Equal(t, []string{"in-progress"}, *statuses)
+	})
+
+	t.Run("Absent Recording", func(t *testing.T) {
+		hits := 0
+		deps := newDeps(t, &hits, nil)
+		mock := deps.dynamodb.(mock)
+		mock.expectedIn.Item = map[string]*dynamodb.AttributeValue{
+			"CallSid": {
+				S: aws.String(callSID),
+			},
+			"RecordingSid": {
+				S: aws.String(recordingSID),
+			},
+			"RecordingStatus": {
+				S: aws.String("absent"),
+			},
+			"Provider": {
+				S: aws.String("twilio"),
+			},
+		}
+		deps.dynamodb = mock
+
+		body := url.Values{
+			"CallSid":         {callSID},
+			"RecordingSid":    {recordingSID},
+			"RecordingStatus": {"absent"},
+		}
+		request := newRequest(twilio.Signature(authToken, "https://example.com/live/webhook", body))
+		request.Body = body.Encode()
+
+		resp, err := deps.Handler(aws.BackgroundContext(), request)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, 1, hits)
+		assert.Equal(t, []string{"absent"}, *mock.statuses)
+	})
+
+	t.Run("Duplicate Request", func(t *testing.T) {
+		hits := 0
+		deps := newDeps(t, &hits, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "exists", nil))
+
+		signature := twilio.Signature(authToken, "https://example.com/live/webhook", params)
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequest(signature))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, 1, hits)
+	})
+
+	t.Run("Blocked Caller", func(t *testing.T) {
+		hits := 0
+		deps := newDeps(t, &hits, nil)
+		deps.blocklist = mockCallerList{"+447700900123": true}
+
+		body := url.Values{
+			"CallSid":      {callSID},
+			"RecordingSid": {recordingSID},
+			"RecordingUrl": {recordingURL},
+			"From":         {"+447700900123"},
+		}
+		request := newRequest(twilio.Signature(authToken, "https://example.com/live/webhook", body))
+		request.Body = body.Encode()
+
+		resp, err := deps.Handler(aws.BackgroundContext(), request)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+		assert.Equal(t, 0, hits)
+		assert.Empty(t, *deps.dynamodb.(mock).statuses)
+	})
+
+	t.Run("Missing Fields", func(t *testing.T) {
+		hits := 0
+		deps := newDeps(t, &hits, nil)
+
+		body := url.Values{"RecordingSid": {recordingSID}}
+		request := newRequest(twilio.Signature(authToken, "https://example.com/live/webhook", body))
+		request.Body = body.Encode()
+
+		resp, err := deps.Handler(aws.BackgroundContext(), request)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 400, resp.StatusCode)
+		assert.Equal(t, "missing required fields: CallSid, RecordingUrl", resp.Body)
+		assert.Equal(t, 0, hits)
+	})
+
+	t.Run("Invalid Signature", func(t *testing.T) {
+		hits := 0
+		deps := newDeps(t, &hits, nil)
+
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequest("bogus"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 403, resp.StatusCode)
+		assert.Equal(t, 0, hits)
+	})
+
+	t.Run("Missing Signature", func(t *testing.T) {
+		hits := 0
+		deps := newDeps(t, &hits, nil)
+
+		resp, err := deps.Handler(aws.BackgroundContext(), newRequest(""))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 403, resp.StatusCode)
+		assert.Equal(t, 0, hits)
+	})
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/dynamodb"
+	"github.com/pulumi/pulumi/sdk/v2/go/pulumi"
+)
+
+// configureMailboxes creates the table of per-number settings, keyed on
+// the number that was dialled.
+func configureMailboxes(ctx *pulumi.Context) (dynamodb.Table, error) {
+	mailboxTable, err := dynamodb.NewTable(ctx, "answering-machine-mailboxes", &dynamodb.TableArgs{
+		BillingMode: pulumi.String("PAY_PER_REQUEST"),
+		HashKey:     pulumi.String("Number"),
+		Attributes: dynamodb.TableAttributeArray{
+			dynamodb.TableAttributeArgs{
+				Name: pulumi.String("Number"),
+				Type: pulumi.String("S"),
+			},
+		},
+	})
+	if err != nil {
+		return dynamodb.Table{}, err
+	}
+
+	ctx.Export("Mailbox Table", mailboxTable.ID())
+
+	return *mailboxTable, nil
+}
@@ -17,21 +17,46 @@ func main() {
 			return err
 		}
 
-		answeringMachineTable, err := configureWebhook(ctx, account, region)
+		blocklistTable, allowlistTable, err := configureCallerLists(ctx)
 		if err != nil {
 			return err
 		}
 
-		recordingBucketID, err := configureRecordingDownload(ctx, answeringMachineTable)
+		mailboxTable, err := configureMailboxes(ctx)
 		if err != nil {
 			return err
 		}
 
-		transcriptionTable, err := configureGoogleSpeech(ctx, recordingBucketID)
+		recordingBucketID, recordingKey, err := configureRecordingBucket(ctx)
 		if err != nil {
 			return err
 		}
 
-		return configureSendEmail(ctx, answeringMachineTable, transcriptionTable, recordingBucketID)
+		failureQueue, err := configureStreamFailures(ctx)
+		if err != nil {
+			return err
+		}
+
+		answeringMachineTable, messagesTable, err := configureWebhook(ctx, account, region, blocklistTable, allowlistTable, mailboxTable, recordingBucketID)
+		if err != nil {
+			return err
+		}
+
+		err = configureRecordingDownload(ctx, answeringMachineTable, mailboxTable, recordingBucketID, recordingKey, failureQueue)
+		if err != nil {
+			return err
+		}
+
+		err = configureHangUpDigest(ctx, answeringMachineTable, mailboxTable)
+		if err != nil {
+			return err
+		}
+
+		transcriptionTable, err := configureGoogleSpeech(ctx, answeringMachineTable, mailboxTable, recordingBucketID, recordingKey)
+		if err != nil {
+			return err
+		}
+
+		return configureSendEmail(ctx, answeringMachineTable, transcriptionTable, messagesTable, mailboxTable, recordingBucketID, recordingKey, failureQueue)
 	})
 }
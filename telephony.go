@@ -0,0 +1,27 @@
+package main
+
+import (
+	"os"
+
+	"github.com/pulumi/pulumi/sdk/v2/go/pulumi"
+)
+
+// telephonyVariables adds the telephony provider and its credentials to
+// the environment of a function that talks to the carrier.
+func telephonyVariables(variables pulumi.StringMap) pulumi.StringMap {
+	for _, name := range []string{
+		"TELEPHONY_PROVIDER",
+		"TWILIO_ACCOUNT_SID",
+		"TWILIO_AUTH_TOKEN",
+		"VONAGE_SIGNATURE_SECRET",
+		"VONAGE_APPLICATION_ID",
+		"VONAGE_PRIVATE_KEY",
+		"TELNYX_PUBLIC_KEY",
+		"TELNYX_API_KEY",
+		"TELNYX_QUERY_SECRET",
+	} {
+		variables[name] = pulumi.String(os.Getenv(name))
+	}
+
+	return variables
+}
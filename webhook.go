@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+
 	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws"
 	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/apigateway"
 	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/dynamodb"
@@ -8,7 +10,13 @@ import (
 	"github.com/pulumi/pulumi/sdk/v2/go/pulumi"
 )
 
-func configureWebhook(ctx *pulumi.Context, account *aws.GetCallerIdentityResult, region *aws.GetRegionResult) (dynamodb.Table, error) {
+func configureWebhook(
+	ctx *pulumi.Context,
+	account *aws.GetCallerIdentityResult,
+	region *aws.GetRegionResult,
+	blocklistTable, allowlistTable, mailboxTable dynamodb.Table,
+	recordingBucketID pulumi.IDOutput) (dynamodb.Table, dynamodb.Table, error) {
+
 	dynamodbTable, err := dynamodb.NewTable(ctx, "answering-machine-webhook-data", &dynamodb.TableArgs{
 		BillingMode: pulumi.String("PAY_PER_REQUEST"),
 		HashKey:     pulumi.String("RecordingSid"),
@@ -17,37 +25,96 @@ func configureWebhook(ctx *pulumi.Context, account *aws.GetCallerIdentityResult,
 				Name: pulumi.String("RecordingSid"),
 				Type: pulumi.String("S"),
 			},
+			dynamodb.TableAttributeArgs{
+				Name: pulumi.String("DigestPending"),
+				Type: pulumi.String("N"),
+			},
+		},
+		// Sparse, only hang-ups waiting for the digest have DigestPending.
+		GlobalSecondaryIndexes: dynamodb.TableGlobalSecondaryIndexArray{
+			dynamodb.TableGlobalSecondaryIndexArgs{
+				Name:           pulumi.String("DigestPending"),
+				HashKey:        pulumi.String("DigestPending"),
+				ProjectionType: pulumi.String("ALL"),
+			},
 		},
 		StreamEnabled:  pulumi.Bool(true),
 		StreamViewType: pulumi.String("NEW_IMAGE"),
 	})
 	if err != nil {
-		return dynamodb.Table{}, err
+		return dynamodb.Table{}, dynamodb.Table{}, err
+	}
+	ctx.Export("Webhook Data Table", dynamodbTable.ID())
+
+	callStatusTable, err := dynamodb.NewTable(ctx, "answering-machine-call-status", &dynamodb.TableArgs{
+		BillingMode: pulumi.String("PAY_PER_REQUEST"),
+		HashKey:     pulumi.String("CallSid"),
+		Attributes: dynamodb.TableAttributeArray{
+			dynamodb.TableAttributeArgs{
+				Name: pulumi.String("CallSid"),
+				Type: pulumi.String("S"),
+			},
+		},
+	})
+	if err != nil {
+		return dynamodb.Table{}, dynamodb.Table{}, err
+	}
+
+	messagesTable, err := dynamodb.NewTable(ctx, "answering-machine-messages", &dynamodb.TableArgs{
+		BillingMode: pulumi.String("PAY_PER_REQUEST"),
+		HashKey:     pulumi.String("MessageSid"),
+		Attributes: dynamodb.TableAttributeArray{
+			dynamodb.TableAttributeArgs{
+				Name: pulumi.String("MessageSid"),
+				Type: pulumi.String("S"),
+			},
+		},
+		StreamEnabled:  pulumi.Bool(true),
+		StreamViewType: pulumi.String("NEW_IMAGE"),
+	})
+	if err != nil {
+		return dynamodb.Table{}, dynamodb.Table{}, err
+	}
+
+	blocklistStatement := policyStatementEntry{
+		Effect:       "Allow",
+		Action:       []string{"dynamodb:BatchGetItem"},
+		Resource:     []string{"%s"},
+		resourceArgs: []interface{}{blocklistTable.Arn},
 	}
 
 	statementEntries := []policyStatementEntry{
+		blocklistStatement,
 		{
 			Effect:       "Allow",
 			Action:       []string{"dynamodb:PutItem"},
 			Resource:     []string{"arn:aws:dynamodb:*:*:table/%s"},
 			resourceArgs: []interface{}{dynamodbTable.ID()},
 		},
+		{
+			Effect:       "Allow",
+			Action:       []string{"dynamodb:UpdateItem"},
+			Resource:     []string{"arn:aws:dynamodb:*:*:table/%s"},
+			resourceArgs: []interface{}{callStatusTable.ID()},
+		},
 	}
 
 	env := lambda.FunctionEnvironmentArgs{
-		Variables: pulumi.StringMap{
-			"TABLE": dynamodbTable.ID(),
-		},
+		Variables: telephonyVariables(pulumi.StringMap{
+			"TABLE":             dynamodbTable.ID(),
+			"CALL_STATUS_TABLE": callStatusTable.ID(),
+			"BLOCKLIST_TABLE":   blocklistTable.ID(),
+		}),
 	}
 
-	function, err := makeLambda(ctx, "webhook", statementEntries, env)
+	function, err := makeLambda(ctx, "webhook", statementEntries, env, 10)
 	if err != nil {
-		return dynamodb.Table{}, err
+		return dynamodb.Table{}, dynamodb.Table{}, err
 	}
 
 	gateway, err := apigateway.NewRestApi(ctx, "answering-machine-webhook-api", &apigateway.RestApiArgs{
 		Name:        pulumi.String("answering-machine-webhook-api"),
-		Description: pulumi.String("Twilio recording webhook"),
+		Description: pulumi.String("Twilio voice, recording and messaging webhooks"),
 		Policy: pulumi.String(`{
 			"Version": "2012-10-17",
 			"Statement": [{
@@ -67,55 +134,123 @@ func configureWebhook(ctx *pulumi.Context, account *aws.GetCallerIdentityResult,
 			}]
 		}`)})
 	if err != nil {
-		return dynamodb.Table{}, err
+		return dynamodb.Table{}, dynamodb.Table{}, err
+	}
+
+	sourceArn := pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/*/*", region.Name, account.AccountId, gateway.ID())
+
+	webhookRoute, err := makeRoute(ctx, "webhook", gateway, function, sourceArn)
+	if err != nil {
+		return dynamodb.Table{}, dynamodb.Table{}, err
+	}
+
+	voiceStatementEntries := []policyStatementEntry{
+		blocklistStatement,
+		{
+			Effect:       "Allow",
+			Action:       []string{"dynamodb:BatchGetItem"},
+			Resource:     []string{"%s"},
+			resourceArgs: []interface{}{allowlistTable.Arn},
+		},
+		{
+			Effect:       "Allow",
+			Action:       []string{"dynamodb:GetItem"},
+			Resource:     []string{"%s"},
+			resourceArgs: []interface{}{mailboxTable.Arn},
+		},
+		{
+			Effect:       "Allow",
+			Action:       []string{"s3:ListBucket"},
+			Resource:     []string{"arn:aws:s3:::%s"},
+			resourceArgs: []interface{}{recordingBucketID},
+		},
+		{
+			Effect:       "Allow",
+			Action:       []string{"s3:GetObject"},
+			Resource:     []string{"arn:aws:s3:::%s/greetings/*"},
+			resourceArgs: []interface{}{recordingBucketID},
+		},
 	}
 
-	webhookResource, err := apigateway.NewResource(ctx, "answering-machine-webhook-api-webhook-resource", &apigateway.ResourceArgs{
-		RestApi:  gateway.ID(),
-		PathPart: pulumi.String("webhook"),
-		ParentId: gateway.RootResourceId,
-	}, pulumi.DependsOn([]pulumi.Resource{gateway}))
+	voiceFunction, err := makeLambda(ctx, "voice", voiceStatementEntries, lambda.FunctionEnvironmentArgs{
+		Variables: telephonyVariables(pulumi.StringMap{
+			"BLOCKLIST_TABLE":            blocklistTable.ID(),
+			"ALLOWLIST_TABLE":            allowlistTable.ID(),
+			"MAILBOX_TABLE":              mailboxTable.ID(),
+			"GREETINGS_BUCKET":           recordingBucketID,
+			"FORWARDING_NUMBER":          pulumi.String(os.Getenv("FORWARDING_NUMBER")),
+			"FORWARDING_TIMEOUT":         pulumi.String(os.Getenv("FORWARDING_TIMEOUT")),
+			"FORWARD_WHEN_OPEN":          pulumi.String(os.Getenv("FORWARD_WHEN_OPEN")),
+			"SCHEDULE_TIMEZONE":          pulumi.String(os.Getenv("SCHEDULE_TIMEZONE")),
+			"SCHEDULE_HOURS":             pulumi.String(os.Getenv("SCHEDULE_HOURS")),
+			"SCHEDULE_HOLIDAYS":          pulumi.String(os.Getenv("SCHEDULE_HOLIDAYS")),
+			"VOICE_GREETING":             pulumi.String(os.Getenv("VOICE_GREETING")),
+			"VOICE_GREETING_URL":         pulumi.String(os.Getenv("VOICE_GREETING_URL")),
+			"VOICE_GREETING_CLOSED":      pulumi.String(os.Getenv("VOICE_GREETING_CLOSED")),
+			"VOICE_GREETING_URL_CLOSED":  pulumi.String(os.Getenv("VOICE_GREETING_URL_CLOSED")),
+			"VOICE_GREETING_HOLIDAY":     pulumi.String(os.Getenv("VOICE_GREETING_HOLIDAY")),
+			"VOICE_GREETING_URL_HOLIDAY": pulumi.String(os.Getenv("VOICE_GREETING_URL_HOLIDAY")),
+			"VOICE_NAME":                 pulumi.String(os.Getenv("VOICE_NAME")),
+			"VOICE_LANGUAGE":             pulumi.String(os.Getenv("VOICE_LANGUAGE")),
+			"VOICE_MAX_LENGTH":           pulumi.String(os.Getenv("VOICE_MAX_LENGTH")),
+			"VOICE_FINISH_ON_KEY":        pulumi.String(os.Getenv("VOICE_FINISH_ON_KEY")),
+			"IVR_OPTIONS":                pulumi.String(os.Getenv("IVR_OPTIONS")),
+			"IVR_PROMPT":                 pulumi.String(os.Getenv("IVR_PROMPT")),
+			"IVR_TIMEOUT":                pulumi.String(os.Getenv("IVR_TIMEOUT")),
+		}),
+	}, 10)
 	if err != nil {
-		return dynamodb.Table{}, err
+		return dynamodb.Table{}, dynamodb.Table{}, err
 	}
 
-	_, err = apigateway.NewMethod(ctx, "answering-machine-webhook-api-post-method", &apigateway.MethodArgs{
-		HttpMethod:    pulumi.String("POST"),
-		Authorization: pulumi.String("NONE"),
-		RestApi:       gateway.ID(),
-		ResourceId:    webhookResource.ID(),
-	}, pulumi.DependsOn([]pulumi.Resource{gateway, webhookResource}))
+	voiceRoute, err := makeRoute(ctx, "voice", gateway, voiceFunction, sourceArn)
 	if err != nil {
-		return dynamodb.Table{}, err
+		return dynamodb.Table{}, dynamodb.Table{}, err
+	}
+
+	smsStatementEntries := []policyStatementEntry{
+		blocklistStatement,
+		{
+			Effect:       "Allow",
+			Action:       []string{"dynamodb:PutItem"},
+			Resource:     []string{"%s"},
+			resourceArgs: []interface{}{messagesTable.Arn},
+		},
+		{
+			Effect: "Allow",
+			Action: []string{"s3:PutObject"},
+			Resource: []string{
+				"arn:aws:s3:::%s/*",
+			},
+			resourceArgs: []interface{}{recordingBucketID},
+		},
 	}
 
-	_, err = apigateway.NewIntegration(ctx, "answering-machine-webhook-api-lambda-integration", &apigateway.IntegrationArgs{
-		HttpMethod:            pulumi.String("POST"),
-		IntegrationHttpMethod: pulumi.String("POST"),
-		ResourceId:            webhookResource.ID(),
-		RestApi:               gateway.ID(),
-		Type:                  pulumi.String("AWS_PROXY"),
-		Uri:                   function.InvokeArn,
-	}, pulumi.DependsOn([]pulumi.Resource{gateway, webhookResource, function}))
+	smsFunction, err := makeLambda(ctx, "sms", smsStatementEntries, lambda.FunctionEnvironmentArgs{
+		Variables: telephonyVariables(pulumi.StringMap{
+			"MESSAGES_TABLE":        messagesTable.ID(),
+			"BLOCKLIST_TABLE":       blocklistTable.ID(),
+			"RECORDING_BUCKET_NAME": recordingBucketID,
+		}),
+	}, 10)
 	if err != nil {
-		return dynamodb.Table{}, err
+		return dynamodb.Table{}, dynamodb.Table{}, err
 	}
 
-	permission, err := lambda.NewPermission(ctx, "answering-machine-webhook-api-lambda-permission", &lambda.PermissionArgs{
-		Action:    pulumi.String("lambda:InvokeFunction"),
-		Function:  function.Name,
-		Principal: pulumi.String("apigateway.amazonaws.com"),
-		SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/*/*", region.Name, account.AccountId, gateway.ID()),
-	}, pulumi.DependsOn([]pulumi.Resource{gateway, webhookResource, function}))
+	smsRoute, err := makeRoute(ctx, "sms", gateway, smsFunction, sourceArn)
 	if err != nil {
-		return dynamodb.Table{}, err
+		return dynamodb.Table{}, dynamodb.Table{}, err
 	}
 
+	routes := append([]pulumi.Resource{gateway}, webhookRoute...)
+	routes = append(routes, voiceRoute...)
+	routes = append(routes, smsRoute...)
+
 	deployment, err := apigateway.NewDeployment(ctx, "answering-machine-webhook-api-deployment", &apigateway.DeploymentArgs{
 		RestApi: gateway.ID(),
-	}, pulumi.DependsOn([]pulumi.Resource{gateway, webhookResource, function, permission}))
+	}, pulumi.DependsOn(routes))
 	if err != nil {
-		return dynamodb.Table{}, err
+		return dynamodb.Table{}, dynamodb.Table{}, err
 	}
 
 	_, err = apigateway.NewStage(ctx, "live", &apigateway.StageArgs{
@@ -125,10 +260,19 @@ func configureWebhook(ctx *pulumi.Context, account *aws.GetCallerIdentityResult,
 		XrayTracingEnabled: pulumi.Bool(true),
 	})
 	if err != nil {
-		return dynamodb.Table{}, err
+		return dynamodb.Table{}, dynamodb.Table{}, err
 	}
 
 	ctx.Export("Webhook Endpoint", pulumi.Sprintf("https://%s.execute-api.%s.amazonaws.com/live/webhook", gateway.ID(), region.Name))
+	ctx.Export("Voice Endpoint", pulumi.Sprintf("https://%s.execute-api.%s.amazonaws.com/live/voice", gateway.ID(), region.Name))
+	ctx.Export("SMS Endpoint", pulumi.Sprintf("https://%s.execute-api.%s.amazonaws.com/live/sms", gateway.ID(), region.Name))
+
+	// Vonage calls an application's answer URL with GET by default, which
+	// the voice route doesn't accept. It must be set to POST, so the call
+	// arrives as signed JSON.
+	if os.Getenv("TELEPHONY_PROVIDER") == "vonage" {
+		ctx.Export("Vonage Answer URL", pulumi.Sprintf("POST https://%s.execute-api.%s.amazonaws.com/live/voice", gateway.ID(), region.Name))
+	}
 
-	return *dynamodbTable, nil
+	return *dynamodbTable, *messagesTable, nil
 }